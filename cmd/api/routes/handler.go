@@ -72,6 +72,8 @@ func (h *Handler) GetHttpHandler() http.Handler {
 		r.Get("/activity/{wallet}", h.WalletActivityHandler)
 		r.Get("/holdings/{wallet}/{token}", h.HoldingsLookupHandler)
 
+		r.Get("/admin/ingest", h.IngestHandler)
+
 	})
 
 	return r
@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultIngestWindow = 216000
+	maxIngestWindow     = 2160000
+	ingestGaps          = 50
+)
+
+// IngestHandler godoc
+//
+//	@Summary		Ingest completeness
+//	@Description	Summarise the slot ledger over the most recent slots: how many were processed, skipped, failed or never seen, where they came from, and the latest gaps
+//
+//	@Security		ApiKeyAuth
+//
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	query		int	false	"Number of recent slots to cover (default 216000)"
+//	@Success		200		{object}	types.IngestSummary
+//	@Failure		400		{object}	map[string]interface{}
+//	@Failure		500		{object}	map[string]interface{}
+//	@Router			/v1/admin/ingest [get]
+func (h *Handler) IngestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	window := uint64(defaultIngestWindow)
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 || parsed > maxIngestWindow {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	summary, err := h.swapsRepo.GetIngestSummary(ctx, window, ingestGaps)
+	if err != nil {
+		log.Printf("Failed to summarise ingest: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
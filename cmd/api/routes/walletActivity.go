@@ -23,6 +23,7 @@ import (
 //	@Param			wallet	path		string	true	"Wallet Address"
 //	@Param			limit	query		int		false	"Limit of records"		default(100)
 //	@Param			offset	query		int		false	"Offset for pagination"	default(0)
+//	@Param			finalized	query		bool	false	"Only return swaps from finalized slots"	default(false)
 //	@Success		200		{object}	types.WalletActivityResponse
 //	@Failure		400		{object}	map[string]interface{}
 //	@Failure		500		{object}	map[string]interface{}
@@ -47,7 +48,9 @@ func (h *Handler) WalletActivityHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	swaps, err := h.swapsRepo.GetAllWalletSwaps(ctx, address, limit, offset)
+	finalizedOnly := r.URL.Query().Get("finalized") == "true"
+
+	swaps, err := h.swapsRepo.GetAllWalletSwaps(ctx, address, limit, offset, finalizedOnly)
 	if err != nil {
 		log.Printf("Failed to GetAllWalletSwaps: %v", err)
 		http.Error(w, "", http.StatusInternalServerError)
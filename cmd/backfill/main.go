@@ -5,20 +5,47 @@ import (
 	"blocsy/internal/solana"
 	"blocsy/internal/utils"
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+const usage = `usage: backfill [command] [flags]
+
+commands:
+  gaps                      fill gaps between processed blocks, forever (default)
+  range -from N -to M       queue every block in the slot range
+  wallet -address A [...]   queue the transaction history of a wallet
+  token -address A [...]    queue the transaction history of a token`
+
+// defaultGapWindow is about two days of slots.
+const defaultGapWindow = 432000
+
 func main() {
+	command, args := "gaps", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer cancel()
 
 	utils.LoadEnvironment()
 
+	switch command {
+	case "gaps", "range", "wallet", "token":
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
 	go monitor(ctx)
 
 	dbx, err := utils.GetDBConnection(ctx)
@@ -34,10 +61,79 @@ func main() {
 	queueHandler := solana.NewSolanaQueueHandler(nil, nil)
 	backfillService := solana.NewBackfillService(solCli, pRepo, queueHandler)
 
+	switch command {
+	case "gaps":
+		backfillGaps(ctx, backfillService, pRepo)
+	case "range":
+		backfillRange(ctx, backfillService, args)
+	case "wallet", "token":
+		backfillAddress(ctx, backfillService, command, args)
+	}
+}
+
+func backfillRange(ctx context.Context, backfillService *solana.BackfillService, args []string) {
+	flags := flag.NewFlagSet("range", flag.ExitOnError)
+	from := flags.Int("from", 0, "first slot")
+	to := flags.Int("to", 0, "last slot (0 = current slot)")
+	flags.Parse(args)
+
+	if *from <= 0 || (*to != 0 && *to < *from) {
+		log.Fatalf("range needs -from and a -to not below it")
+	}
+
+	if err := backfillService.HandleBackFill(ctx, *from, *to, true); err != nil {
+		log.Fatalf("Error backfilling %d --> %d: %v", *from, *to, err)
+	}
+}
+
+func backfillAddress(ctx context.Context, backfillService *solana.BackfillService, command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	address := flags.String("address", "", command+" address")
+	before := flags.String("before", "", "start below this signature")
+	until := flags.String("until", "", "stop at this signature")
+	minSlot := flags.Uint64("min-slot", 0, "stop at the first transaction older than this slot")
+	limit := flags.Int("limit", 0, "maximum number of signatures to walk (0 = full history)")
+	flags.Parse(args)
+
+	if *address == "" {
+		log.Fatalf("%s needs -address", command)
+	}
+
+	opts := solana.AddressBackfillOptions{
+		Before:  *before,
+		Until:   *until,
+		MinSlot: *minSlot,
+		Limit:   *limit,
+	}
+	var stats solana.AddressBackfillStats
+	var err error
+	if command == "token" {
+		stats, err = backfillService.BackfillToken(ctx, *address, opts)
+	} else {
+		stats, err = backfillService.BackfillAddress(ctx, *address, opts)
+	}
+	if err != nil {
+		log.Fatalf("Error backfilling %s %s after %d signatures: %v", command, *address, stats.Signatures, err)
+	}
+	log.Printf("Backfilled %s %s: %d signatures, %d queued, %d failed, %d skipped",
+		command, *address, stats.Signatures, stats.Queued, stats.Failed, stats.Skipped)
+}
+
+func backfillGaps(ctx context.Context, backfillService *solana.BackfillService, pRepo *db.TimescaleRepository) {
 	numWorkers := 1
 
+	// Only the recent slots are searched; older gaps are left to range backfills.
+	window := uint64(defaultGapWindow)
+	if w, err := strconv.ParseUint(os.Getenv("SLOT_GAP_WINDOW"), 10, 64); err == nil && w > 0 {
+		window = w
+	}
+
 	for {
-		blocks, err := pRepo.FindMissingBlocks(ctx)
+		if err := backfillService.RetryFailedBlocks(ctx); err != nil {
+			log.Printf("Error retrying failed blocks: %v", err)
+		}
+
+		blocks, err := pRepo.FindMissingBlocks(ctx, window)
 		if err != nil {
 			log.Fatalf("Error getting missing blocks: %v", err)
 		}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"blocsy/internal/db"
 	"blocsy/internal/solana"
 	"blocsy/internal/utils"
 	"context"
+	"expvar"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 )
 
 func main() {
@@ -24,15 +28,39 @@ func main() {
 
 func solanaListener(ctx context.Context) {
 
-	grpcAddress := os.Getenv("SOL_GRPC")
-	if grpcAddress == "" {
+	if os.Getenv("SOL_GRPC") == "" {
 		log.Fatalf("SOL_GRPC is required")
 	}
+	grpcAddresses := strings.Split(os.Getenv("SOL_GRPC"), ",")
 
-	authToken := os.Getenv("SOL_GRPC_AUTH_TOKEN")
+	var authTokens []string
+	if os.Getenv("SOL_GRPC_AUTH_TOKEN") != "" {
+		authTokens = strings.Split(os.Getenv("SOL_GRPC_AUTH_TOKEN"), ",")
+	}
 
 	queueHandler := solana.NewSolanaQueueHandler(nil, nil)
-	sbl := solana.NewBlockListener(grpcAddress, queueHandler, authToken)
+
+	var backfillService *solana.BackfillService
+	if os.Getenv("SOL_HTTPS_BACKFILL_NODES") != "" {
+		dbx, err := utils.GetDBConnection(ctx)
+		if err != nil {
+			log.Fatalf("Error connecting to db: %v", err)
+		}
+		backfillService = solana.NewBackfillService(solana.NewSolanaService(ctx), db.NewTimescaleRepository(dbx), queueHandler)
+	}
+
+	checkpoint := solana.NewSlotCheckpoint(os.Getenv("SOL_GRPC_CHECKPOINT_FILE"))
+	sbl := solana.NewBlockListener(grpcAddresses, queueHandler, authTokens, checkpoint, backfillService)
+
+	expvar.Publish("endpoints", expvar.Func(func() any { return sbl.Stats() }))
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			log.Printf("Serving metrics on %s/debug/vars", addr)
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				log.Printf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
 
 	go func() {
 		log.Println("Listening for new blocks (solana)...")
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"blocsy/cmd/api/websocket"
+	"blocsy/internal/cache"
+	"blocsy/internal/db"
+	"blocsy/internal/solana"
+	"blocsy/internal/utils"
+	"context"
+	"expvar"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Runs the listener and the tx processor in one process. Without
+// QUEUE_TRANSPORT the two are connected by the in-memory transport.
+func main() {
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	utils.LoadEnvironment()
+
+	if os.Getenv("QUEUE_TRANSPORT") == "" {
+		os.Setenv("QUEUE_TRANSPORT", solana.TransportMemory)
+	}
+
+	if os.Getenv("SOL_GRPC") == "" {
+		log.Fatalf("SOL_GRPC is required")
+	}
+
+	dbx, err := utils.GetDBConnection(ctx)
+	if err != nil {
+		log.Fatalf("Error connecting to db: %v", err)
+	}
+
+	defer func() {
+		log.Println("Closing DB connection...")
+		dbx.Close()
+	}()
+
+	c := cache.NewCache()
+	pRepo := db.NewTimescaleRepository(dbx)
+
+	websocketServer := websocket.NewWebSocketServer()
+	go websocketServer.Start()
+
+	solSvc := solana.NewSolanaService(ctx)
+
+	tf := solana.NewTokenFinder(c, solSvc, pRepo)
+	tf.NewTokenProcessor()
+	pf := solana.NewPairsService(c, tf, solSvc, pRepo)
+	pf.NewPairProcessor()
+
+	sh := solana.NewSwapHandler(tf, pf)
+	txHandler := solana.NewTxHandler(sh, solSvc, pRepo, pRepo, websocketServer)
+
+	consumer := solana.NewSolanaQueueHandler(txHandler, pRepo)
+	go solana.NewCommitmentReconciler(pRepo).Run(ctx)
+	if os.Getenv("SOL_GRPC_POOL_STATE") == "true" {
+		poolTransport, err := solana.NewPoolStateTransport()
+		if err != nil {
+			log.Fatalf("Failed to create pool state transport: %v", err)
+		}
+		go solana.NewPoolStateWriter(poolTransport, pRepo).Run(ctx)
+	}
+	producer := solana.NewSolanaQueueHandler(nil, nil)
+
+	var authTokens []string
+	if os.Getenv("SOL_GRPC_AUTH_TOKEN") != "" {
+		authTokens = strings.Split(os.Getenv("SOL_GRPC_AUTH_TOKEN"), ",")
+	}
+
+	var backfillService *solana.BackfillService
+	if os.Getenv("SOL_HTTPS_BACKFILL_NODES") != "" {
+		backfillService = solana.NewBackfillService(solSvc, pRepo, producer)
+	}
+
+	checkpoint := solana.NewSlotCheckpoint(os.Getenv("SOL_GRPC_CHECKPOINT_FILE"))
+	sbl := solana.NewBlockListener(strings.Split(os.Getenv("SOL_GRPC"), ","), producer, authTokens, checkpoint, backfillService)
+
+	expvar.Publish("queue", expvar.Func(func() any { return consumer.Stats() }))
+	expvar.Publish("parse", expvar.Func(func() any { return txHandler.ParseStats() }))
+	expvar.Publish("endpoints", expvar.Func(func() any { return sbl.Stats() }))
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			log.Printf("Serving metrics on %s/debug/vars", addr)
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				log.Printf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
+
+	go func() {
+		log.Println("Listening for new blocks (solana)...")
+		if err := sbl.Listen(); err != nil {
+			log.Fatalf("failed to listen, err: %v", err)
+		}
+	}()
+
+	go func() {
+		log.Printf("Processing solana txs (%s transport)...", os.Getenv("QUEUE_TRANSPORT"))
+		consumer.ListenToSolanaQueue(ctx)
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down pipeline...")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"blocsy/internal/solana"
+	"blocsy/internal/utils"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Moves dead-lettered blocks back onto the solana-tx queue. It stops after
+// -limit messages or once the dead-letter queue has been idle for -idle.
+func main() {
+	limit := flag.Int("limit", 0, "maximum number of messages to re-drive (0 = all)")
+	idle := flag.Duration("idle", 10*time.Second, "stop after the dead-letter queue is idle this long")
+	dryRun := flag.Bool("dry-run", false, "only list dead-lettered messages")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	utils.LoadEnvironment()
+
+	transport, err := solana.NewTransport()
+	if err != nil {
+		log.Fatalf("Failed to create queue transport: %v", err)
+	}
+	defer transport.Close()
+
+	deliveries, err := transport.ConsumeDeadLetters(ctx)
+	if err != nil {
+		log.Fatalf("Failed to consume dead letters: %v", err)
+	}
+
+	// A dry run holds what it lists and puts it back at the end, so listing
+	// never consumes a message. RabbitMQ hands out no more than the prefetch
+	// unacked, so the count comes from the queue itself.
+	var listed []solana.Delivery
+	redriven := 0
+	for *limit == 0 || redriven < *limit {
+		var d solana.Delivery
+		var ok bool
+
+		select {
+		case d, ok = <-deliveries:
+		case <-time.After(*idle):
+			ok = false
+		case <-ctx.Done():
+			ok = false
+		}
+		if !ok {
+			break
+		}
+
+		log.Printf("Dead letter (failed at %v, %d times): %v",
+			d.Headers[solana.HeaderFailedAt], solana.HeaderInt(d.Headers, solana.HeaderDeadLetters), d.Headers[solana.HeaderFailureReason])
+		if *dryRun {
+			listed = append(listed, d)
+			redriven++
+			continue
+		}
+
+		headers := make(map[string]interface{}, len(d.Headers))
+		for k, v := range d.Headers {
+			headers[k] = v
+		}
+		delete(headers, solana.HeaderFailureReason)
+		delete(headers, solana.HeaderFailedAt)
+
+		msg := d.Message
+		msg.Headers = headers
+		if err = transport.Publish(msg); err != nil {
+			log.Printf("Failed to re-drive message: %v", err)
+			if err = d.Nack(true); err != nil {
+				log.Printf("Failed to nack message: %v", err)
+			}
+			break
+		}
+
+		if err = d.Ack(); err != nil {
+			log.Printf("Failed to ack message: %v", err)
+		}
+		redriven++
+	}
+
+	if *dryRun {
+		// The listed messages are unacked, so the depth leaves them out.
+		found := -1
+		if d, ok := transport.(solana.DeadLetterDepth); ok {
+			if n, err := d.DeadLetterDepth(); err == nil {
+				found = n + len(listed)
+			}
+		}
+		for _, d := range listed {
+			if err = d.Nack(true); err != nil {
+				log.Printf("Failed to requeue listed message: %v", err)
+			}
+		}
+		if found < 0 {
+			log.Printf("Listed %d dead-lettered messages", len(listed))
+			return
+		}
+		log.Printf("Found %d dead-lettered messages, listed %d", found, len(listed))
+		return
+	}
+	log.Printf("Re-drove %d messages", redriven)
+}
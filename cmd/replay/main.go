@@ -0,0 +1,146 @@
+package main
+
+import (
+	"blocsy/internal/db"
+	"blocsy/internal/solana"
+	"blocsy/internal/types"
+	"blocsy/internal/utils"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Re-runs the parser without touching RPC, over archived getBlock responses or,
+// with -capture, over blocks recorded by the tx processor (QUEUE_RECORD_DIR).
+// Results go to the database, or with -out to a JSON lines file.
+func main() {
+	src := flag.String("src", "", "block file, directory of block files or tar of them (optionally .zst)")
+	capture := flag.Bool("capture", false, "src is a recording or a directory of recordings")
+	from := flag.Uint64("from", 0, "first slot to replay")
+	to := flag.Uint64("to", 0, "last slot to replay (0 = all)")
+	speed := flag.Float64("speed", 0, "replay speed relative to block time (0 = as fast as possible)")
+	out := flag.String("out", "", "write results to this file instead of the database")
+	flag.Parse()
+
+	if *src == "" {
+		log.Fatalf("-src is required")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+
+	sh := solana.NewSwapHandler(offlineTokenFinder{}, offlinePairFinder{})
+
+	var txHandler *solana.TxHandler
+	var swaps solana.SwapsRepo
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+
+		sink := newFileSink(f)
+		txHandler = solana.NewTxHandler(sh, nil, sink, nil, nil)
+		swaps = sink
+	} else {
+		utils.LoadEnvironment()
+
+		dbx, err := utils.GetDBConnection(ctx)
+		if err != nil {
+			log.Fatalf("Error connecting to db: %v", err)
+		}
+		defer dbx.Close()
+
+		pRepo := db.NewTimescaleRepository(dbx)
+		txHandler = solana.NewTxHandler(sh, nil, pRepo, pRepo, nil)
+		swaps = pRepo
+	}
+
+	var blocks, txs, swapCount int
+	var firstBlockTime int64
+	started := time.Now()
+
+	// pace holds a block back until its time has come at -speed.
+	pace := func(blockTime int64) error {
+		if *speed <= 0 {
+			return nil
+		}
+		if firstBlockTime == 0 {
+			firstBlockTime = blockTime
+		}
+		due := started.Add(time.Duration(float64(time.Duration(blockTime-firstBlockTime)*time.Second) / *speed))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(due)):
+			return nil
+		}
+	}
+
+	if *capture {
+		recorded, err := solana.ReadRecordings(ctx, *src, *from, *to)
+		if err != nil {
+			log.Fatalf("Failed to read recordings: %v", err)
+		}
+
+		qh := solana.NewQueueHandlerWithTransport(txHandler, swaps, nil)
+		for _, block := range recorded {
+			if err = pace(block.Timestamp); err != nil {
+				break
+			}
+			blockSwaps, err := qh.ReplayBlock(ctx, block)
+			if err != nil {
+				log.Printf("Failed to replay slot %d: %v", block.Block, err)
+			}
+			blocks++
+			txs += len(block.Transactions)
+			swapCount += len(blockSwaps)
+		}
+
+		txHandler.Wg.Wait()
+		log.Printf("Replayed %d recorded blocks in %s: %d txs, %d swaps", blocks, time.Since(started).Round(time.Millisecond), txs, swapCount)
+		return
+	}
+
+	err := solana.ReadArchive(ctx, *src, *from, *to, func(slot uint64, msg *types.HTTPBlockMessage) error {
+		if msg.Result == nil {
+			return nil
+		}
+		blockTime := msg.Result.BlockTime
+
+		if err := pace(blockTime); err != nil {
+			return err
+		}
+
+		var blockSwaps []types.SwapLog
+		for i := range msg.Result.Transactions {
+			processed, _, err := txHandler.ProcessTransaction(ctx, &msg.Result.Transactions[i], blockTime, slot, true)
+			if err != nil {
+				log.Printf("Failed to process a transaction in slot %d: %v", slot, err)
+				continue
+			}
+			blockSwaps = append(blockSwaps, processed...)
+		}
+		if err := swaps.InsertSwaps(ctx, blockSwaps); err != nil {
+			return err
+		}
+
+		blocks++
+		txs += len(msg.Result.Transactions)
+		swapCount += len(blockSwaps)
+		if blocks%1000 == 0 {
+			log.Printf("Replayed %d blocks up to slot %d: %d txs, %d swaps", blocks, slot, txs, swapCount)
+		}
+		return nil
+	})
+
+	txHandler.Wg.Wait()
+	if err != nil {
+		log.Fatalf("Replay stopped after %d blocks: %v", blocks, err)
+	}
+	log.Printf("Replayed %d blocks in %s: %d txs, %d swaps", blocks, time.Since(started).Round(time.Millisecond), txs, swapCount)
+}
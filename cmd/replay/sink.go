@@ -0,0 +1,108 @@
+package main
+
+import (
+	"blocsy/internal/solana"
+	"blocsy/internal/types"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// offlineTokenFinder and offlinePairFinder stand in for the finders, which
+// would look up unknown tokens and pairs over RPC.
+type offlineTokenFinder struct{}
+
+func (offlineTokenFinder) FindToken(context.Context, string, bool) (*types.Token, *[]types.Pair, error) {
+	return &types.Token{}, &[]types.Pair{}, nil
+}
+func (offlineTokenFinder) AddToQueue(string) {}
+
+type offlinePairFinder struct{}
+
+func (offlinePairFinder) FindPair(context.Context, string, *string) (*types.Pair, *types.QuoteToken, error) {
+	return &types.Pair{}, &types.QuoteToken{}, nil
+}
+func (offlinePairFinder) AddToQueue(solana.PairProcessorQueue) {}
+
+type record struct {
+	Kind string      `json:"kind"`
+	Slot uint64      `json:"slot"`
+	Data interface{} `json:"data"`
+}
+
+// fileSink writes everything the tx handler would store as JSON lines.
+type fileSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newFileSink(w io.Writer) *fileSink {
+	return &fileSink{enc: json.NewEncoder(w)}
+}
+
+func (s *fileSink) write(kind string, slot uint64, data interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(record{Kind: kind, Slot: slot, Data: data})
+}
+
+func (s *fileSink) InsertSwaps(_ context.Context, swaps []types.SwapLog) error {
+	for _, swap := range swaps {
+		if err := s.write("swap", swap.BlockNumber, swap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) InsertToken(_ context.Context, token types.Token) error {
+	return s.write("token", uint64(token.CreatedBlock), token)
+}
+
+func (s *fileSink) ApplyTokenSupplyChange(_ context.Context, block uint64, _ string, _ int, _ int, address string, changeAmount string, action string) error {
+	return s.write("supply", block, map[string]string{"address": address, "amount": changeAmount, "action": action})
+}
+
+func (s *fileSink) UpdateTokenSupply(_ context.Context, address string, changeAmount string, action string) error {
+	return s.write("supply", 0, map[string]string{"address": address, "amount": changeAmount, "action": action})
+}
+
+func (s *fileSink) InsertTokenEvents(_ context.Context, events []types.TokenEvent) error {
+	for _, event := range events {
+		if err := s.write("tokenEvent", event.Slot, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) FindToken(context.Context, string) (*types.Token, error) {
+	return &types.Token{}, nil
+}
+func (s *fileSink) UpdateTokenInfo(context.Context, string, *types.Metadata) error { return nil }
+func (s *fileSink) UpdateTokenDecimals(context.Context, string, int) error         { return nil }
+
+func (s *fileSink) InsertPair(_ context.Context, pair types.Pair) error {
+	return s.write("pair", 0, pair)
+}
+func (s *fileSink) FindPair(context.Context, string) (*types.Pair, error) {
+	return &types.Pair{}, nil
+}
+func (s *fileSink) FindPairsByToken(context.Context, string) ([]*types.Pair, error) {
+	return nil, nil
+}
+
+// The slot bookkeeping of SwapsRepo has nothing to do in a file.
+
+func (s *fileSink) MarkBlockProcessed(context.Context, int) error                  { return nil }
+func (s *fileSink) DeleteSwapsUsingTx(context.Context, string) error               { return nil }
+func (s *fileSink) FindMissingBlocks(context.Context, uint64) ([][]int, error)     { return nil, nil }
+func (s *fileSink) RecordSlot(context.Context, types.SlotLedgerEntry) error        { return nil }
+func (s *fileSink) UpsertSlotStatus(context.Context, types.SlotStatus) error       { return nil }
+func (s *fileSink) FindSlotStatus(context.Context, uint64) (string, error)         { return "", nil }
+func (s *fileSink) FinalizeSwaps(context.Context) (int64, error)                   { return 0, nil }
+func (s *fileSink) FindDeadSlots(context.Context, time.Duration) ([]uint64, error) { return nil, nil }
+func (s *fileSink) RollbackSlot(context.Context, uint64) error                     { return nil }
+func (s *fileSink) PruneSlotHistory(context.Context, uint64) error                 { return nil }
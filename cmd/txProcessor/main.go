@@ -7,14 +7,20 @@ import (
 	"blocsy/internal/solana"
 	"blocsy/internal/utils"
 	"context"
+	"expvar"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 )
 
+const defaultDrainTimeout = 30 * time.Second
+
 func main() {
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	utils.LoadEnvironment()
@@ -35,10 +41,7 @@ func main() {
 	websocketServer := websocket.NewWebSocketServer()
 	go websocketServer.Start()
 
-	go solanaTxHandler(ctx, c, pRepo, websocketServer)
-
-	<-ctx.Done()
-	log.Println("Shutting down tx processor...")
+	solanaTxHandler(ctx, c, pRepo, websocketServer)
 }
 
 func solanaTxHandler(ctx context.Context, c *cache.Cache, pRepo *db.TimescaleRepository, websocketServer *websocket.WebSocketServer) {
@@ -54,9 +57,66 @@ func solanaTxHandler(ctx context.Context, c *cache.Cache, pRepo *db.TimescaleRep
 	txHandler := solana.NewTxHandler(sh, solSvc, pRepo, pRepo, websocketServer)
 
 	queueHandler := solana.NewSolanaQueueHandler(txHandler, pRepo)
+	go solana.NewCommitmentReconciler(pRepo).Run(ctx)
+
+	if os.Getenv("SOL_GRPC_POOL_STATE") == "true" {
+		poolTransport, err := solana.NewPoolStateTransport()
+		if err != nil {
+			log.Fatalf("Failed to create pool state transport: %v", err)
+		}
+		go solana.NewPoolStateWriter(poolTransport, pRepo).Run(ctx)
+	}
+
+	expvar.Publish("queue", expvar.Func(func() any { return queueHandler.Stats() }))
+	expvar.Publish("parse", expvar.Func(func() any { return txHandler.ParseStats() }))
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			log.Printf("Serving metrics on %s/debug/vars", addr)
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				log.Printf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
 
 	log.Println("Listening for solana txs in rabbitMQ...")
-	defer log.Println("Stopped listening for solana txs in rabbitMQ...")
-	queueHandler.ListenToSolanaQueue(ctx)
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		queueHandler.ListenToSolanaQueue(ctx)
+	}()
 
+	select {
+	case <-ctx.Done():
+	case <-consumed:
+		log.Println("Queue consumer ended")
+	}
+	log.Println("Shutting down tx processor...")
+	drain(queueHandler, txHandler, tf, pf)
+}
+
+// drain shuts down in order: finish the deliveries already taken, then the
+// token and supply writes they started, then the token and pair lookups, and
+// only then close the queue. Everything shares one TX_DRAIN_TIMEOUT deadline.
+func drain(queueHandler *solana.QueueHandler, txHandler *solana.TxHandler, tf *solana.TokenFinder, pf *solana.PairsService) {
+	timeout := defaultDrainTimeout
+	if d, err := time.ParseDuration(os.Getenv("TX_DRAIN_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	started := time.Now()
+
+	requeued := queueHandler.Drain(ctx)
+	writesDone := txHandler.Drain(ctx)
+	pairsLeft := pf.StopPairProcessor(ctx)
+	tokensLeft := tf.StopTokenProcessor(ctx)
+	queueHandler.Close()
+
+	if requeued == 0 && writesDone && pairsLeft == 0 && tokensLeft == 0 {
+		log.Printf("Drained tx processor in %s", time.Since(started).Round(time.Millisecond))
+		return
+	}
+	log.Printf("Drain timed out after %s: %d deliveries requeued, token and supply writes finished: %v, %d pairs and %d tokens not looked up",
+		time.Since(started).Round(time.Millisecond), requeued, writesDone, pairsLeft, tokensLeft)
 }
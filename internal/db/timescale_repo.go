@@ -14,10 +14,17 @@ import (
 )
 
 const (
-	swapLogTable = "swap_log"
-	blocksTable  = "processed_block"
-	tokensTable  = "token"
-	pairsTable   = "pair"
+	swapLogTable       = "swap_log"
+	blocksTable        = "processed_block"
+	tokensTable        = "token"
+	pairsTable         = "pair"
+	slotStatusTable    = "slot_status"
+	supplyChangesTable = "token_supply_change"
+	poolStateTable     = "pool_state"
+	backfillRetryTable = "backfill_retry"
+	slotLedgerTable    = "slot_ledger"
+	slotPassesTable    = "slot_ledger_pass"
+	tokenEventsTable   = "token_event"
 )
 
 type TimescaleRepository struct {
@@ -42,36 +49,206 @@ func (repo *TimescaleRepository) MarkBlockProcessed(ctx context.Context, blockNu
 	return nil
 }
 
-func (repo *TimescaleRepository) FindMissingBlocks(ctx context.Context) ([][]int, error) {
-	var query = fmt.Sprintf(`SELECT DISTINCT "blockNumber" FROM "%s" ORDER BY "blockNumber";`, blocksTable)
+// RecordFailedBlock schedules blockNumber for another backfill attempt, further
+// out the more often it has failed.
+func (repo *TimescaleRepository) RecordFailedBlock(ctx context.Context, blockNumber int, reason string) error {
+	var query = fmt.Sprintf(`INSERT INTO "%[1]s" ("blockNumber", "attempts", "lastError", "nextAttemptAt", "updatedAt")
+VALUES ($1, 1, $2, (NOW() AT TIME ZONE 'utc') + INTERVAL '5 minutes', NOW() AT TIME ZONE 'utc')
+ON CONFLICT ("blockNumber") DO UPDATE SET
+    "attempts" = "%[1]s"."attempts" + 1,
+    "lastError" = EXCLUDED."lastError",
+    "nextAttemptAt" = (NOW() AT TIME ZONE 'utc') + LEAST("%[1]s"."attempts" + 1, 12) * INTERVAL '5 minutes',
+    "updatedAt" = EXCLUDED."updatedAt";`, backfillRetryTable)
+
+	if _, err := repo.db.ExecContext(ctx, query, blockNumber, reason); err != nil {
+		return fmt.Errorf("cannot record failed block: %w", err)
+	}
+
+	return nil
+}
 
-	var blockNumbers []int
-	if err := repo.db.SelectContext(ctx, &blockNumbers, query); err != nil {
-		return nil, fmt.Errorf("cannot get block numbers: %w", err)
+func (repo *TimescaleRepository) FindRetryBlocks(ctx context.Context, limit int, maxAttempts int) ([]int, error) {
+	var query = fmt.Sprintf(`SELECT "blockNumber" FROM "%s"
+WHERE "attempts" < $1 AND "nextAttemptAt" <= NOW() AT TIME ZONE 'utc'
+ORDER BY "blockNumber" LIMIT $2;`, backfillRetryTable)
+
+	var blocks []int
+	if err := repo.db.SelectContext(ctx, &blocks, query, maxAttempts, limit); err != nil {
+		return nil, fmt.Errorf("cannot get blocks to retry: %w", err)
 	}
 
-	if len(blockNumbers) == 0 {
-		return nil, nil
+	return blocks, nil
+}
+
+func (repo *TimescaleRepository) ClearFailedBlock(ctx context.Context, blockNumber int) error {
+	var query = fmt.Sprintf(`DELETE FROM "%s" WHERE "blockNumber" = $1;`, backfillRetryTable)
+
+	if _, err := repo.db.ExecContext(ctx, query, blockNumber); err != nil {
+		return fmt.Errorf("cannot clear failed block: %w", err)
 	}
 
-	if blockNumbers[0] == 0 {
-		blockNumbers = blockNumbers[1:]
+	return nil
+}
+
+// FindMissingBlocks returns the gaps between the slots known to be done within
+// window slots of the newest one. Failed ledger slots count as gaps; blocks
+// waiting in the retry table do not, RetryFailedBlocks owns them.
+func (repo *TimescaleRepository) FindMissingBlocks(ctx context.Context, window uint64) ([][]int, error) {
+	var query = fmt.Sprintf(`WITH bounds AS (
+    SELECT GREATEST(
+        (SELECT COALESCE(MAX("slot"), 0) FROM "%[1]s"),
+        (SELECT COALESCE(MAX("blockNumber"), 0) FROM "%[2]s")
+    ) - $1 AS "low"
+), known AS (
+    SELECT "slot" FROM "%[1]s" WHERE "status" <> '%[4]s' AND "slot" > (SELECT "low" FROM bounds)
+    UNION SELECT "blockNumber" FROM "%[2]s" WHERE "blockNumber" > (SELECT "low" FROM bounds)
+    UNION SELECT "blockNumber" FROM "%[3]s" WHERE "blockNumber" > (SELECT "low" FROM bounds)
+), ordered AS (
+    SELECT "slot", LEAD("slot") OVER (ORDER BY "slot") AS "next" FROM known WHERE "slot" > 0
+)
+SELECT "slot" + 1 AS "from", "next" - 1 AS "to" FROM ordered WHERE "next" - "slot" > 1 ORDER BY "slot";`,
+		slotLedgerTable, blocksTable, backfillRetryTable, types.SlotFailed)
+
+	var gaps []types.SlotRange
+	if err := repo.db.SelectContext(ctx, &gaps, query, int64(window)); err != nil {
+		return nil, fmt.Errorf("cannot find missing blocks: %w", err)
 	}
 
 	var missingBlockRanges [][]int
-	for i := 1; i < len(blockNumbers); i++ {
-		gap := blockNumbers[i] - blockNumbers[i-1] - 1
-		if gap > 0 {
-			missingBlockRanges = append(missingBlockRanges, []int{
-				blockNumbers[i-1] + 1,
-				blockNumbers[i] - 1,
-			})
-		}
+	for _, gap := range gaps {
+		missingBlockRanges = append(missingBlockRanges, []int{int(gap.From), int(gap.To)})
 	}
 
 	return missingBlockRanges, nil
 }
 
+//=============================================== Slot Ledger Functions  ===============================================
+
+// RecordSlot stores a pass over a piece of a slot and recomputes the slot's
+// ledger entry. A piece passed again replaces its earlier pass, so a
+// redelivered block is not counted twice. The pieces of a source add up, and
+// a source with a failed piece stays failed until that piece passes. The
+// entry takes the best source: ok before skipped before failed, then the most
+// transactions.
+func (repo *TimescaleRepository) RecordSlot(ctx context.Context, entry types.SlotLedgerEntry) error {
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Pieces of a slot are handled by several workers at once.
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(entry.Slot)); err != nil {
+		return fmt.Errorf("cannot lock slot %d: %w", entry.Slot, err)
+	}
+
+	var pass = fmt.Sprintf(`INSERT INTO "%s" ("slot", "source", "piece", "txCount", "swapCount", "processingMs", "status", "updatedAt")
+VALUES ($1, $2, $3, $4, $5, $6, $7, NOW() AT TIME ZONE 'utc')
+ON CONFLICT ("slot", "source", "piece") DO UPDATE SET
+    "txCount" = EXCLUDED."txCount",
+    "swapCount" = EXCLUDED."swapCount",
+    "processingMs" = EXCLUDED."processingMs",
+    "status" = EXCLUDED."status",
+    "updatedAt" = EXCLUDED."updatedAt";`, slotPassesTable)
+	if _, err = tx.ExecContext(ctx, pass, int64(entry.Slot), entry.Source, entry.Piece, entry.TxCount, entry.SwapCount, entry.ProcessingMs, entry.Status); err != nil {
+		return fmt.Errorf("cannot record pass over slot %d: %w", entry.Slot, err)
+	}
+
+	var query = fmt.Sprintf(`WITH sources AS (
+    SELECT "source", SUM("txCount") AS "txCount", SUM("swapCount") AS "swapCount", SUM("processingMs") AS "processingMs",
+        CASE WHEN BOOL_OR("status" = '%[3]s') THEN '%[3]s' WHEN BOOL_OR("status" = '%[4]s') THEN '%[4]s' ELSE '%[5]s' END AS "status"
+    FROM "%[2]s" WHERE "slot" = $1 GROUP BY "source"
+)
+INSERT INTO "%[1]s" ("slot", "source", "txCount", "swapCount", "processingMs", "status", "updatedAt")
+SELECT $1, "source", "txCount", "swapCount", "processingMs", "status", NOW() AT TIME ZONE 'utc' FROM sources
+ORDER BY "status" = '%[5]s' DESC, "status" = '%[4]s' DESC, "txCount" DESC LIMIT 1
+ON CONFLICT ("slot") DO UPDATE SET
+    "source" = EXCLUDED."source",
+    "txCount" = EXCLUDED."txCount",
+    "swapCount" = EXCLUDED."swapCount",
+    "processingMs" = EXCLUDED."processingMs",
+    "status" = EXCLUDED."status",
+    "updatedAt" = EXCLUDED."updatedAt";`, slotLedgerTable, slotPassesTable, types.SlotFailed, types.SlotSkipped, types.SlotOK)
+	if _, err = tx.ExecContext(ctx, query, int64(entry.Slot)); err != nil {
+		return fmt.Errorf("cannot record slot %d: %w", entry.Slot, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit slot %d: %w", entry.Slot, err)
+	}
+	return nil
+}
+
+// GetIngestSummary summarises the ledger over the window slots up to the newest
+// recorded one, with the most recent maxGaps gaps.
+func (repo *TimescaleRepository) GetIngestSummary(ctx context.Context, window uint64, maxGaps int) (*types.IngestSummary, error) {
+	var toSlot int64
+	if err := repo.db.GetContext(ctx, &toSlot, fmt.Sprintf(`SELECT COALESCE(MAX("slot"), 0) FROM "%s";`, slotLedgerTable)); err != nil {
+		return nil, fmt.Errorf("cannot get latest ledger slot: %w", err)
+	}
+	if toSlot == 0 {
+		return &types.IngestSummary{BySource: map[string]int64{}}, nil
+	}
+	fromSlot := max(toSlot-int64(window)+1, 1)
+
+	var counts struct {
+		Recorded        int64   `db:"recorded"`
+		OK              int64   `db:"ok"`
+		Skipped         int64   `db:"skipped"`
+		Failed          int64   `db:"failed"`
+		AvgProcessingMs float64 `db:"avgProcessingMs"`
+	}
+	var query = fmt.Sprintf(`SELECT
+    COUNT(*) AS "recorded",
+    COUNT(*) FILTER (WHERE "status" = '%[2]s') AS "ok",
+    COUNT(*) FILTER (WHERE "status" = '%[3]s') AS "skipped",
+    COUNT(*) FILTER (WHERE "status" = '%[4]s') AS "failed",
+    COALESCE(AVG("processingMs") FILTER (WHERE "status" = '%[2]s'), 0) AS "avgProcessingMs"
+FROM "%[1]s" WHERE "slot" BETWEEN $1 AND $2;`, slotLedgerTable, types.SlotOK, types.SlotSkipped, types.SlotFailed)
+	if err := repo.db.GetContext(ctx, &counts, query, fromSlot, toSlot); err != nil {
+		return nil, fmt.Errorf("cannot count ledger slots: %w", err)
+	}
+
+	var sources []struct {
+		Source string `db:"source"`
+		Slots  int64  `db:"slots"`
+	}
+	query = fmt.Sprintf(`SELECT "source", COUNT(*) AS "slots" FROM "%s" WHERE "slot" BETWEEN $1 AND $2 GROUP BY "source";`, slotLedgerTable)
+	if err := repo.db.SelectContext(ctx, &sources, query, fromSlot, toSlot); err != nil {
+		return nil, fmt.Errorf("cannot count ledger sources: %w", err)
+	}
+
+	var gaps []types.SlotRange
+	query = fmt.Sprintf(`WITH ordered AS (
+    SELECT "slot", LAG("slot", 1, $1 - 1) OVER (ORDER BY "slot") AS "prev"
+    FROM "%s" WHERE "slot" BETWEEN $1 AND $2
+)
+SELECT "prev" + 1 AS "from", "slot" - 1 AS "to" FROM ordered WHERE "slot" - "prev" > 1 ORDER BY "slot" DESC LIMIT $3;`, slotLedgerTable)
+	if err := repo.db.SelectContext(ctx, &gaps, query, fromSlot, toSlot, maxGaps); err != nil {
+		return nil, fmt.Errorf("cannot find ledger gaps: %w", err)
+	}
+
+	span := toSlot - fromSlot + 1
+	summary := &types.IngestSummary{
+		FromSlot:        uint64(fromSlot),
+		ToSlot:          uint64(toSlot),
+		Recorded:        counts.Recorded,
+		OK:              counts.OK,
+		Skipped:         counts.Skipped,
+		Failed:          counts.Failed,
+		Missing:         span - counts.Recorded,
+		Completeness:    float64(counts.OK+counts.Skipped) / float64(span),
+		BySource:        make(map[string]int64, len(sources)),
+		AvgProcessingMs: counts.AvgProcessingMs,
+		Gaps:            gaps,
+	}
+	for _, source := range sources {
+		summary.BySource[source.Source] = source.Slots
+	}
+
+	return summary, nil
+}
+
 //=============================================== Swap Table Functions  ================================================
 
 func (repo *TimescaleRepository) InsertSwaps(ctx context.Context, swaps []types.SwapLog) error {
@@ -91,6 +268,12 @@ func (repo *TimescaleRepository) InsertSwaps(ctx context.Context, swaps []types.
 		`"pair"`,
 		`"token"`,
 		`"processed"`,
+		`"finalized"`,
+		`"hop"`,
+		`"fee"`,
+		`"baseReserve"`,
+		`"quoteReserve"`,
+		`"creator"`,
 	}
 
 	query := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES`, swapLogTable, strings.Join(columns, ", "))
@@ -118,6 +301,12 @@ func (repo *TimescaleRepository) InsertSwaps(ctx context.Context, swaps []types.
 			swap.Pair,
 			swap.Token,
 			swap.Processed,
+			swap.Finalized,
+			swap.Hop,
+			swap.Fee,
+			swap.BaseReserve,
+			swap.QuoteReserve,
+			swap.Creator,
 		)
 	}
 
@@ -131,6 +320,71 @@ func (repo *TimescaleRepository) InsertSwaps(ctx context.Context, swaps []types.
 	return nil
 }
 
+func (repo *TimescaleRepository) InsertPoolStates(ctx context.Context, states []types.PoolState) error {
+	if len(states) == 0 {
+		return nil
+	}
+
+	columns := []string{
+		`"pool"`,
+		`"exchange"`,
+		`"slot"`,
+		`"timestamp"`,
+		`"baseMint"`,
+		`"quoteMint"`,
+		`"baseReserve"`,
+		`"quoteReserve"`,
+		`"liquidity"`,
+		`"price"`,
+	}
+
+	query := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES`, poolStateTable, strings.Join(columns, ", "))
+
+	valueStrings := []string{}
+	valueArgs := []interface{}{}
+
+	for i, state := range states {
+		base := i*len(columns) + 1
+		placeholders := []string{}
+		for j := 0; j < len(columns); j++ {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", base+j))
+		}
+		valueStrings = append(valueStrings, "("+strings.Join(placeholders, ", ")+")")
+
+		var baseReserve, quoteReserve, liquidity interface{}
+		if state.BaseReserve != nil {
+			baseReserve = strconv.FormatUint(*state.BaseReserve, 10)
+		}
+		if state.QuoteReserve != nil {
+			quoteReserve = strconv.FormatUint(*state.QuoteReserve, 10)
+		}
+		if state.Liquidity != "" {
+			liquidity = state.Liquidity
+		}
+
+		valueArgs = append(valueArgs,
+			state.Pool,
+			state.Exchange,
+			int64(state.Slot),
+			state.Timestamp.UTC(),
+			state.BaseMint,
+			state.QuoteMint,
+			baseReserve,
+			quoteReserve,
+			liquidity,
+			state.Price,
+		)
+	}
+
+	query += strings.Join(valueStrings, ", ") + ` ON CONFLICT (pool, slot, timestamp) DO NOTHING;`
+
+	if _, err := repo.db.ExecContext(ctx, query, valueArgs...); err != nil {
+		return fmt.Errorf("cannot insert pool states batch: %w", err)
+	}
+
+	return nil
+}
+
 func (repo *TimescaleRepository) DeleteSwapsUsingTx(ctx context.Context, signature string) error {
 	var query = fmt.Sprintf(`DELETE FROM "%s" WHERE id = '%s'`, swapLogTable, signature)
 	log.Println("Deleting swaps using tx: ", signature, query)
@@ -143,15 +397,21 @@ func (repo *TimescaleRepository) DeleteSwapsUsingTx(ctx context.Context, signatu
 
 }
 
-func (repo *TimescaleRepository) GetAllWalletSwaps(ctx context.Context, wallet string, limit int64, offset int64) ([]types.SwapLog, error) {
+func (repo *TimescaleRepository) GetAllWalletSwaps(ctx context.Context, wallet string, limit int64, offset int64, finalizedOnly bool) ([]types.SwapLog, error) {
+	var finalized string
+	if finalizedOnly {
+		finalized = "AND sl.finalized"
+	}
 
 	var query = fmt.Sprintf(`
 		SELECT sl.*, t.symbol AS "tokenSymbol"
 		FROM "%s" sl
 		JOIN token t ON sl.token = t.address
 		WHERE sl.wallet = $1
+		AND sl.hop = 0
+		%s
 		ORDER BY sl.timestamp DESC
-		LIMIT %d OFFSET %d;`, swapLogTable, limit, offset)
+		LIMIT %d OFFSET %d;`, swapLogTable, finalized, limit, offset)
 
 	var swaps []types.SwapLog
 
@@ -180,6 +440,7 @@ func (repo *TimescaleRepository) GetSwapsOnDate(ctx context.Context, wallet stri
 			token qt ON pr."quoteToken" = qt.address
 		WHERE 
 			sl.wallet = $1
+			AND sl.hop = 0
 			AND DATE(sl.timestamp) >= $2
 		ORDER BY sl.timestamp ASC;`, swapLogTable)
 
@@ -194,6 +455,7 @@ func (repo *TimescaleRepository) GetSwapsOnDate(ctx context.Context, wallet stri
 func (repo *TimescaleRepository) FindSwap(ctx context.Context, timestamp int64, token string, amount float64) (*types.SwapLog, error) {
 	var query = fmt.Sprintf(`SELECT * FROM "%s" 
 WHERE token = ?
+AND hop = 0
 AND ABS("amountOut"-?) <= 0.001
 AND ABS(EXTRACT(EPOCH FROM timestamp) - ?) <= 3 
 AND EXTRACT(EPOCH FROM timestamp) < ?
@@ -222,6 +484,7 @@ ORDER BY timestamp DESC LIMIT 1;`, swapLogTable)
 func (repo *TimescaleRepository) FindFirstTokenSwaps(ctx context.Context, token string) ([]types.SwapLog, error) {
 	var query = fmt.Sprintf(`SELECT * FROM "%s" 
 WHERE token = $1
+AND hop = 0
 ORDER BY timestamp ASC 
 LIMIT 100;`, swapLogTable)
 
@@ -263,7 +526,8 @@ func (repo *TimescaleRepository) FindWalletTokenHoldings(ctx context.Context, to
 		), 0) as totalTokens
 		FROM "%s"
 		WHERE token = $1
-		AND wallet = $2;
+		AND wallet = $2
+		AND hop = 0;
 	`, swapLogTable)
 
 	var totalTokens float64
@@ -287,6 +551,7 @@ func (repo *TimescaleRepository) FindTopTraders(ctx context.Context, token strin
 				END) as pnl
 			FROM "%s"
 			WHERE token = $1
+			AND hop = 0
 			GROUP BY wallet
 		)
 		SELECT wallet
@@ -418,7 +683,7 @@ FROM token_res;
 
 // =============================================== Token Table Functions  ================================================
 func (repo *TimescaleRepository) InsertToken(ctx context.Context, token types.Token) error {
-	var query = fmt.Sprintf(`INSERT INTO "%s" ("address", "name", "symbol", "decimals", "supply", "createdBlock", "createdTimestamp", "deployer", "metadata", "network") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`, tokensTable)
+	var query = fmt.Sprintf(`INSERT INTO "%s" ("address", "name", "symbol", "decimals", "supply", "createdBlock", "createdTimestamp", "deployer", "metadata", "network", "program", "extensions", "mintAuthority", "freezeAuthority", "mintAuthorityRevoked", "freezeAuthorityRevoked") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`, tokensTable)
 
 	var createdBlock interface{}
 	if token.CreatedBlock == 0 {
@@ -451,7 +716,7 @@ func (repo *TimescaleRepository) InsertToken(ctx context.Context, token types.To
 		metadata = *token.Metadata
 	}
 
-	if _, err := repo.db.ExecContext(ctx, query, token.Address, token.Name, token.Symbol, token.Decimals, token.Supply, createdBlock, token.CreatedTimestamp, deployer, metadata, token.Network); err != nil {
+	if _, err := repo.db.ExecContext(ctx, query, token.Address, token.Name, token.Symbol, token.Decimals, token.Supply, createdBlock, token.CreatedTimestamp, deployer, metadata, token.Network, token.Program, token.Extensions, token.MintAuthority, token.FreezeAuthority, token.MintAuthorityRevoked, token.FreezeAuthorityRevoked); err != nil {
 		return fmt.Errorf("cannot insert token: %w", err)
 	}
 
@@ -472,7 +737,13 @@ func (repo *TimescaleRepository) FindToken(ctx context.Context, address string)
 	"createdTimestamp",
 	deployer,
 	metadata,
-	network 
+	network,
+	program,
+	extensions,
+	"mintAuthority",
+	"freezeAuthority",
+	"mintAuthorityRevoked",
+	"freezeAuthorityRevoked"
 	FROM "%s" WHERE address = $1`, tokensTable)
 
 	var token types.Token
@@ -522,6 +793,223 @@ func (repo *TimescaleRepository) UpdateTokenSupply(ctx context.Context, address
 	return nil
 }
 
+// ApplyTokenSupplyChange updates the supply and records the change against
+// the slot it came from so it can be reversed if that slot is rolled back.
+// A change already recorded for the instruction is not applied again.
+func (repo *TimescaleRepository) ApplyTokenSupplyChange(ctx context.Context, block uint64, signature string, ixIndex int, innerIndex int, address string, changeAmount string, action string) error {
+	amount, err := strconv.ParseFloat(changeAmount, 64)
+	if err != nil {
+		return fmt.Errorf("invalid change amount: %w", err)
+	}
+
+	var query string
+	switch action {
+	case "mint":
+		query = fmt.Sprintf(`UPDATE "%s" SET supply = supply + $1::float8 WHERE address = $2`, tokensTable)
+	case "burn":
+		query = fmt.Sprintf(`UPDATE "%s" SET supply = supply - $1::float8 WHERE address = $2`, tokensTable)
+	default:
+		return fmt.Errorf("invalid action: %s, must be either 'mint' or 'burn'", action)
+	}
+
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var ledger = fmt.Sprintf(`INSERT INTO "%s" ("slot", "signature", "ixIndex", "innerIndex", "address", "amount", "action") VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT DO NOTHING`, supplyChangesTable)
+	res, err := tx.ExecContext(ctx, ledger, block, signature, ixIndex, innerIndex, address, amount, action)
+	if err != nil {
+		return fmt.Errorf("cannot record supply change: %w", err)
+	}
+	if inserted, _ := res.RowsAffected(); inserted == 0 {
+		return nil
+	}
+	if _, err = tx.ExecContext(ctx, query, amount, address); err != nil {
+		return fmt.Errorf("cannot update token supply: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit supply change: %w", err)
+	}
+	return nil
+}
+
+// InsertTokenEvents stores the events and applies mint and freeze authority
+// changes to their tokens. Events already stored are skipped, so a redelivered
+// block changes nothing. A revoked authority stays revoked.
+func (repo *TimescaleRepository) InsertTokenEvents(ctx context.Context, events []types.TokenEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var insert = fmt.Sprintf(`INSERT INTO "%s" ("signature", "ixIndex", "innerIndex", "slot", "timestamp", "program", "type", "mint", "account", "authority", "authorityType", "newAuthority", "delegate", "destination", "amount")
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+ON CONFLICT DO NOTHING`, tokenEventsTable)
+
+	var authorities = map[string]string{
+		types.AuthorityMintTokens:    fmt.Sprintf(`UPDATE "%s" SET "mintAuthority" = NULLIF($1, ''), "mintAuthorityRevoked" = ($1 = '') WHERE address = $2 AND NOT "mintAuthorityRevoked"`, tokensTable),
+		types.AuthorityFreezeAccount: fmt.Sprintf(`UPDATE "%s" SET "freezeAuthority" = NULLIF($1, ''), "freezeAuthorityRevoked" = ($1 = '') WHERE address = $2 AND NOT "freezeAuthorityRevoked"`, tokensTable),
+	}
+
+	for _, event := range events {
+		res, err := tx.ExecContext(ctx, insert, event.Signature, event.IxIndex, event.InnerIndex, int64(event.Slot), event.Timestamp,
+			event.Program, event.Type, event.Mint, event.Account, event.Authority, event.AuthorityType, event.NewAuthority, event.Delegate, event.Destination, event.Amount)
+		if err != nil {
+			return fmt.Errorf("cannot insert token event: %w", err)
+		}
+		if inserted, _ := res.RowsAffected(); inserted == 0 || event.Type != types.TokenEventSetAuthority {
+			continue
+		}
+
+		query, ok := authorities[event.AuthorityType]
+		if !ok {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, query, event.NewAuthority, event.Mint); err != nil {
+			return fmt.Errorf("cannot update token authority: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit token events: %w", err)
+	}
+	return nil
+}
+
+//=============================================== Slot Status Functions  ===============================================
+
+// UpsertSlotStatus never moves a slot out of a terminal (finalized or dead)
+// status, so late or duplicated updates are harmless.
+func (repo *TimescaleRepository) UpsertSlotStatus(ctx context.Context, status types.SlotStatus) error {
+	var query = fmt.Sprintf(`INSERT INTO "%[1]s" ("slot", "parent", "status") VALUES ($1, $2, $3)
+ON CONFLICT ("slot") DO UPDATE SET "status" = EXCLUDED."status", "parent" = GREATEST("%[1]s"."parent", EXCLUDED."parent"), "updatedAt" = NOW() AT TIME ZONE 'utc'
+WHERE "%[1]s"."status" NOT IN ('%[2]s', '%[3]s');`, slotStatusTable, types.CommitmentFinalized, types.SlotDead)
+
+	if _, err := repo.db.ExecContext(ctx, query, status.Slot, status.Parent, status.Status); err != nil {
+		return fmt.Errorf("cannot upsert slot status: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *TimescaleRepository) FindSlotStatus(ctx context.Context, slot uint64) (string, error) {
+	var query = fmt.Sprintf(`SELECT "status" FROM "%s" WHERE "slot" = $1`, slotStatusTable)
+
+	var status string
+	if err := repo.db.GetContext(ctx, &status, query, slot); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("cannot get slot status: %w", err)
+	}
+
+	return status, nil
+}
+
+// FinalizeSwaps flags every swap at or below the finalized root, except those
+// in dead slots, as final.
+func (repo *TimescaleRepository) FinalizeSwaps(ctx context.Context) (int64, error) {
+	var query = fmt.Sprintf(`UPDATE "%[1]s" SET "finalized" = TRUE
+WHERE NOT "finalized"
+AND "blockNumber" <= (SELECT COALESCE(MAX("slot"), 0) FROM "%[2]s" WHERE "status" = '%[3]s')
+AND "blockNumber" NOT IN (SELECT "slot" FROM "%[2]s" WHERE "status" = '%[4]s');`, swapLogTable, slotStatusTable, types.CommitmentFinalized, types.SlotDead)
+
+	res, err := repo.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("cannot finalize swaps: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (repo *TimescaleRepository) FindDeadSlots(ctx context.Context, since time.Duration) ([]uint64, error) {
+	var query = fmt.Sprintf(`SELECT "slot" FROM "%s" WHERE "status" = '%s' AND "updatedAt" > $1 ORDER BY "slot"`, slotStatusTable, types.SlotDead)
+
+	var slots []uint64
+	if err := repo.db.SelectContext(ctx, &slots, query, time.Now().UTC().Add(-since)); err != nil {
+		return nil, fmt.Errorf("cannot get dead slots: %w", err)
+	}
+
+	return slots, nil
+}
+
+// RollbackSlot removes the swaps, tokens, supply changes and token events
+// written for a slot that never finalized. Mint and freeze authorities the
+// events changed go back to the latest change left in other slots, or to the
+// signer of the first rolled back change, with their revoked flags.
+func (repo *TimescaleRepository) RollbackSlot(ctx context.Context, slot uint64) error {
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		fmt.Sprintf(`DELETE FROM "%s" WHERE "blockNumber" = $1 AND NOT "finalized"`, swapLogTable),
+		fmt.Sprintf(`UPDATE "%s" t SET supply = t.supply - c.delta
+FROM (
+	SELECT "address", SUM(CASE WHEN "action" = 'mint' THEN "amount" ELSE -"amount" END) AS delta
+	FROM "%s" WHERE "slot" = $1 GROUP BY "address"
+) c
+WHERE t.address = c.address`, tokensTable, supplyChangesTable),
+		fmt.Sprintf(`DELETE FROM "%s" WHERE "slot" = $1`, supplyChangesTable),
+		fmt.Sprintf(`DELETE FROM "%s" WHERE "createdBlock" = $1`, tokensTable),
+		restoreAuthority(types.AuthorityMintTokens, "mintAuthority"),
+		restoreAuthority(types.AuthorityFreezeAccount, "freezeAuthority"),
+		fmt.Sprintf(`DELETE FROM "%s" WHERE "slot" = $1`, tokenEventsTable),
+	}
+
+	for _, query := range queries {
+		if _, err = tx.ExecContext(ctx, query, slot); err != nil {
+			return fmt.Errorf("cannot roll back slot %d: %w", slot, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit rollback of slot %d: %w", slot, err)
+	}
+	return nil
+}
+
+// restoreAuthority builds the query RollbackSlot uses to undo the changes to
+// one authority column made by the events of slot $1.
+func restoreAuthority(authorityType string, column string) string {
+	return fmt.Sprintf(`UPDATE "%[1]s" t SET "%[4]s" = NULLIF(r."authority", ''), "%[4]sRevoked" = (r."authority" = '')
+FROM (
+	SELECT DISTINCT ON (e."mint") e."mint", COALESCE((
+		SELECT k."newAuthority" FROM "%[2]s" k
+		WHERE k."mint" = e."mint" AND k."type" = '%[3]s' AND k."authorityType" = '%[5]s' AND k."slot" <> $1
+		ORDER BY k."slot" DESC, k."ixIndex" DESC, k."innerIndex" DESC LIMIT 1
+	), e."authority") AS "authority"
+	FROM "%[2]s" e WHERE e."slot" = $1 AND e."type" = '%[3]s' AND e."authorityType" = '%[5]s'
+	ORDER BY e."mint", e."ixIndex", e."innerIndex"
+) r
+WHERE t.address = r."mint"`, tokensTable, tokenEventsTable, types.TokenEventSetAuthority, column, authorityType)
+}
+
+// PruneSlotHistory drops slot statuses more than keepSlots behind the
+// finalized root. Supply changes are kept: they stop a replay of an old block
+// from applying its mints and burns twice.
+func (repo *TimescaleRepository) PruneSlotHistory(ctx context.Context, keepSlots uint64) error {
+	var root = fmt.Sprintf(`(SELECT COALESCE(MAX("slot"), 0) FROM "%s" WHERE "status" = '%s')`, slotStatusTable, types.CommitmentFinalized)
+
+	var statuses = fmt.Sprintf(`DELETE FROM "%s" WHERE "slot" + $1 < %s`, slotStatusTable, root)
+	if _, err := repo.db.ExecContext(ctx, statuses, keepSlots); err != nil {
+		return fmt.Errorf("cannot prune slot statuses: %w", err)
+	}
+
+	return nil
+}
+
 //=============================================== Pair Table Functions  ================================================
 
 func (repo *TimescaleRepository) InsertPair(ctx context.Context, pair types.Pair) error {
@@ -576,6 +1064,12 @@ func CreateTokenTable(ctx context.Context, db *sqlx.DB) {
     "deployer" TEXT,
     "metadata" TEXT,
     "network" TEXT NOT NULL,
+    "program" TEXT NOT NULL DEFAULT '',
+    "extensions" TEXT NOT NULL DEFAULT '',
+    "mintAuthority" TEXT,
+    "freezeAuthority" TEXT,
+    "mintAuthorityRevoked" BOOLEAN NOT NULL DEFAULT FALSE,
+    "freezeAuthorityRevoked" BOOLEAN NOT NULL DEFAULT FALSE,
     PRIMARY KEY ("address")
 );`, tokensTable)
 
@@ -583,6 +1077,21 @@ func CreateTokenTable(ctx context.Context, db *sqlx.DB) {
 		log.Fatalf("Error creating table: %v", err)
 	}
 
+	// Tables created before Token-2022 support and authority tracking lack
+	// the columns.
+	migrations := []string{
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "program" TEXT NOT NULL DEFAULT '';`, tokensTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "extensions" TEXT NOT NULL DEFAULT '';`, tokensTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "mintAuthority" TEXT;`, tokensTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "freezeAuthority" TEXT;`, tokensTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "mintAuthorityRevoked" BOOLEAN NOT NULL DEFAULT FALSE;`, tokensTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "freezeAuthorityRevoked" BOOLEAN NOT NULL DEFAULT FALSE;`, tokensTable),
+	}
+	for _, migration := range migrations {
+		if _, err := db.ExecContext(ctx, migration); err != nil {
+			log.Fatalf("Error migrating table: %v", err)
+		}
+	}
 }
 
 func CreatePairTable(ctx context.Context, db *sqlx.DB) {
@@ -629,6 +1138,12 @@ func CreateSwapsTable(ctx context.Context, db *sqlx.DB) {
     "pair" TEXT NOT NULL,
     "token" TEXT NOT NULL,
     "processed" BOOLEAN DEFAULT FALSE NOT NULL,
+    "finalized" BOOLEAN DEFAULT FALSE NOT NULL,
+    "hop" INT DEFAULT 0 NOT NULL,
+    "fee" DOUBLE PRECISION NOT NULL DEFAULT 0,
+    "baseReserve" NUMERIC,
+    "quoteReserve" NUMERIC,
+    "creator" TEXT NOT NULL DEFAULT '',
     PRIMARY KEY (id,pair,action,"amountIn","amountOut","blockNumber",timestamp)
 );`, swapLogTable)
 
@@ -638,6 +1153,23 @@ func CreateSwapsTable(ctx context.Context, db *sqlx.DB) {
 
 	ConvertHyperTable(ctx, db, swapLogTable)
 
+	// Tables created before finality tracking, route legs and trade events
+	// lack the columns.
+	migrations := []string{
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "finalized" BOOLEAN DEFAULT FALSE NOT NULL;`, swapLogTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "hop" INT DEFAULT 0 NOT NULL;`, swapLogTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "fee" DOUBLE PRECISION NOT NULL DEFAULT 0;`, swapLogTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "baseReserve" NUMERIC;`, swapLogTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "quoteReserve" NUMERIC;`, swapLogTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "creator" TEXT NOT NULL DEFAULT '';`, swapLogTable),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%[1]s_unfinalized" ON "%[1]s" ("blockNumber") WHERE NOT "finalized";`, swapLogTable),
+	}
+	for _, migration := range migrations {
+		if _, err := db.ExecContext(ctx, migration); err != nil {
+			log.Fatalf("Error migrating table: %v", err)
+		}
+	}
+
 	// Create indexes on the table so that queries are faster
 	//indexes := []string{
 	//	`("timestamp" DESC)`,
@@ -657,6 +1189,165 @@ func CreateSwapsTable(ctx context.Context, db *sqlx.DB) {
 	//}
 }
 
+func CreateSlotStatusTable(ctx context.Context, db *sqlx.DB) {
+	var query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+    "slot" BIGINT NOT NULL,
+    "parent" BIGINT NOT NULL DEFAULT 0,
+    "status" TEXT NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'utc'),
+    PRIMARY KEY ("slot")
+);`, slotStatusTable)
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		log.Fatalf("Error creating table: %v", err)
+	}
+
+}
+
+func CreateSupplyChangesTable(ctx context.Context, db *sqlx.DB) {
+	var query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%[1]s" (
+    "slot" BIGINT NOT NULL,
+    "signature" TEXT,
+    "ixIndex" INT,
+    "innerIndex" INT,
+    "address" TEXT NOT NULL,
+    "amount" DOUBLE PRECISION NOT NULL,
+    "action" TEXT NOT NULL
+);`, supplyChangesTable)
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		log.Fatalf("Error creating table: %v", err)
+	}
+
+	// Changes recorded before they were keyed by instruction have no
+	// signature, and never conflict.
+	statements := []string{
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "signature" TEXT;`, supplyChangesTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "ixIndex" INT;`, supplyChangesTable),
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "innerIndex" INT;`, supplyChangesTable),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%[1]s_slot" ON "%[1]s" ("slot");`, supplyChangesTable),
+		fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS "%[1]s_instruction" ON "%[1]s" ("signature", "ixIndex", "innerIndex", "action");`, supplyChangesTable),
+	}
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			log.Printf("Error migrating table: %v", err)
+		}
+	}
+
+}
+
+func CreatePoolStateTable(ctx context.Context, db *sqlx.DB) {
+	var query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%[1]s" (
+    "pool" TEXT NOT NULL,
+    "exchange" TEXT NOT NULL,
+    "slot" BIGINT NOT NULL,
+    "timestamp" TIMESTAMP NOT NULL,
+    "baseMint" TEXT NOT NULL,
+    "quoteMint" TEXT NOT NULL,
+    "baseReserve" NUMERIC,
+    "quoteReserve" NUMERIC,
+    "liquidity" NUMERIC,
+    "price" DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (pool, slot, timestamp)
+);`, poolStateTable)
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		log.Fatalf("Error creating table: %v", err)
+	}
+
+	ConvertHyperTable(ctx, db, poolStateTable)
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%[1]s_pool_timestamp" ON "%[1]s" ("pool", "timestamp" DESC);`, poolStateTable)); err != nil {
+		log.Printf("Error creating index: %v", err)
+	}
+
+}
+
+func CreateBackfillRetryTable(ctx context.Context, db *sqlx.DB) {
+	var query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+    "blockNumber" BIGINT NOT NULL,
+    "attempts" INT NOT NULL DEFAULT 0,
+    "lastError" TEXT NOT NULL DEFAULT '',
+    "nextAttemptAt" TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'utc'),
+    PRIMARY KEY ("blockNumber")
+);`, backfillRetryTable)
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		log.Fatalf("Error creating table: %v", err)
+	}
+
+}
+
+func CreateSlotLedgerTable(ctx context.Context, db *sqlx.DB) {
+	var query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+    "slot" BIGINT NOT NULL,
+    "source" TEXT NOT NULL,
+    "txCount" INT NOT NULL DEFAULT 0,
+    "swapCount" INT NOT NULL DEFAULT 0,
+    "processingMs" BIGINT NOT NULL DEFAULT 0,
+    "status" TEXT NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'utc'),
+    PRIMARY KEY ("slot")
+);`, slotLedgerTable)
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		log.Fatalf("Error creating table: %v", err)
+	}
+
+	query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+    "slot" BIGINT NOT NULL,
+    "source" TEXT NOT NULL,
+    "piece" TEXT NOT NULL DEFAULT '',
+    "txCount" INT NOT NULL DEFAULT 0,
+    "swapCount" INT NOT NULL DEFAULT 0,
+    "processingMs" BIGINT NOT NULL DEFAULT 0,
+    "status" TEXT NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'utc'),
+    PRIMARY KEY ("slot", "source", "piece")
+);`, slotPassesTable)
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		log.Fatalf("Error creating table: %v", err)
+	}
+
+}
+
+func CreateTokenEventsTable(ctx context.Context, db *sqlx.DB) {
+	var query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+    "signature" TEXT NOT NULL,
+    "ixIndex" INT NOT NULL,
+    "innerIndex" INT NOT NULL,
+    "slot" BIGINT NOT NULL,
+    "timestamp" TIMESTAMP NOT NULL,
+    "program" TEXT NOT NULL,
+    "type" TEXT NOT NULL,
+    "mint" TEXT NOT NULL,
+    "account" TEXT NOT NULL,
+    "authority" TEXT NOT NULL,
+    "authorityType" TEXT NOT NULL DEFAULT '',
+    "newAuthority" TEXT NOT NULL DEFAULT '',
+    "delegate" TEXT NOT NULL DEFAULT '',
+    "destination" TEXT NOT NULL DEFAULT '',
+    "amount" TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY ("signature", "ixIndex", "innerIndex")
+);`, tokenEventsTable)
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		log.Fatalf("Error creating table: %v", err)
+	}
+
+	indexes := []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%[1]s_mint_slot" ON "%[1]s" ("mint", "slot" DESC);`, tokenEventsTable),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%[1]s_slot" ON "%[1]s" ("slot");`, tokenEventsTable),
+	}
+	for _, index := range indexes {
+		if _, err := db.ExecContext(ctx, index); err != nil {
+			log.Printf("Error creating index: %v", err)
+		}
+	}
+}
+
 func ConvertHyperTable(ctx context.Context, db *sqlx.DB, tableName string) {
 	query := fmt.Sprintf(`SELECT create_hypertable('%s', 'timestamp');`, tableName)
 
@@ -0,0 +1,234 @@
+package anchor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/mr-tron/base58"
+	"math"
+	"math/big"
+)
+
+var errShort = errors.New("unexpected end of data")
+
+var numberSizes = map[string]int{
+	"u8": 1, "i8": 1, "u16": 2, "i16": 2, "u32": 4, "i32": 4, "u64": 8, "i64": 8,
+	"f32": 4, "f64": 8,
+}
+
+// Values are decoded fields by name. Unsigned integers decode to uint64,
+// signed ones to int64, 128-bit ones to *big.Int and public keys to base58
+// strings. Options are nil when absent, vecs and arrays []any, structs Values,
+// and enums the variant name, or a Values of the variant name to its fields.
+type Values map[string]any
+
+func (v Values) Uint64(name string) (uint64, bool) {
+	n, ok := v[name].(uint64)
+	return n, ok
+}
+
+func (v Values) Int64(name string) (int64, bool) {
+	n, ok := v[name].(int64)
+	return n, ok
+}
+
+func (v Values) Bool(name string) (bool, bool) {
+	b, ok := v[name].(bool)
+	return b, ok
+}
+
+// String is a string or public key field.
+func (v Values) String(name string) (string, bool) {
+	s, ok := v[name].(string)
+	return s, ok
+}
+
+type reader struct {
+	data  []byte
+	pos   int
+	types map[string]*TypeDef
+}
+
+func (r *reader) take(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errShort
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) fields(fields []Field) (Values, error) {
+	return r.fieldsUntil(fields, false)
+}
+
+// fieldsUntil is fields, stopping without error when atEnd and the data
+// ends between two fields. The fields left out are absent from the values.
+func (r *reader) fieldsUntil(fields []Field, atEnd bool) (Values, error) {
+	values := make(Values, len(fields))
+	for i, f := range fields {
+		if atEnd && i > 0 && r.pos == len(r.data) {
+			break
+		}
+		v, err := r.value(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		name := camelCase(f.Name)
+		if name == "" {
+			name = fmt.Sprint(i)
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+func (r *reader) value(t Type) (any, error) {
+	switch {
+	case t.Option:
+		var present bool
+		if t.COption {
+			b, err := r.take(4)
+			if err != nil {
+				return nil, err
+			}
+			present = binary.LittleEndian.Uint32(b) != 0
+		} else {
+			b, err := r.take(1)
+			if err != nil {
+				return nil, err
+			}
+			present = b[0] != 0
+		}
+		if !present {
+			return nil, nil
+		}
+		return r.value(*t.Elem)
+
+	case t.Vec:
+		b, err := r.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return r.list(*t.Elem, int(binary.LittleEndian.Uint32(b)))
+
+	case t.Array > 0:
+		return r.list(*t.Elem, t.Array)
+
+	case t.Defined != "":
+		def, ok := r.types[t.Defined]
+		if !ok {
+			return nil, fmt.Errorf("undefined type %s", t.Defined)
+		}
+		return r.defined(def)
+	}
+
+	return r.primitive(t.Primitive)
+}
+
+func (r *reader) list(elem Type, n int) ([]any, error) {
+	// Every element takes at least a byte, which bounds a corrupt length.
+	if n > len(r.data)-r.pos {
+		return nil, errShort
+	}
+	list := make([]any, n)
+	for i := range list {
+		v, err := r.value(elem)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+func (r *reader) defined(def *TypeDef) (any, error) {
+	if def.Type.Kind != "enum" {
+		return r.fields(def.Type.Fields)
+	}
+
+	b, err := r.take(1)
+	if err != nil {
+		return nil, err
+	}
+	if int(b[0]) >= len(def.Type.Variants) {
+		return nil, fmt.Errorf("%s has no variant %d", def.Name, b[0])
+	}
+	variant := def.Type.Variants[b[0]]
+	if len(variant.Fields) == 0 {
+		return variant.Name, nil
+	}
+	fields, err := r.fields(variant.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return Values{variant.Name: fields}, nil
+}
+
+func (r *reader) primitive(name string) (any, error) {
+	if size, ok := numberSizes[name]; ok {
+		b, err := r.take(size)
+		if err != nil {
+			return nil, err
+		}
+		var u uint64
+		for i := size - 1; i >= 0; i-- {
+			u = u<<8 | uint64(b[i])
+		}
+		switch name[0] {
+		case 'u':
+			return u, nil
+		case 'i':
+			// Sign extend.
+			shift := 64 - 8*size
+			return int64(u<<shift) >> shift, nil
+		}
+		if size == 4 {
+			return float64(math.Float32frombits(uint32(u))), nil
+		}
+		return math.Float64frombits(u), nil
+	}
+
+	switch name {
+	case "bool":
+		b, err := r.take(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "u128", "i128":
+		b, err := r.take(16)
+		if err != nil {
+			return nil, err
+		}
+		be := make([]byte, 16)
+		for i := range b {
+			be[15-i] = b[i]
+		}
+		n := new(big.Int).SetBytes(be)
+		if name == "i128" && be[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 128))
+		}
+		return n, nil
+	case "pubkey", "publicKey":
+		b, err := r.take(32)
+		if err != nil {
+			return nil, err
+		}
+		return base58.Encode(b), nil
+	case "string", "bytes":
+		b, err := r.take(4)
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.take(int(binary.LittleEndian.Uint32(b)))
+		if err != nil {
+			return nil, err
+		}
+		if name == "string" {
+			return string(data), nil
+		}
+		return append([]byte(nil), data...), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", name)
+}
@@ -0,0 +1,203 @@
+package anchor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// IDL is an Anchor IDL, in the 0.30 format or the legacy one. Only what the
+// decoder needs is read.
+type IDL struct {
+	Address  string `json:"address"`
+	Name     string `json:"name"` // legacy
+	Metadata struct {
+		Name    string `json:"name"`
+		Address string `json:"address"` // legacy
+	} `json:"metadata"`
+	Instructions []InstructionDef `json:"instructions"`
+	Events       []EventDef       `json:"events"`
+	Types        []TypeDef        `json:"types"`
+}
+
+type InstructionDef struct {
+	Name          string       `json:"name"`
+	Discriminator []int        `json:"discriminator"`
+	Accounts      []AccountDef `json:"accounts"`
+	Args          []Field      `json:"args"`
+}
+
+// AccountDef is an instruction account, or a group of them that Anchor
+// flattens in order.
+type AccountDef struct {
+	Name     string       `json:"name"`
+	Accounts []AccountDef `json:"accounts"`
+}
+
+// EventDef is an event. 0.30 IDLs keep its fields in Types under the same
+// name, legacy ones inline.
+type EventDef struct {
+	Name          string  `json:"name"`
+	Discriminator []int   `json:"discriminator"`
+	Fields        []Field `json:"fields"`
+}
+
+type TypeDef struct {
+	Name string `json:"name"`
+	Type struct {
+		Kind     string    `json:"kind"` // struct or enum
+		Fields   []Field   `json:"fields"`
+		Variants []Variant `json:"variants"`
+	} `json:"type"`
+}
+
+type Variant struct {
+	Name   string  `json:"name"`
+	Fields []Field `json:"fields"`
+}
+
+// Field is a named field, or an element of a tuple when Name is empty.
+type Field struct {
+	Name string
+	Type Type
+}
+
+func (f *Field) UnmarshalJSON(data []byte) error {
+	var named struct {
+		Name *string         `json:"name"`
+		Type json.RawMessage `json:"type"`
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &named); err == nil && named.Name != nil && named.Type != nil {
+			f.Name = *named.Name
+			return json.Unmarshal(named.Type, &f.Type)
+		}
+	}
+	return json.Unmarshal(data, &f.Type)
+}
+
+// Type is a primitive like u64 or pubkey, a container of Elem, or a Defined
+// type.
+type Type struct {
+	Primitive string
+	Option    bool // option or coption
+	COption   bool
+	Vec       bool
+	Array     int
+	Elem      *Type
+	Defined   string
+}
+
+func (t *Type) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.Primitive); err == nil {
+		return nil
+	}
+
+	var compound struct {
+		Option  *Type             `json:"option"`
+		COption *Type             `json:"coption"`
+		Vec     *Type             `json:"vec"`
+		Array   []json.RawMessage `json:"array"`
+		Defined json.RawMessage   `json:"defined"`
+	}
+	if err := json.Unmarshal(data, &compound); err != nil {
+		return err
+	}
+
+	switch {
+	case compound.Option != nil:
+		t.Option, t.Elem = true, compound.Option
+	case compound.COption != nil:
+		t.Option, t.COption, t.Elem = true, true, compound.COption
+	case compound.Vec != nil:
+		t.Vec, t.Elem = true, compound.Vec
+	case len(compound.Array) == 2:
+		t.Elem = &Type{}
+		if err := json.Unmarshal(compound.Array[0], t.Elem); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(compound.Array[1], &t.Array); err != nil {
+			return fmt.Errorf("array length: %w", err)
+		}
+	case compound.Defined != nil:
+		// "Name" in legacy IDLs, {"name": "Name"} in 0.30 ones.
+		if err := json.Unmarshal(compound.Defined, &t.Defined); err != nil {
+			var defined struct {
+				Name string `json:"name"`
+			}
+			if err = json.Unmarshal(compound.Defined, &defined); err != nil {
+				return err
+			}
+			t.Defined = defined.Name
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", data)
+	}
+	return nil
+}
+
+// ProgramID is the address the IDL was published for.
+func (idl *IDL) ProgramID() string {
+	if idl.Address != "" {
+		return idl.Address
+	}
+	return idl.Metadata.Address
+}
+
+func (idl *IDL) ProgramName() string {
+	if idl.Metadata.Name != "" {
+		return idl.Metadata.Name
+	}
+	return idl.Name
+}
+
+// discriminator is the one in the IDL, or Anchor's default: the first 8
+// bytes of sha256 over "<namespace>:<name>".
+func discriminator(given []int, namespace string, name string) ([8]byte, error) {
+	var d [8]byte
+	if len(given) == 0 {
+		sum := sha256.Sum256([]byte(namespace + ":" + name))
+		copy(d[:], sum[:8])
+		return d, nil
+	}
+	if len(given) != 8 {
+		return d, fmt.Errorf("%s discriminator of %d bytes", name, len(given))
+	}
+	for i, b := range given {
+		d[i] = byte(b)
+	}
+	return d, nil
+}
+
+// camelCase turns the snake_case names of 0.30 IDLs into the camelCase of
+// legacy ones, so handlers use one spelling.
+func camelCase(name string) string {
+	if !strings.Contains(name, "_") {
+		return name
+	}
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// snakeCase is the instruction name Anchor hashes into its discriminator.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,193 @@
+package anchor
+
+import (
+	"blocsy/internal/types"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// eventIxTag prefixes the self-CPI instruction that emit_cpi! logs an event
+// with.
+var eventIxTag = []byte{0xe4, 0x45, 0xa5, 0x2e, 0x51, 0xcb, 0x9a, 0x1d}
+
+// Program decodes the instructions and events of one program by the
+// discriminators in its IDL.
+type Program struct {
+	ID   string
+	Name string
+
+	instructions map[[8]byte]*instruction
+	events       map[[8]byte]*event
+	types        map[string]*TypeDef
+}
+
+type instruction struct {
+	name     string
+	accounts []string
+	args     []Field
+}
+
+type event struct {
+	name   string
+	fields []Field
+}
+
+// Instruction is a decoded instruction. Accounts are keyed by their IDL name,
+// in camelCase; accounts the transaction didn't pass are absent.
+type Instruction struct {
+	Program  string
+	Name     string
+	Args     Values
+	Accounts map[string]string
+}
+
+// Account is the key passed as the named account.
+func (ix *Instruction) Account(name string) (string, error) {
+	if key, ok := ix.Accounts[name]; ok {
+		return key, nil
+	}
+	return "", types.NewDecodeError(ix.Program, types.ErrMissingAccounts, "%s has no %s account", ix.Name, name)
+}
+
+// Event is a decoded event.
+type Event struct {
+	Program string
+	Name    string
+	Fields  Values
+}
+
+// NewProgram indexes idl for decoding.
+func NewProgram(idl *IDL) (*Program, error) {
+	p := &Program{
+		ID:           idl.ProgramID(),
+		Name:         idl.ProgramName(),
+		instructions: make(map[[8]byte]*instruction, len(idl.Instructions)),
+		events:       make(map[[8]byte]*event, len(idl.Events)),
+		types:        make(map[string]*TypeDef, len(idl.Types)),
+	}
+	if p.ID == "" {
+		return nil, fmt.Errorf("IDL %s has no address", p.Name)
+	}
+
+	for i := range idl.Types {
+		p.types[idl.Types[i].Name] = &idl.Types[i]
+	}
+
+	for _, def := range idl.Instructions {
+		d, err := discriminator(def.Discriminator, "global", snakeCase(def.Name))
+		if err != nil {
+			return nil, err
+		}
+		p.instructions[d] = &instruction{
+			name:     camelCase(def.Name),
+			accounts: flattenAccounts(def.Accounts, nil),
+			args:     def.Args,
+		}
+	}
+
+	for _, def := range idl.Events {
+		d, err := discriminator(def.Discriminator, "event", def.Name)
+		if err != nil {
+			return nil, err
+		}
+		fields := def.Fields
+		if len(fields) == 0 {
+			if t, ok := p.types[def.Name]; ok {
+				fields = t.Type.Fields
+			}
+		}
+		p.events[d] = &event{name: def.Name, fields: fields}
+	}
+
+	return p, nil
+}
+
+func flattenAccounts(defs []AccountDef, names []string) []string {
+	for _, def := range defs {
+		if len(def.Accounts) > 0 {
+			names = flattenAccounts(def.Accounts, names)
+			continue
+		}
+		names = append(names, camelCase(def.Name))
+	}
+	return names
+}
+
+// DecodeInstruction decodes the args of the instruction data, and names the
+// accounts it was passed.
+func (p *Program) DecodeInstruction(data []byte, accountKeys []string, accounts []int) (*Instruction, error) {
+	if len(data) < 8 {
+		return nil, types.NewDecodeError(p.ID, types.ErrShortData, "instruction of %d bytes", len(data))
+	}
+	def, ok := p.instructions[[8]byte(data[:8])]
+	if !ok {
+		return nil, types.NewDecodeError(p.ID, types.ErrUnknownInstruction, "discriminator %x", data[:8])
+	}
+
+	r := &reader{data: data[8:], types: p.types}
+	args, err := r.fields(def.args)
+	if err != nil {
+		return nil, types.NewDecodeError(p.ID, types.ErrShortData, "%s: %v", def.name, err)
+	}
+
+	ix := &Instruction{Program: p.ID, Name: def.name, Args: args, Accounts: make(map[string]string, len(def.accounts))}
+	for i, name := range def.accounts {
+		if i >= len(accounts) {
+			break
+		}
+		if accounts[i] < 0 || accounts[i] >= len(accountKeys) {
+			return nil, types.NewDecodeError(p.ID, types.ErrAccountIndex, "%s: %d of %d keys", name, accounts[i], len(accountKeys))
+		}
+		ix.Accounts[name] = accountKeys[accounts[i]]
+	}
+	return ix, nil
+}
+
+// DecodeEvent decodes an event from the data of a "Program data:" log, or of
+// the self-CPI instruction of emit_cpi!. Fields appended by later versions of
+// the program are ignored, and those an earlier version did not log yet are
+// absent.
+func (p *Program) DecodeEvent(data []byte) (*Event, error) {
+	data = bytes.TrimPrefix(data, eventIxTag)
+	if len(data) < 8 {
+		return nil, types.NewDecodeError(p.ID, types.ErrNoEvent, "event of %d bytes", len(data))
+	}
+	def, ok := p.events[[8]byte(data[:8])]
+	if !ok {
+		return nil, types.NewDecodeError(p.ID, types.ErrNoEvent, "discriminator %x", data[:8])
+	}
+
+	r := &reader{data: data[8:], types: p.types}
+	fields, err := r.fieldsUntil(def.fields, true)
+	if err != nil {
+		return nil, types.NewDecodeError(p.ID, types.ErrShortData, "%s: %v", def.name, err)
+	}
+	return &Event{Program: p.ID, Name: def.name, Fields: fields}, nil
+}
+
+// DecodeLogEvents decodes the "Program data:" logs the program wrote itself,
+// at any depth, skipping those that are not its events.
+func (p *Program) DecodeLogEvents(logs []types.LogDetails) []*Event {
+	var events []*Event
+	for _, logDetail := range logs {
+		if logDetail.Program == p.ID {
+			for _, line := range logDetail.Logs {
+				encoded, found := strings.CutPrefix(line, "Program data: ")
+				if !found {
+					continue
+				}
+				data, err := base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					continue
+				}
+				if e, err := p.DecodeEvent(data); err == nil {
+					events = append(events, e)
+				}
+			}
+		}
+		events = append(events, p.DecodeLogEvents(logDetail.SubLogs)...)
+	}
+	return events
+}
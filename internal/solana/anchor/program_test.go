@@ -0,0 +1,159 @@
+package anchor
+
+import (
+	"blocsy/internal/types"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"github.com/mr-tron/base58"
+	"math/big"
+	"testing"
+)
+
+const pumpProgram = "6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P"
+
+func TestDecodePumpTradeEvent(t *testing.T) {
+	p := Default().Program(pumpProgram)
+	if p == nil {
+		t.Fatal("pump IDL not embedded")
+	}
+
+	mint, _ := base58.Decode("So11111111111111111111111111111111111111112")
+	d, _ := discriminator(nil, "event", "TradeEvent")
+	data := append(append([]byte{}, eventIxTag...), d[:]...)
+	data = append(data, mint...)
+	data = binary.LittleEndian.AppendUint64(data, 1_500_000_000)
+	data = binary.LittleEndian.AppendUint64(data, 42)
+	data = append(data, 1)
+	data = append(data, mint...)
+	data = binary.LittleEndian.AppendUint64(data, uint64(1<<63|5))
+	data = binary.LittleEndian.AppendUint64(data, 7)
+	data = binary.LittleEndian.AppendUint64(data, 8)
+	data = append(data, 0xff, 0xff) // fields of a later program version
+
+	e, err := p.DecodeEvent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Name != "TradeEvent" {
+		t.Fatalf("decoded %s", e.Name)
+	}
+	if v, _ := e.Fields.Uint64("solAmount"); v != 1_500_000_000 {
+		t.Fatalf("solAmount %d", v)
+	}
+	if v, _ := e.Fields.Bool("isBuy"); !v {
+		t.Fatal("isBuy false")
+	}
+	if v, _ := e.Fields.Int64("timestamp"); v != -1<<63+5 {
+		t.Fatalf("timestamp %d", v)
+	}
+	if v, _ := e.Fields.String("mint"); v != "So11111111111111111111111111111111111111112" {
+		t.Fatalf("mint %s", v)
+	}
+
+	if _, err = p.DecodeEvent(data[:40]); !errors.Is(err, types.ErrShortData) {
+		t.Fatalf("truncated event: %v", err)
+	}
+	// An event of an earlier program version, without the later fields.
+	e, err = p.DecodeEvent(data[:56])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Fields.Uint64("tokenAmount"); ok || e.Fields["solAmount"] == nil {
+		t.Fatalf("earlier event %v", e.Fields)
+	}
+
+	logs := []types.LogDetails{{Program: "router", SubLogs: []types.LogDetails{{
+		Program: pumpProgram,
+		Logs:    []string{"Program log: Instruction: Buy", "Program data: " + base64.StdEncoding.EncodeToString(data[8:]), "Program data: !!"},
+	}}}}
+	if events := p.DecodeLogEvents(logs); len(events) != 1 || events[0].Name != "TradeEvent" {
+		t.Fatalf("decoded %d log events", len(events))
+	}
+}
+
+func TestDecodeInstructionAccounts(t *testing.T) {
+	p := Default().Program(pumpProgram)
+	d, _ := discriminator(nil, "global", "buy")
+	data := binary.LittleEndian.AppendUint64(d[:], 10)
+	data = binary.LittleEndian.AppendUint64(data, 20)
+	keys := []string{"global", "fee", "mint", "curve", "ata", "userAta", "user"}
+
+	ix, err := p.DecodeInstruction(data, keys, []int{0, 1, 2, 3, 4, 5, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := ix.Args.Uint64("maxSolCost"); ix.Name != "buy" || v != 20 {
+		t.Fatalf("decoded %s maxSolCost %d", ix.Name, v)
+	}
+	if curve, _ := ix.Account("bondingCurve"); curve != "curve" {
+		t.Fatalf("bondingCurve %s", curve)
+	}
+	if _, err = ix.Account("eventAuthority"); !errors.Is(err, types.ErrMissingAccounts) {
+		t.Fatalf("absent account: %v", err)
+	}
+
+	if _, err = p.DecodeInstruction(data, keys, []int{0, 9}); !errors.Is(err, types.ErrAccountIndex) {
+		t.Fatalf("bad account index: %v", err)
+	}
+	if _, err = p.DecodeInstruction(make([]byte, 16), keys, nil); !errors.Is(err, types.ErrUnknownInstruction) {
+		t.Fatalf("unknown discriminator: %v", err)
+	}
+}
+
+func TestLegacyIDL(t *testing.T) {
+	idl := `{
+		"version": "0.1.0", "name": "legacy", "metadata": {"address": "Legacy111"},
+		"instructions": [{
+			"name": "swapBaseIn",
+			"accounts": [{"name": "pool", "isMut": true, "isSigner": false}, {"name": "owner", "accounts": [{"name": "userOwner", "isMut": false, "isSigner": true}]}],
+			"args": [
+				{"name": "amountIn", "type": "u64"},
+				{"name": "side", "type": {"defined": "Side"}},
+				{"name": "memo", "type": {"option": "string"}},
+				{"name": "path", "type": {"vec": "publicKey"}},
+				{"name": "delta", "type": "i128"}
+			]
+		}],
+		"events": [{"name": "Swapped", "fields": [{"name": "amount", "type": "i64", "index": false}]}],
+		"types": [{"name": "Side", "type": {"kind": "enum", "variants": [{"name": "Bid"}, {"name": "Ask", "fields": [{"name": "limit", "type": "u16"}]}]}}]
+	}`
+	p, err := NewRegistry().Register([]byte(idl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := discriminator(nil, "global", "swap_base_in")
+	data := binary.LittleEndian.AppendUint64(d[:], 99)
+	data = append(data, 1, 3, 0)    // Ask{limit: 3}
+	data = append(data, 0)          // no memo
+	data = append(data, 0, 0, 0, 0) // empty path
+	for i := 0; i < 16; i++ {
+		data = append(data, 0xff) // -1
+	}
+
+	ix, err := p.DecodeInstruction(data, []string{"pool", "owner"}, []int{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner, _ := ix.Account("userOwner"); owner != "owner" {
+		t.Fatalf("userOwner %s", owner)
+	}
+	side, _ := ix.Args["side"].(Values)
+	if limit, _ := side["Ask"].(Values).Uint64("limit"); limit != 3 {
+		t.Fatalf("side %v", ix.Args["side"])
+	}
+	delta, _ := ix.Args["delta"].(*big.Int)
+	if ix.Args["memo"] != nil || delta == nil || delta.Int64() != -1 {
+		t.Fatalf("args %v", ix.Args)
+	}
+
+	ed, _ := discriminator(nil, "event", "Swapped")
+	e, err := p.DecodeEvent(binary.LittleEndian.AppendUint64(ed[:], 1<<64-2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := e.Fields.Int64("amount"); v != -2 {
+		t.Fatalf("amount %d", v)
+	}
+}
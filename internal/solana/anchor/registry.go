@@ -0,0 +1,113 @@
+package anchor
+
+import (
+	"blocsy/internal/types"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sync"
+)
+
+//go:embed idl/*.json
+var embeddedIDLs embed.FS
+
+// Registry holds the programs with a known IDL.
+type Registry struct {
+	mu       sync.RWMutex
+	programs map[string]*Program
+}
+
+func NewRegistry() *Registry {
+	return &Registry{programs: make(map[string]*Program)}
+}
+
+var (
+	defaultRegistry *Registry
+	defaultOnce     sync.Once
+)
+
+// Default is the registry of the IDLs shipped in idl/ and those found in
+// ANCHOR_IDL_DIR, which replace shipped ones of the same program.
+func Default() *Registry {
+	defaultOnce.Do(func() {
+		defaultRegistry = NewRegistry()
+		if err := defaultRegistry.LoadFS(embeddedIDLs, "idl"); err != nil {
+			log.Printf("Failed to load the embedded IDLs: %v", err)
+		}
+		if dir := os.Getenv("ANCHOR_IDL_DIR"); dir != "" {
+			if err := defaultRegistry.LoadFS(os.DirFS(dir), "."); err != nil {
+				log.Printf("Failed to load the IDLs in %s: %v", dir, err)
+			}
+		}
+	})
+	return defaultRegistry
+}
+
+// Register adds the program of an IDL in JSON, replacing any previous IDL of
+// the same program.
+func (r *Registry) Register(data []byte) (*Program, error) {
+	var idl IDL
+	if err := json.Unmarshal(data, &idl); err != nil {
+		return nil, fmt.Errorf("failed to parse IDL: %w", err)
+	}
+	p, err := NewProgram(&idl)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.programs[p.ID] = p
+	r.mu.Unlock()
+	return p, nil
+}
+
+// LoadFS registers every .json file in dir. A file that fails doesn't stop
+// the others.
+func (r *Registry) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err == nil {
+			_, err = r.Register(data)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Program is the program with address id, or nil.
+func (r *Registry) Program(id string) *Program {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.programs[id]
+}
+
+func (r *Registry) DecodeInstruction(programId string, data []byte, accountKeys []string, accounts []int) (*Instruction, error) {
+	p := r.Program(programId)
+	if p == nil {
+		return nil, types.NewDecodeError(programId, types.ErrUnknownInstruction, "no IDL")
+	}
+	return p.DecodeInstruction(data, accountKeys, accounts)
+}
+
+func (r *Registry) DecodeEvent(programId string, data []byte) (*Event, error) {
+	p := r.Program(programId)
+	if p == nil {
+		return nil, types.NewDecodeError(programId, types.ErrNoEvent, "no IDL")
+	}
+	return p.DecodeEvent(data)
+}
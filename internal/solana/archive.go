@@ -0,0 +1,168 @@
+package solana
+
+import (
+	"archive/tar"
+	"blocsy/internal/types"
+	"context"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mailru/easyjson"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadArchive calls fn with every getBlock response stored under path, which
+// may be a single file, a directory of files or a tar. Files are named after
+// their slot (310000000.json), and files and tars may be zstd compressed
+// (.zst). Directories are read in slot order, tars in archive order. Blocks
+// outside [from, to] are skipped; a to of 0 means no upper bound.
+func ReadArchive(ctx context.Context, path string, from, to uint64, fn func(slot uint64, msg *types.HTTPBlockMessage) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	inRange := func(slot uint64) bool {
+		return slot >= from && (to == 0 || slot <= to)
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to list archive: %w", err)
+		}
+
+		type slotFile struct {
+			slot uint64
+			name string
+		}
+		var files []slotFile
+		for _, e := range entries {
+			if slot, ok := archiveSlot(e.Name()); ok && !e.IsDir() && inRange(slot) {
+				files = append(files, slotFile{slot, e.Name()})
+			}
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].slot < files[j].slot })
+
+		for _, f := range files {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			if err = readArchiveFile(filepath.Join(path, f.name), f.slot, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), ".zst")
+	if strings.HasSuffix(name, ".tar") {
+		return readArchiveTar(ctx, path, inRange, fn)
+	}
+
+	slot, ok := archiveSlot(filepath.Base(path))
+	if !ok {
+		return fmt.Errorf("cannot tell the slot of %s from its name", path)
+	}
+	if !inRange(slot) {
+		return nil
+	}
+	return readArchiveFile(path, slot, fn)
+}
+
+func readArchiveFile(path string, slot uint64, fn func(slot uint64, msg *types.HTTPBlockMessage) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	msg, err := decodeArchiveBlock(f, strings.HasSuffix(path, ".zst"))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return fn(slot, msg)
+}
+
+func readArchiveTar(ctx context.Context, path string, inRange func(uint64) bool, fn func(slot uint64, msg *types.HTTPBlockMessage) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".zst") {
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		slot, ok := archiveSlot(filepath.Base(hdr.Name))
+		if !ok || !inRange(slot) {
+			continue
+		}
+
+		msg, err := decodeArchiveBlock(tr, strings.HasSuffix(hdr.Name, ".zst"))
+		if err != nil {
+			return fmt.Errorf("failed to read %s in %s: %w", hdr.Name, path, err)
+		}
+		if err = fn(slot, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeArchiveBlock(r io.Reader, compressed bool) (*types.HTTPBlockMessage, error) {
+	if compressed {
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	msg := types.HTTPBlockMessage{}
+	if err := easyjson.UnmarshalFromReader(r, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// archiveSlot parses the slot from the leading digits of a file name.
+func archiveSlot(name string) (uint64, bool) {
+	end := 0
+	for end < len(name) && name[end] >= '0' && name[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return 0, false
+	}
+	slot, err := strconv.ParseUint(name[:end], 10, 64)
+	return slot, err == nil
+}
@@ -3,13 +3,29 @@ package solana
 import (
 	"blocsy/internal/types"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 )
 
-func NewBackfillService(solSvc *SolanaService, pRepo SwapsRepo, qHandler *QueueHandler) *BackfillService {
+const (
+	// RPC errors for slots the cluster skipped, or a node no longer has:
+	// its ledger starts at a later snapshot, or long-term storage lacks it.
+	rpcSlotSkipped          = -32007
+	rpcSlotSkippedOrMissing = -32009
+
+	backfillRetryBatch  = 100
+	backfillMaxAttempts = 10
+
+	backfillProgressEvery = 1000
+)
+
+var errFetchFailed = errors.New("no node could serve the block")
+
+func NewBackfillService(solSvc *SolanaService, pRepo BackfillRepo, qHandler *QueueHandler) *BackfillService {
 
 	var nodes []*Node
 
@@ -26,13 +42,16 @@ func NewBackfillService(solSvc *SolanaService, pRepo SwapsRepo, qHandler *QueueH
 		solSvc:       solSvc,
 		queueHandler: qHandler,
 		pRepo:        pRepo,
-		nodeUrls:     nodes,
+		nodes:        NewNodePool(nodes),
 	}
 }
 
+// HandleBackFill queues every block in the range. Blocks no node could serve
+// are recorded in the retry table and picked up by RetryFailedBlocks.
 func (bs *BackfillService) HandleBackFill(ctx context.Context, startBlock int, toBlock int, ignoreWS bool) error {
 	log.Printf("Handling Backfill %d --> %d", startBlock, toBlock)
 	defer log.Printf("Backfill completed")
+	defer bs.nodes.LogStats()
 
 	if toBlock == 0 {
 		slot, err := bs.solSvc.GetSlot(ctx)
@@ -42,30 +61,137 @@ func (bs *BackfillService) HandleBackFill(ctx context.Context, startBlock int, t
 		toBlock = int(slot)
 	}
 
+	started := time.Now()
 	for i := startBlock; i <= toBlock; i++ {
-		n := bs.nodeUrls[i%(len(bs.nodeUrls))]
-		block, err := n.GetBlockMessage(context.Background(), i)
+		if done := i - startBlock; done > 0 && done%backfillProgressEvery == 0 {
+			log.Printf("Backfill %d --> %d: %d/%d blocks (%.1f blocks/s)",
+				startBlock, toBlock, done, toBlock-startBlock+1, float64(done)/time.Since(started).Seconds())
+		}
+
+		err := bs.backfillBlock(ctx, i, ignoreWS)
+		if errors.Is(err, errFetchFailed) && ctx.Err() == nil {
+			log.Printf("Failed to backfill block %d: %v", i, err)
+			bs.recordFailedBlock(ctx, i, err)
+			continue
+		}
 		if err != nil {
-			log.Fatalf("Error getting transaction: %v", err)
+			return err
 		}
-		_ = bs.pRepo.MarkBlockProcessed(ctx, i)
-		if block.Error != nil {
-			if block.Error.Code == -32009 {
-				log.Printf("Block %d not available", i)
-				continue
-			} else {
-				continue
-			}
+	}
+
+	return nil
+}
+
+// RetryFailedBlocks backfills the recorded blocks that are due for another
+// attempt. Blocks that failed backfillMaxAttempts times stay in the table for
+// inspection.
+func (bs *BackfillService) RetryFailedBlocks(ctx context.Context) error {
+	blocks, err := bs.pRepo.FindRetryBlocks(ctx, backfillRetryBatch, backfillMaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		err = bs.backfillBlock(ctx, block, true)
+		if errors.Is(err, errFetchFailed) && ctx.Err() == nil {
+			bs.recordFailedBlock(ctx, block, err)
+			continue
 		}
+		if err != nil {
+			return err
+		}
+		if err = bs.pRepo.ClearFailedBlock(ctx, block); err != nil {
+			log.Printf("Failed to clear retried block %d: %v", block, err)
+		}
+	}
+
+	return nil
+}
 
-		bs.queueHandler.AddToSolanaQueue(types.BlockData{
-			Transactions: block.Result.Transactions,
-			Block:        uint64(i),
-			Timestamp:    block.Result.BlockTime,
-			IgnoreWS:     ignoreWS,
-		})
+func (bs *BackfillService) backfillBlock(ctx context.Context, blockNumber int, ignoreWS bool) error {
+	block, err := bs.fetchBlock(ctx, blockNumber)
+	if err != nil {
+		return err
+	}
+	if block == nil {
+		log.Printf("Block %d not available", blockNumber)
+		_ = bs.pRepo.MarkBlockProcessed(ctx, blockNumber)
+		bs.recordSlot(ctx, blockNumber, types.SlotSkipped)
+		return nil
+	}
 
+	err = bs.queueHandler.AddToSolanaQueue(types.BlockData{
+		Transactions: block.Transactions,
+		Block:        uint64(blockNumber),
+		Timestamp:    block.BlockTime,
+		IgnoreWS:     ignoreWS,
+		Commitment:   types.CommitmentConfirmed,
+		Source:       types.IngestSourceBackfill,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue block %d: %w", blockNumber, err)
 	}
+	_ = bs.pRepo.MarkBlockProcessed(ctx, blockNumber)
 
 	return nil
 }
+
+// recordFailedBlock schedules a block no node could serve for a retry and
+// marks it failed in the slot ledger.
+func (bs *BackfillService) recordFailedBlock(ctx context.Context, blockNumber int, reason error) {
+	if err := bs.pRepo.RecordFailedBlock(ctx, blockNumber, reason.Error()); err != nil {
+		log.Printf("Failed to record block %d for retry: %v", blockNumber, err)
+	}
+	bs.recordSlot(ctx, blockNumber, types.SlotFailed)
+}
+
+// recordSlot covers the slots that never reach the queue.
+func (bs *BackfillService) recordSlot(ctx context.Context, blockNumber int, status string) {
+	err := bs.pRepo.RecordSlot(ctx, types.SlotLedgerEntry{Slot: uint64(blockNumber), Source: types.IngestSourceBackfill, Status: status})
+	if err != nil {
+		log.Printf("Failed to record slot %d in the ledger: %v", blockNumber, err)
+	}
+}
+
+// fetchBlock asks the pool's nodes in turn until one returns the block. A nil
+// block means the slot was skipped, which takes every node in the pool to
+// agree: a node with a pruned ledger gives the same answer for a real block.
+func (bs *BackfillService) fetchBlock(ctx context.Context, blockNumber int) (*types.BlockResult, error) {
+	tried := make(map[*Node]bool)
+	skipped := 0
+	var lastErr error
+
+	for {
+		n, err := bs.nodes.Pick(ctx, tried)
+		if err != nil {
+			if lastErr == nil {
+				lastErr = err
+			}
+			return nil, fmt.Errorf("%w: %w", errFetchFailed, lastErr)
+		}
+		tried[n] = true
+
+		start := time.Now()
+		msg, err := n.GetBlockMessage(ctx, blockNumber)
+		if err == nil && msg.Error != nil {
+			if msg.Error.Code == rpcSlotSkipped || msg.Error.Code == rpcSlotSkippedOrMissing {
+				bs.nodes.Report(n, time.Since(start), nil)
+				if skipped++; skipped == bs.nodes.Len() {
+					return nil, nil
+				}
+				lastErr = fmt.Errorf("rpc error %d: %s", msg.Error.Code, msg.Error.Message)
+				continue
+			}
+			err = fmt.Errorf("rpc error %d: %s", msg.Error.Code, msg.Error.Message)
+		} else if err == nil && msg.Result == nil {
+			err = fmt.Errorf("empty result")
+		}
+		bs.nodes.Report(n, time.Since(start), err)
+
+		if err == nil {
+			return msg.Result, nil
+		}
+		log.Printf("Failed to get block %d from %s: %v", blockNumber, n.name, err)
+		lastErr = err
+	}
+}
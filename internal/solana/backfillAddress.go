@@ -0,0 +1,207 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const signaturesPageSize = 1000
+
+type AddressBackfillOptions struct {
+	// Before and Until bound the walk by signature, newest to oldest.
+	Before string
+	Until  string
+	// MinSlot stops the walk at the first signature older than it.
+	MinSlot uint64
+	// Limit caps the number of signatures walked; 0 walks the full history.
+	Limit int
+}
+
+type AddressBackfillStats struct {
+	Signatures int
+	Queued     int
+	Failed     int
+	Skipped    int
+	OldestSlot uint64
+}
+
+// BackfillAddress walks the transaction history of a wallet with
+// getSignaturesForAddress and queues the transactions, grouped by slot, with
+// IgnoreWS set. The blocks are partial, so they are not marked processed.
+func (bs *BackfillService) BackfillAddress(ctx context.Context, address string, opts AddressBackfillOptions) (AddressBackfillStats, error) {
+	var stats AddressBackfillStats
+	err := bs.walkAddress(ctx, address, opts, make(map[string]bool), &stats)
+	return stats, err
+}
+
+// BackfillToken walks the history of a token like BackfillAddress, and then
+// that of its known pools and their vaults: many venues never list the mint
+// in a swap. A transaction found by several walks is queued once. opts apply
+// to each walk.
+func (bs *BackfillService) BackfillToken(ctx context.Context, mint string, opts AddressBackfillOptions) (AddressBackfillStats, error) {
+	var stats AddressBackfillStats
+
+	addresses := []string{mint}
+	pairs, err := bs.pRepo.FindPairsByToken(ctx, mint)
+	if err != nil {
+		return stats, fmt.Errorf("failed to find pools of %s: %w", mint, err)
+	}
+	for _, pair := range pairs {
+		addresses = append(addresses, pair.Address)
+
+		accInfo, err := bs.solSvc.GetAccountInfo(ctx, pair.Address)
+		if err != nil {
+			log.Printf("Failed to get pool %s, walking it without its vaults: %v", pair.Address, err)
+			continue
+		}
+		vaults, err := poolVaults(accInfo.Owner.String(), accInfo)
+		if err != nil {
+			log.Printf("Failed to decode pool %s, walking it without its vaults: %v", pair.Address, err)
+			continue
+		}
+		for _, vault := range vaults {
+			addresses = append(addresses, vault.String())
+		}
+	}
+	log.Printf("Backfill %s: walking the token, %d pools and their vaults (%d addresses)", mint, len(pairs), len(addresses))
+
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		if err = bs.walkAddress(ctx, address, opts, seen, &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// walkAddress queues the transactions of one address not in seen, and adds
+// them to seen and stats.
+func (bs *BackfillService) walkAddress(ctx context.Context, address string, opts AddressBackfillOptions, seen map[string]bool, stats *AddressBackfillStats) error {
+	started := time.Now()
+	before := opts.Before
+	walked := 0
+
+	for {
+		pageSize := signaturesPageSize
+		if opts.Limit > 0 {
+			pageSize = min(pageSize, opts.Limit-walked)
+		}
+		if pageSize <= 0 {
+			break
+		}
+
+		var page []types.SignatureInfo
+		err := bs.callNodes(ctx, func(n *Node) error {
+			var err error
+			page, err = n.GetSignaturesForAddress(ctx, address, before, opts.Until, pageSize)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get signatures of %s before %q: %w", address, before, err)
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		done := false
+		var block *types.BlockData
+		for _, sig := range page {
+			if opts.MinSlot > 0 && sig.Slot < opts.MinSlot {
+				done = true
+				break
+			}
+			walked++
+			before = sig.Signature
+			if seen[sig.Signature] {
+				continue
+			}
+			seen[sig.Signature] = true
+			stats.Signatures++
+			stats.OldestSlot = sig.Slot
+
+			// The listener does not subscribe to failed transactions either.
+			if sig.Err != nil {
+				stats.Skipped++
+				continue
+			}
+
+			tx, err := bs.fetchTx(ctx, sig.Signature)
+			if err != nil {
+				log.Printf("Failed to get transaction %s: %v", sig.Signature, err)
+				stats.Failed++
+				continue
+			}
+
+			if block != nil && block.Block != sig.Slot {
+				if err = bs.queueHandler.AddToSolanaQueue(*block); err != nil {
+					return fmt.Errorf("failed to queue slot %d: %w", block.Block, err)
+				}
+				block = nil
+			}
+			if block == nil {
+				block = &types.BlockData{Block: sig.Slot, IgnoreWS: true, Commitment: types.CommitmentConfirmed, Source: types.IngestSourceAddress}
+				if sig.BlockTime != nil {
+					block.Timestamp = *sig.BlockTime
+				}
+			}
+			block.Transactions = append(block.Transactions, *tx)
+			stats.Queued++
+		}
+
+		if block != nil {
+			if err = bs.queueHandler.AddToSolanaQueue(*block); err != nil {
+				return fmt.Errorf("failed to queue slot %d: %w", block.Block, err)
+			}
+		}
+
+		log.Printf("Backfill %s: %d signatures, %d queued, %d failed, %d skipped, reached slot %d (%s)",
+			address, stats.Signatures, stats.Queued, stats.Failed, stats.Skipped, stats.OldestSlot, time.Since(started).Round(time.Second))
+
+		if done || len(page) < pageSize {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (bs *BackfillService) fetchTx(ctx context.Context, signature string) (*types.SolanaTx, error) {
+	var tx *types.SolanaTx
+	err := bs.callNodes(ctx, func(n *Node) error {
+		var err error
+		tx, err = n.GetTx(ctx, signature)
+		if err == nil && len(tx.Transaction.Signatures) == 0 {
+			err = fmt.Errorf("transaction not found")
+		}
+		return err
+	})
+	return tx, err
+}
+
+// callNodes runs call on the pool's nodes in turn until one succeeds.
+func (bs *BackfillService) callNodes(ctx context.Context, call func(n *Node) error) error {
+	tried := make(map[*Node]bool)
+	var lastErr error
+
+	for {
+		n, err := bs.nodes.Pick(ctx, tried)
+		if err != nil {
+			if lastErr == nil {
+				lastErr = err
+			}
+			return lastErr
+		}
+		tried[n] = true
+
+		start := time.Now()
+		err = call(n)
+		bs.nodes.Report(n, time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+}
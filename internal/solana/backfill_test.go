@@ -0,0 +1,39 @@
+package solana
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func rpcNode(t *testing.T, name string, response string) *Node {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+	return NewNode(name, srv.URL)
+}
+
+func TestFetchBlockSkipped(t *testing.T) {
+	const (
+		missing = `{"jsonrpc":"2.0","id":"1","error":{"code":-32009,"message":"Slot 5 was skipped, or missing in long-term storage"}}`
+		skipped = `{"jsonrpc":"2.0","id":"1","error":{"code":-32007,"message":"Slot 5 was skipped, or missing due to ledger jump to recent snapshot"}}`
+		block   = `{"jsonrpc":"2.0","id":"1","result":{"blockTime":1700000000,"parentSlot":4,"transactions":[]}}`
+	)
+
+	// A pruned node does not make the slot skipped while another has it.
+	bs := &BackfillService{nodes: NewNodePool([]*Node{rpcNode(t, "pruned", missing), rpcNode(t, "archive", block)})}
+	for i := 0; i < 3; i++ {
+		result, err := bs.fetchBlock(context.Background(), 5)
+		if err != nil || result == nil || result.BlockTime != 1700000000 {
+			t.Fatalf("got %+v, %v", result, err)
+		}
+	}
+
+	bs = &BackfillService{nodes: NewNodePool([]*Node{rpcNode(t, "a", skipped), rpcNode(t, "b", missing)})}
+	if result, err := bs.fetchBlock(context.Background(), 5); err != nil || result != nil {
+		t.Fatalf("slot every node skipped: %+v, %v", result, err)
+	}
+}
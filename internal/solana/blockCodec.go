@@ -0,0 +1,117 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"encoding/json"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"strings"
+)
+
+const (
+	EncodingJSON = "json"
+	EncodingZstd = "zstd"
+
+	contentTypeJSON = "application/json"
+	// contentTypeBlockV1 is easyjson BlockData; bump the version whenever the
+	// payload layout changes so consumers can keep decoding older messages.
+	contentTypeBlockV1 = "application/vnd.blocsy.block.v1+json"
+
+	contentTypeSlotStatusV1 = "application/vnd.blocsy.slot-status.v1+json"
+	contentTypePoolStateV1  = "application/vnd.blocsy.pool-state.v1+json"
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// EncodeBlock builds the queue message for a block. EncodingJSON produces the
+// original plain JSON message that every consumer understands.
+func EncodeBlock(block types.BlockData, encoding string) (Message, error) {
+	body, err := block.MarshalJSON()
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to marshal block %d: %w", block.Block, err)
+	}
+
+	switch encoding {
+	case "", EncodingJSON:
+		return Message{ContentType: contentTypeJSON, Body: body}, nil
+	case EncodingZstd:
+		return Message{
+			ContentType:     contentTypeBlockV1,
+			ContentEncoding: EncodingZstd,
+			Body:            zstdEncoder.EncodeAll(body, make([]byte, 0, len(body)/4)),
+		}, nil
+	default:
+		return Message{}, fmt.Errorf("unknown block encoding %q", encoding)
+	}
+}
+
+// DecodeBlock accepts every format EncodeBlock has ever produced.
+func DecodeBlock(msg Message) (types.BlockData, error) {
+	var block types.BlockData
+
+	body := msg.Body
+	switch msg.ContentEncoding {
+	case "":
+	case EncodingZstd:
+		var err error
+		if body, err = zstdDecoder.DecodeAll(msg.Body, nil); err != nil {
+			return block, fmt.Errorf("failed to decompress block: %w", err)
+		}
+	default:
+		return block, fmt.Errorf("unknown content encoding %q", msg.ContentEncoding)
+	}
+
+	contentType, _, _ := strings.Cut(msg.ContentType, ";")
+	switch strings.TrimSpace(contentType) {
+	case "", contentTypeJSON, contentTypeBlockV1:
+		if err := block.UnmarshalJSON(body); err != nil {
+			return block, fmt.Errorf("failed to unmarshal block: %w", err)
+		}
+		return block, nil
+	default:
+		return block, fmt.Errorf("unknown content type %q", msg.ContentType)
+	}
+}
+
+func EncodeSlotStatus(status types.SlotStatus) (Message, error) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to marshal slot status %d: %w", status.Slot, err)
+	}
+	return Message{ContentType: contentTypeSlotStatusV1, Body: body}, nil
+}
+
+func IsSlotStatus(msg Message) bool {
+	return msg.ContentType == contentTypeSlotStatusV1
+}
+
+func DecodeSlotStatus(msg Message) (types.SlotStatus, error) {
+	var status types.SlotStatus
+	if err := json.Unmarshal(msg.Body, &status); err != nil {
+		return status, fmt.Errorf("failed to unmarshal slot status: %w", err)
+	}
+	return status, nil
+}
+
+func EncodePoolStates(states []types.PoolState) (Message, error) {
+	body, err := json.Marshal(states)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to marshal pool states: %w", err)
+	}
+	return Message{ContentType: contentTypePoolStateV1, Body: body}, nil
+}
+
+func DecodePoolStates(msg Message) ([]types.PoolState, error) {
+	if msg.ContentType != contentTypePoolStateV1 {
+		return nil, fmt.Errorf("unknown content type %q", msg.ContentType)
+	}
+
+	var states []types.PoolState
+	if err := json.Unmarshal(msg.Body, &states); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pool states: %w", err)
+	}
+	return states, nil
+}
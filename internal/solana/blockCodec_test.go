@@ -0,0 +1,159 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/mr-tron/base58"
+)
+
+func randomKey(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	r.Read(b)
+	return base58.Encode(b)
+}
+
+// sampleBlock builds a block shaped like a busy mainnet slot: most
+// transactions touch a handful of shared programs and mints.
+func sampleBlock(txs int) types.BlockData {
+	r := rand.New(rand.NewSource(1))
+
+	programs := make([]string, 8)
+	for i := range programs {
+		programs[i] = randomKey(r, 32)
+	}
+	mints := make([]string, 32)
+	for i := range mints {
+		mints[i] = randomKey(r, 32)
+	}
+
+	block := types.BlockData{Block: 300000000, Timestamp: 1735689600}
+	for i := 0; i < txs; i++ {
+		keys := []string{randomKey(r, 32)}
+		for j := 0; j < 12; j++ {
+			keys = append(keys, randomKey(r, 32))
+		}
+		keys = append(keys, programs[r.Intn(len(programs))], programs[r.Intn(len(programs))])
+
+		balances := make([]uint64, len(keys))
+		for j := range balances {
+			balances[j] = uint64(r.Int63n(1e12))
+		}
+
+		var tokenBalances []types.TokenBalance
+		for j := 0; j < 4; j++ {
+			tokenBalances = append(tokenBalances, types.TokenBalance{
+				AccountIndex: r.Intn(len(keys)),
+				Mint:         mints[r.Intn(len(mints))],
+				Owner:        keys[r.Intn(len(keys))],
+				ProgramId:    programs[0],
+				UITokenAmount: types.UITokenAmount{
+					Amount:   fmt.Sprint(r.Int63()),
+					Decimals: 6,
+				},
+			})
+		}
+
+		block.Transactions = append(block.Transactions, types.SolanaTx{
+			Meta: types.TransactionMeta{
+				Fee:               5000,
+				PreBalances:       balances,
+				PostBalances:      balances,
+				PreTokenBalances:  tokenBalances,
+				PostTokenBalances: tokenBalances,
+				LogMessages: []string{
+					"Program " + keys[len(keys)-1] + " invoke [1]",
+					"Program log: Instruction: Swap",
+					"Program " + keys[len(keys)-1] + " consumed 45123 of 200000 compute units",
+					"Program " + keys[len(keys)-1] + " success",
+				},
+			},
+			Transaction: types.TransactionData{
+				Signatures: []string{randomKey(r, 64)},
+				Message: types.Message{
+					AccountKeys:     keys,
+					RecentBlockhash: randomKey(r, 32),
+					Instructions: []types.Instruction{
+						{ProgramIdIndex: len(keys) - 1, Accounts: []int{1, 2, 3, 4, 5, 6}, Data: randomKey(r, 24)},
+						{ProgramIdIndex: len(keys) - 2, Accounts: []int{7, 8, 9}, Data: randomKey(r, 9)},
+					},
+				},
+			},
+		})
+	}
+
+	return block
+}
+
+func TestDecodeBlockFormats(t *testing.T) {
+	block := sampleBlock(10)
+
+	for _, encoding := range []string{EncodingJSON, EncodingZstd} {
+		msg, err := EncodeBlock(block, encoding)
+		if err != nil {
+			t.Fatalf("%s: encode: %v", encoding, err)
+		}
+
+		decoded, err := DecodeBlock(msg)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", encoding, err)
+		}
+		if decoded.Block != block.Block || len(decoded.Transactions) != len(block.Transactions) ||
+			decoded.Transactions[9].Transaction.Signatures[0] != block.Transactions[9].Transaction.Signatures[0] {
+			t.Fatalf("%s: block did not round-trip", encoding)
+		}
+	}
+
+	// Messages published before the envelope existed carry no encoding.
+	body, _ := block.MarshalJSON()
+	if _, err := DecodeBlock(Message{ContentType: "application/json", Body: body}); err != nil {
+		t.Fatalf("legacy message: %v", err)
+	}
+
+	if _, err := DecodeBlock(Message{ContentEncoding: "br", Body: body}); err == nil {
+		t.Fatalf("expected an error for an unknown encoding")
+	}
+}
+
+func BenchmarkEncodeBlock(b *testing.B) {
+	block := sampleBlock(1000)
+
+	for _, encoding := range []string{EncodingJSON, EncodingZstd} {
+		b.Run(encoding, func(b *testing.B) {
+			var size int
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				msg, err := EncodeBlock(block, encoding)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(msg.Body)
+			}
+			b.ReportMetric(float64(size), "bytes/msg")
+		})
+	}
+}
+
+func BenchmarkDecodeBlock(b *testing.B) {
+	block := sampleBlock(1000)
+
+	for _, encoding := range []string{EncodingJSON, EncodingZstd} {
+		msg, err := EncodeBlock(block, encoding)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(encoding, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(msg.Body)))
+			for i := 0; i < b.N; i++ {
+				if _, err := DecodeBlock(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(msg.Body)), "bytes/msg")
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package solana
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultCheckpointFile = "listener.checkpoint"
+
+// SlotCheckpoint persists the last slot the listener has fully received so a
+// restart or reconnect can resume the stream from the following slot.
+type SlotCheckpoint struct {
+	path string
+	mu   sync.Mutex
+	slot uint64
+}
+
+func NewSlotCheckpoint(path string) *SlotCheckpoint {
+	if path == "" {
+		path = defaultCheckpointFile
+	}
+	return &SlotCheckpoint{path: path}
+}
+
+func (c *SlotCheckpoint) Load() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("cannot read checkpoint: %w", err)
+	}
+
+	slot, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint %q: %w", c.path, err)
+	}
+
+	c.slot = slot
+	return slot, nil
+}
+
+func (c *SlotCheckpoint) Slot() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slot
+}
+
+// Save records slot if it is newer than the stored one. The file is replaced
+// atomically so a crash mid-write never leaves a truncated checkpoint.
+func (c *SlotCheckpoint) Save(slot uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if slot <= c.slot {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot create checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.WriteString(strconv.FormatUint(slot, 10)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write checkpoint: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot sync checkpoint: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("cannot close checkpoint: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("cannot replace checkpoint: %w", err)
+	}
+
+	c.slot = slot
+	return nil
+}
@@ -0,0 +1,171 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Statuses are kept this many slots behind the finalized root so late
+	// duplicates from slower endpoints are recognised.
+	commitmentHistorySlots = 1024
+
+	reconcileInterval = 10 * time.Second
+	// Dead slots are rolled back repeatedly for this long to catch blocks
+	// that were still being processed when the slot was declared dead.
+	rollbackWindow = 10 * time.Minute
+	// About two days of slot statuses are kept in the database.
+	slotHistoryKept = 432000
+)
+
+func commitmentRank(status string) int {
+	switch status {
+	case types.CommitmentProcessed:
+		return 1
+	case types.CommitmentConfirmed:
+		return 2
+	case types.CommitmentFinalized, types.SlotDead:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// CommitmentTracker follows the status of every slot across all endpoints and
+// reports each change once. When a slot is finalized, the recorded parents
+// lead back from it: older slots on that chain are finalized with it, and
+// those the chain passes over are reported dead. Slots below the known part
+// of the chain are left to the node's own dead status, since a missed parent
+// must not roll back a rooted slot.
+type CommitmentTracker struct {
+	mu      sync.Mutex
+	slots   map[uint64]string
+	parents map[uint64]uint64
+	root    uint64
+	publish func(types.SlotStatus)
+}
+
+func NewCommitmentTracker(publish func(types.SlotStatus)) *CommitmentTracker {
+	return &CommitmentTracker{
+		slots:   make(map[uint64]string),
+		parents: make(map[uint64]uint64),
+		publish: publish,
+	}
+}
+
+func (c *CommitmentTracker) Observe(slot, parent uint64, status string) {
+	var changes []types.SlotStatus
+
+	c.mu.Lock()
+	if slot <= c.root && status != types.SlotDead {
+		c.mu.Unlock()
+		return
+	}
+	if parent != 0 {
+		c.parents[slot] = parent
+	}
+	if commitmentRank(status) <= commitmentRank(c.slots[slot]) {
+		c.mu.Unlock()
+		return
+	}
+
+	c.slots[slot] = status
+	changes = append(changes, types.SlotStatus{Slot: slot, Parent: parent, Status: status})
+
+	if status == types.CommitmentFinalized {
+		// The ancestors of the new root, as far back as their parents are
+		// known. Every slot between lowest and the root is on it or dead.
+		ancestors := make(map[uint64]bool)
+		lowest := slot
+		for s := slot; s > c.root; {
+			p, ok := c.parents[s]
+			if !ok {
+				break
+			}
+			ancestors[p] = true
+			lowest = p
+			s = p
+		}
+
+		for s, st := range c.slots {
+			if s >= slot || commitmentRank(st) >= commitmentRank(types.CommitmentFinalized) {
+				continue
+			}
+			if ancestors[s] {
+				c.slots[s] = types.CommitmentFinalized
+				changes = append(changes, types.SlotStatus{Slot: s, Parent: c.parents[s], Status: types.CommitmentFinalized})
+			} else if s > lowest {
+				c.slots[s] = types.SlotDead
+				changes = append(changes, types.SlotStatus{Slot: s, Status: types.SlotDead})
+			}
+		}
+
+		c.root = slot
+		for s := range c.slots {
+			if s+commitmentHistorySlots < c.root {
+				delete(c.slots, s)
+			}
+		}
+		for s := range c.parents {
+			if s+commitmentHistorySlots < c.root {
+				delete(c.parents, s)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	for _, change := range changes {
+		c.publish(change)
+	}
+}
+
+// CommitmentReconciler applies slot statuses recorded by the tx processor:
+// swaps below the finalized root are flagged final and dead slots are rolled
+// back.
+type CommitmentReconciler struct {
+	repo SlotsRepo
+}
+
+func NewCommitmentReconciler(repo SlotsRepo) *CommitmentReconciler {
+	return &CommitmentReconciler{repo: repo}
+}
+
+func (r *CommitmentReconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reconcile(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *CommitmentReconciler) reconcile(ctx context.Context) {
+	dead, err := r.repo.FindDeadSlots(ctx, rollbackWindow)
+	if err != nil {
+		log.Printf("Failed to find dead slots: %v", err)
+	}
+	for _, slot := range dead {
+		if err = r.repo.RollbackSlot(ctx, slot); err != nil {
+			log.Printf("Failed to roll back slot %d: %v", slot, err)
+		}
+	}
+
+	finalized, err := r.repo.FinalizeSwaps(ctx)
+	if err != nil {
+		log.Printf("Failed to finalize swaps: %v", err)
+	} else if finalized > 0 {
+		log.Printf("Finalized %d swaps", finalized)
+	}
+
+	if err = r.repo.PruneSlotHistory(ctx, slotHistoryKept); err != nil {
+		log.Printf("Failed to prune slot history: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"testing"
+)
+
+func TestCommitmentTrackerFinalize(t *testing.T) {
+	published := make(map[uint64]string)
+	c := NewCommitmentTracker(func(s types.SlotStatus) { published[s.Slot] = s.Status })
+
+	c.Observe(100, 99, types.CommitmentProcessed)
+	c.Observe(101, 100, types.CommitmentConfirmed)
+	c.Observe(102, 100, types.CommitmentProcessed) // a fork off 100
+	c.Observe(103, 101, types.CommitmentProcessed)
+	c.Observe(103, 101, types.CommitmentFinalized)
+
+	want := map[uint64]string{
+		100: types.CommitmentFinalized,
+		101: types.CommitmentFinalized,
+		102: types.SlotDead,
+		103: types.CommitmentFinalized,
+	}
+	for slot, status := range want {
+		if published[slot] != status {
+			t.Fatalf("slot %d: %s, want %s", slot, published[slot], status)
+		}
+	}
+
+	// Late updates of settled slots are not reported again.
+	delete(published, 102)
+	c.Observe(102, 100, types.CommitmentConfirmed)
+	if _, ok := published[102]; ok {
+		t.Fatal("dead slot reported again")
+	}
+}
+
+func TestCommitmentTrackerMissingParent(t *testing.T) {
+	published := make(map[uint64]string)
+	c := NewCommitmentTracker(func(s types.SlotStatus) { published[s.Slot] = s.Status })
+
+	c.Observe(200, 199, types.CommitmentConfirmed)
+	// The update that would link 201 to 200 was missed.
+	c.Observe(202, 201, types.CommitmentFinalized)
+
+	if published[200] != types.CommitmentConfirmed {
+		t.Fatalf("slot 200 off an unknown chain reported %s", published[200])
+	}
+
+	// The node reports its dead slots itself.
+	c.Observe(200, 199, types.SlotDead)
+	if published[200] != types.SlotDead {
+		t.Fatalf("slot 200: %s", published[200])
+	}
+}
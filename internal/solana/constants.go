@@ -9,6 +9,7 @@ const (
 
 	METEORA_DLMM_PROGRAM  = "LBUZKhRxPF3XUpBCjp4YzTKgLccjZhTSDM9YuVaPwxo"
 	METEORA_POOLS_PROGRAM = "Eo7WjKq67rjJQSZxS6z3YkapzY3eMj6Xy8X5EQVn5UaB"
+	METEORA_VAULT_PROGRAM = "24Uqj9JCLxUeoC3hGfh5W3s9FM9uCHDS2SG3LYwBpyTi"
 
 	RAYDIUM_AMM_ROUTING      = "routeUGWgWzqBWFcrCfv8tritsqukccJPu3q5GPP3xS"
 	RAYDIUM_LIQ_POOL_V4      = "675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8"
@@ -27,6 +28,7 @@ const (
 	PHOENIX = "PhoeNiXZ8ByJGLkxNfZRnkUfjvmuYqLR89jjFHGqdXY"
 
 	TOKEN_PROGRAM            = "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"
+	TOKEN_2022_PROGRAM       = "TokenzQdBNbLqP5VEhdkAS6EPFLC1PHnBqCXEpPxuEb"
 	ASSOCIATED_TOKEN_PROGRAM = "ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL"
 	SYSTEM_PROGRAM           = "11111111111111111111111111111111"
 	METAPLEX_TOKEN_METDATA   = "metaqbxxUerdq28cj1RbAWkYQm3ybzjb6a8bt518x1s"
@@ -73,11 +75,3 @@ var Programs = map[string]string{
 	ORCA_SWAP:                "ORCA_SWAP",
 	PHOENIX:                  "PHOENIX",
 }
-
-var IgnorePrograms = map[string]bool{
-	PHOENIX:               true,
-	LIFINITY_SWAP_V2:      true,
-	ORCA_SWAP:             true,
-	ORCA_SWAP_V2:          true,
-	METEORA_POOLS_PROGRAM: true,
-}
@@ -4,37 +4,36 @@ import (
 	"blocsy/internal/types"
 )
 
-func HandleFluxbeamSwaps(tx *types.SolanaTx, innerIndex int, ixIndex int, transfers []types.SolTransfer) (types.SolSwap, int) {
-	transfer1, ok := FindTransfer(transfers, innerIndex, ixIndex+1)
-	if !ok {
-		return types.SolSwap{}, 0
-	}
+// HandleFluxbeamSwaps decodes a FluxBeam swap, whose owner fee may be minted
+// as pool LP between the two legs.
+func HandleFluxbeamSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
+	currentTransfer := transfers[index]
 
-	tf2Index := ixIndex + 2
-	transfer2, ok := FindTransfer(transfers, innerIndex, tf2Index)
-	if !ok {
-		return types.SolSwap{}, 0
+	program := currentTransfer.ParentProgramId
+	pair, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 0)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
-
-	for transfer2.Type == "mint" {
-		tf2Index++
-		transfer2, ok = FindTransfer(transfers, innerIndex, tf2Index)
-		if !ok {
-			return types.SolSwap{}, 0
-		}
-
-		if transfer2.Mint == "" {
-			break
-		}
+	wallet, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 2)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	userSource, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 3)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	userDestination, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 6)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
-	s := types.SolSwap{
-		Exchange:  "FLUXBEAM",
-		Wallet:    transfer1.FromUserAccount,
-		TokenOut:  transfer1.Mint,
-		AmountOut: transfer1.Amount,
-		TokenIn:   transfer2.Mint,
-		AmountIn:  transfer2.Amount,
+	s, inc, err := userLegs(index, transfers, userSource, userDestination)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
-	return s, tf2Index - ixIndex
+	s.Pair = pair
+	s.Exchange = "FLUXBEAM"
+	s.Wallet = wallet
+
+	return s, inc, nil
 }
@@ -0,0 +1,76 @@
+package dex
+
+import (
+	"blocsy/internal/solana/anchor"
+	"blocsy/internal/types"
+)
+
+// jupiterRoutes are the Jupiter instructions that swap along a route plan.
+var jupiterRoutes = map[string]bool{
+	"route":                              true,
+	"routeWithTokenLedger":               true,
+	"exactOutRoute":                      true,
+	"sharedAccountsRoute":                true,
+	"sharedAccountsRouteWithTokenLedger": true,
+	"sharedAccountsExactOutRoute":        true,
+}
+
+func IsJupiterRoute(ix *anchor.Instruction) bool {
+	return jupiterRoutes[ix.Name]
+}
+
+// HandleJupiterRoute folds the SwapEvents of a route into the trade the user
+// made, from the input mint of the first hop to the output mint of the last.
+// Hops that split or merge are summed. decimals is -1 for an unknown mint.
+func HandleJupiterRoute(ix *anchor.Instruction, events []*anchor.Event, decimals func(mint string) int) (types.SolSwap, error) {
+	wallet, err := ix.Account("userTransferAuthority")
+	if err != nil {
+		return types.SolSwap{}, err
+	}
+
+	var hops []anchor.Values
+	for _, event := range events {
+		if event.Name == "SwapEvent" {
+			hops = append(hops, event.Fields)
+		}
+	}
+	if len(hops) == 0 {
+		return types.SolSwap{}, types.NewDecodeError(ix.Program, types.ErrNoEvent, "%s without swap events", ix.Name)
+	}
+
+	inputMint, _ := hops[0].String("inputMint")
+	outputMint, _ := hops[len(hops)-1].String("outputMint")
+	// A round trip, like an arbitrage, is not a trade.
+	if inputMint == outputMint {
+		return types.SolSwap{}, nil
+	}
+
+	var inputAmount, outputAmount uint64
+	for _, hop := range hops {
+		if mint, _ := hop.String("inputMint"); mint == inputMint {
+			amount, _ := hop.Uint64("inputAmount")
+			inputAmount += amount
+		}
+		if mint, _ := hop.String("outputMint"); mint == outputMint {
+			amount, _ := hop.Uint64("outputAmount")
+			outputAmount += amount
+		}
+	}
+
+	inputDecimals, outputDecimals := decimals(inputMint), decimals(outputMint)
+	if inputDecimals < 0 {
+		return types.SolSwap{}, types.NewDecodeError(ix.Program, types.ErrUnknownDecimals, "%s", inputMint)
+	}
+	if outputDecimals < 0 {
+		return types.SolSwap{}, types.NewDecodeError(ix.Program, types.ErrUnknownDecimals, "%s", outputMint)
+	}
+
+	return types.SolSwap{
+		Exchange:  "JUPITER",
+		Wallet:    wallet,
+		TokenOut:  inputMint,
+		AmountOut: uiAmount(inputAmount, inputDecimals),
+		TokenIn:   outputMint,
+		AmountIn:  uiAmount(outputAmount, outputDecimals),
+	}, nil
+}
@@ -4,36 +4,36 @@ import (
 	"blocsy/internal/types"
 )
 
-func HandleLifinitySwaps(tx *types.SolanaTx, innerIndex int, ixIndex int, transfers []types.SolTransfer) (types.SolSwap, int) {
-	tf2Index := ixIndex + 2
+// HandleLifinitySwaps decodes a Lifinity V2 swap, whose fee may be minted as
+// pool LP between the two legs.
+func HandleLifinitySwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
+	currentTransfer := transfers[index]
 
-	transfer1, ok := FindTransfer(transfers, innerIndex, ixIndex+1)
-	if !ok {
-		return types.SolSwap{}, 0
+	program := currentTransfer.ParentProgramId
+	pair, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 1)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
-
-	transfer2, ok := FindTransfer(transfers, innerIndex, tf2Index)
-	if !ok {
-		return types.SolSwap{}, 0
+	wallet, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 2)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
-
-	if transfer2.Mint != "" {
-		for transfer2.Type == "Mint" {
-			tf2Index++
-			transfer2, ok = FindTransfer(transfers, innerIndex, tf2Index)
-			if !ok {
-				return types.SolSwap{}, 0
-			}
-		}
+	userSource, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 3)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	userDestination, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 4)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
-	s := types.SolSwap{
-		Exchange:  "LIFINITY",
-		Wallet:    transfer1.FromUserAccount,
-		TokenOut:  transfer1.Mint,
-		AmountOut: transfer1.Amount,
-		TokenIn:   transfer2.Mint,
-		AmountIn:  transfer2.Amount,
+	s, inc, err := userLegs(index, transfers, userSource, userDestination)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
-	return s, tf2Index - ixIndex
+	s.Pair = pair
+	s.Exchange = "LIFINITY"
+	s.Wallet = wallet
+
+	return s, inc, nil
 }
@@ -2,25 +2,25 @@ package dex
 
 import "blocsy/internal/types"
 
-func HandleMeteoraSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int) {
-	if index+1 >= len(transfers) {
-		return types.SolSwap{}, 0
-	}
-
+func HandleMeteoraSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
 	currentTransfer := transfers[index]
-	nextTransfer := transfers[index+1]
-	if currentTransfer.ParentProgramId != nextTransfer.ParentProgramId {
-		return types.SolSwap{}, 0
+	nextTransfer, err := pairedTransfer(index, transfers)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
-	if len(currentTransfer.IxAccounts) == 0 || len(accountKeys) < (currentTransfer.IxAccounts)[0] {
-		return types.SolSwap{}, 0
+	program := currentTransfer.ParentProgramId
+	pair, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 0)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	wallet, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 10)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
-
-	wallet := accountKeys[(currentTransfer.IxAccounts)[10]]
 
 	s := types.SolSwap{
-		Pair:      accountKeys[(currentTransfer.IxAccounts)[0]],
+		Pair:      pair,
 		Exchange:  "METEORA",
 		Wallet:    wallet,
 		TokenOut:  currentTransfer.Mint,
@@ -29,6 +29,6 @@ func HandleMeteoraSwaps(index int, transfers []types.SolTransfer, accountKeys []
 		AmountOut: currentTransfer.Amount,
 	}
 
-	return s, 1
+	return s, 1, nil
 
 }
@@ -0,0 +1,40 @@
+package dex
+
+import "blocsy/internal/types"
+
+// HandleMeteoraPoolsSwaps decodes a swap of a Meteora dynamic AMM pool. The
+// pool holds no tokens itself: the input is deposited into the vault of one
+// mint and the output withdrawn from the vault of the other, with vault LP
+// minted and burned in between. The protocol fee goes straight from the
+// user's source account and counts as input.
+func HandleMeteoraPoolsSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
+	currentTransfer := transfers[index]
+
+	program := currentTransfer.ParentProgramId
+	pair, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 0)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	userSource, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 1)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	userDestination, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 2)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	wallet, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 12)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+
+	s, inc, err := userLegs(index, transfers, userSource, userDestination)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	s.Pair = pair
+	s.Exchange = "METEORA"
+	s.Wallet = wallet
+
+	return s, inc, nil
+}
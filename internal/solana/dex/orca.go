@@ -4,28 +4,22 @@ import (
 	"blocsy/internal/types"
 )
 
-func HandleOrcaSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int) {
-	if index+1 >= len(transfers) {
-		return types.SolSwap{}, 0
-	}
-
+func HandleOrcaSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
 	currentTransfer := transfers[index]
-	nextTransfer := transfers[index+1]
-	if currentTransfer.ParentProgramId != nextTransfer.ParentProgramId {
-		return types.SolSwap{}, 0
-	}
-
-	if len(currentTransfer.IxAccounts) < 3 || len(accountKeys) < (currentTransfer.IxAccounts)[2] {
-		return types.SolSwap{}, 0
+	nextTransfer, err := pairedTransfer(index, transfers)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
 	wallet := currentTransfer.FromUserAccount
 
-	pair := ""
+	pairIndex := 2
 	if len(currentTransfer.IxAccounts) == 15 {
-		pair = accountKeys[(currentTransfer.IxAccounts)[4]]
-	} else {
-		pair = accountKeys[(currentTransfer.IxAccounts)[2]]
+		pairIndex = 4
+	}
+	pair, err := accountAt(currentTransfer.ParentProgramId, accountKeys, currentTransfer.IxAccounts, pairIndex)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
 	s := types.SolSwap{
@@ -38,6 +32,6 @@ func HandleOrcaSwaps(index int, transfers []types.SolTransfer, accountKeys []str
 		AmountOut: currentTransfer.Amount,
 	}
 
-	return s, 1
+	return s, 1, nil
 
 }
@@ -0,0 +1,53 @@
+package dex
+
+import (
+	"blocsy/internal/types"
+	"github.com/mr-tron/base58"
+)
+
+// tokenSwapSwap is the tag of the swap instruction of the SPL token-swap
+// program the legacy Orca programs are built on.
+const tokenSwapSwap = 1
+
+// HandleOrcaTokenSwaps decodes a swap of the legacy Orca token-swap programs.
+// Deposits take the same accounts as a swap, so the instruction tag tells
+// them apart.
+func HandleOrcaTokenSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
+	currentTransfer := transfers[index]
+
+	program := currentTransfer.ParentProgramId
+	data, err := base58.Decode(currentTransfer.ParentData)
+	if err != nil {
+		return types.SolSwap{}, 0, types.NewDecodeError(program, types.ErrBadEncoding, "%v", err)
+	}
+	if len(data) == 0 || data[0] != tokenSwapSwap {
+		return types.SolSwap{}, 0, nil
+	}
+
+	pair, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 0)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	wallet, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 2)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	userSource, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 3)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	userDestination, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 6)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+
+	s, inc, err := userLegs(index, transfers, userSource, userDestination)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	s.Pair = pair
+	s.Exchange = "ORCA"
+	s.Wallet = wallet
+
+	return s, inc, nil
+}
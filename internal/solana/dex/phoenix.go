@@ -2,26 +2,55 @@ package dex
 
 import (
 	"blocsy/internal/types"
+	"github.com/mr-tron/base58"
 )
 
-func HandlePhoenixSwaps(innerIndex int, ixIndex int, transfers []types.SolTransfer) (types.SolSwap, int) {
-	transfer1, ok := FindTransfer(transfers, innerIndex, ixIndex+1)
-	if !ok {
-		return types.SolSwap{}, 0
+// phoenixSwap is the tag of the Phoenix swap instruction.
+const phoenixSwap = 0
+
+// HandlePhoenixSwaps decodes a Phoenix swap. Phoenix is an order book: the
+// taker fills against resting orders, and the market vaults, not a pool,
+// send and receive the tokens. The market is the pair and the trader the
+// wallet; the side is the one of the trader's base and quote accounts the
+// input left. Withdrawals and the other instructions that move tokens between
+// the trader and the vaults take the same leading accounts, so the instruction
+// tag tells them apart.
+func HandlePhoenixSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
+	currentTransfer := transfers[index]
+
+	program := currentTransfer.ParentProgramId
+	data, err := base58.Decode(currentTransfer.ParentData)
+	if err != nil {
+		return types.SolSwap{}, 0, types.NewDecodeError(program, types.ErrBadEncoding, "%v", err)
+	}
+	if len(data) == 0 || data[0] != phoenixSwap {
+		return types.SolSwap{}, 0, nil
 	}
 
-	transfer2, ok := FindTransfer(transfers, innerIndex, ixIndex+2)
-	if !ok {
-		return types.SolSwap{}, 0
+	market, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 2)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	trader, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 3)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	baseAccount, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 4)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	quoteAccount, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 5)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
-	s := types.SolSwap{
-		Exchange:  "PHOENIX",
-		Wallet:    transfer2.FromUserAccount,
-		TokenOut:  transfer2.Mint,
-		TokenIn:   transfer1.Mint,
-		AmountIn:  transfer1.Amount,
-		AmountOut: transfer2.Amount,
+	s, inc, err := userLegs(index, transfers, baseAccount, quoteAccount)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
-	return s, 3
+	s.Pair = market
+	s.Exchange = "PHOENIX"
+	s.Wallet = trader
+
+	return s, inc, nil
 }
@@ -1,77 +1,72 @@
 package dex
 
 import (
+	"blocsy/internal/solana/anchor"
 	"blocsy/internal/types"
-	"encoding/base64"
-	"encoding/hex"
+	"github.com/blocto/solana-go-sdk/common"
 	"github.com/mr-tron/base58"
 	"math"
 	"math/big"
 	"strconv"
-	"strings"
 )
 
-const TRADE_EVENT_DISCRIMINATOR = "bddb7fd34ee661ee"
-
-func HandlePumpFunSwapData(ixData string) types.SolSwap {
+// HandlePumpFunSwapData decodes the trade event that programId emitted as
+// ixData.
+func HandlePumpFunSwapData(programId string, ixData string) (types.SolSwap, error) {
 	s := types.SolSwap{}
 	var tokenOutDecimals, tokenInDecimals int
 
-	bytesData, _ := base58.Decode(ixData)
-	if bytesData == nil {
-		return types.SolSwap{}
+	if ixData == "" {
+		return types.SolSwap{}, types.NewDecodeError(programId, types.ErrNoEvent, "")
+	}
+	bytesData, err := base58.Decode(ixData)
+	if err != nil {
+		return types.SolSwap{}, types.NewDecodeError(programId, types.ErrBadEncoding, "%v", err)
 	}
 
-	hexData := hex.EncodeToString(bytesData)
-	pos := strings.Index(hexData, TRADE_EVENT_DISCRIMINATOR)
-	if pos != -1 {
-		slicedHexData := hexData[pos+len(TRADE_EVENT_DISCRIMINATOR):]
-		decodedBytes, err := hex.DecodeString(slicedHexData)
-		if err != nil {
-			return types.SolSwap{}
-		}
-
-		swap_ := types.PumpFunSwap{}
-		err = swap_.Decode(decodedBytes)
-		if err != nil {
-			return types.SolSwap{}
-		}
-
-		if swap_.Mint.String() == "" {
-			return types.SolSwap{}
-		}
+	event, err := anchor.Default().DecodeEvent(programId, bytesData)
+	if err != nil {
+		return types.SolSwap{}, err
+	}
+	if event.Name != "TradeEvent" {
+		return types.SolSwap{}, types.NewDecodeError(programId, types.ErrNoEvent, "%s, not a trade", event.Name)
+	}
 
-		s.TokenIn = swap_.Mint.String()
-		s.Wallet = swap_.User.String()
-		s.Exchange = "PUMPFUN"
-
-		if swap_.IsBuy {
-			s.TokenOut = "So11111111111111111111111111111111111111112"
-			tokenOutDecimals = 9
-			tokenInDecimals = 6
-			s.TokenIn = swap_.Mint.String()
-			s.AmountOut = strconv.FormatUint(swap_.SolAmount, 10)
-			s.AmountIn = strconv.FormatUint(swap_.TokenAmount, 10)
-		} else {
-			tokenOutDecimals = 6
-			tokenInDecimals = 9
-			s.TokenIn = "So11111111111111111111111111111111111111112"
-			s.TokenOut = swap_.Mint.String()
-			s.AmountOut = strconv.FormatUint(swap_.TokenAmount, 10)
-			s.AmountIn = strconv.FormatUint(swap_.SolAmount, 10)
-		}
+	mint, _ := event.Fields.String("mint")
+	user, _ := event.Fields.String("user")
+	isBuy, _ := event.Fields.Bool("isBuy")
+	solAmount, _ := event.Fields.Uint64("solAmount")
+	tokenAmount, _ := event.Fields.Uint64("tokenAmount")
+
+	s.TokenIn = mint
+	s.Wallet = user
+	s.Exchange = "PUMPFUN"
+
+	if isBuy {
+		s.TokenOut = "So11111111111111111111111111111111111111112"
+		tokenOutDecimals = 9
+		tokenInDecimals = 6
+		s.TokenIn = mint
+		s.AmountOut = strconv.FormatUint(solAmount, 10)
+		s.AmountIn = strconv.FormatUint(tokenAmount, 10)
 	} else {
-		return types.SolSwap{}
+		tokenOutDecimals = 6
+		tokenInDecimals = 9
+		s.TokenIn = "So11111111111111111111111111111111111111112"
+		s.TokenOut = mint
+		s.AmountOut = strconv.FormatUint(tokenAmount, 10)
+		s.AmountIn = strconv.FormatUint(solAmount, 10)
 	}
 
+	// A trade of nothing is not an error, but not a swap either.
 	amountOutFloat, ok := new(big.Float).SetString(s.AmountOut)
 	if !ok || amountOutFloat.Cmp(big.NewFloat(0)) == 0 {
-		return types.SolSwap{}
+		return types.SolSwap{}, nil
 	}
 
 	amountInFloat, ok := new(big.Float).SetString(s.AmountIn)
 	if !ok || amountInFloat.Cmp(big.NewFloat(0)) == 0 {
-		return types.SolSwap{}
+		return types.SolSwap{}, nil
 	}
 
 	amountOutFloat.Quo(amountOutFloat, new(big.Float).SetFloat64(math.Pow10(tokenOutDecimals)))
@@ -79,14 +74,15 @@ func HandlePumpFunSwapData(ixData string) types.SolSwap {
 	s.AmountOut = amountOutFloat.String()
 	s.AmountIn = amountInFloat.String()
 
-	return s
+	return s, nil
 }
 
-func HandlePumpFunSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int) {
+func HandlePumpFunSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
 	currentTransfer := transfers[index]
 
-	if len(currentTransfer.IxAccounts) < 4 || len(accountKeys) < (currentTransfer.IxAccounts)[3] {
-		return types.SolSwap{}, 0
+	pair, err := parentAccount(currentTransfer, accountKeys, "bondingCurve", 3)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
 	incr := 0
@@ -99,54 +95,48 @@ func HandlePumpFunSwaps(index int, transfers []types.SolTransfer, accountKeys []
 
 	// Ignore transfers to the PumpFun Fee account
 	if currentTransfer.ToUserAccount == "CebN5WGQ4jvEPvsVU4EoHEpgzq1VV7AbicfhtW4xC9iM" {
-		return types.SolSwap{}, incr
+		return types.SolSwap{}, incr, nil
 	}
 
-	s := HandlePumpFunSwapData(currentTransfer.EventData)
-	s.Pair = accountKeys[(currentTransfer.IxAccounts)[3]]
+	s, err := HandlePumpFunSwapData(currentTransfer.ParentProgramId, currentTransfer.EventData)
+	if err != nil {
+		return types.SolSwap{}, incr, err
+	}
+	s.Pair = pair
 
-	return s, incr
+	return s, incr, nil
 
 }
 
 func HandlePumpFunNewToken(parsedLogs []types.LogDetails, programId string) []types.PumpFunCreation {
-
-	var pfLogs []string
-
-	var checkLogs func(logs []types.LogDetails)
-	checkLogs = func(logs []types.LogDetails) {
-		for _, logDetail := range logs {
-			for _, log_ := range logDetail.Logs {
-				if strings.Contains(log_, "Program data:") && logDetail.Program == programId {
-					pfLogs = append(pfLogs, log_)
-				}
-			}
-			checkLogs(logDetail.SubLogs)
-		}
-	}
-	checkLogs(parsedLogs)
-	if len(pfLogs) == 0 {
+	program := anchor.Default().Program(programId)
+	if program == nil {
 		return nil
 	}
 
 	var tokens []types.PumpFunCreation
-	for _, pLog := range pfLogs {
-		splitStr := strings.Split(pLog, "Program data: ")[1]
-		bytesData, err := base64.StdEncoding.DecodeString(splitStr)
-		if err != nil {
+	for _, event := range program.DecodeLogEvents(parsedLogs) {
+		if event.Name != "CreateEvent" {
 			continue
 		}
 		newToken := types.PumpFunCreation{}
-		err = newToken.Decode(bytesData)
-		if err != nil {
-			continue
+		newToken.Name, _ = event.Fields.String("name")
+		newToken.Symbol, _ = event.Fields.String("symbol")
+		newToken.Uri, _ = event.Fields.String("uri")
+		if mint, ok := event.Fields.String("mint"); ok {
+			newToken.Mint = common.PublicKeyFromString(mint)
+		}
+		if bondingCurve, ok := event.Fields.String("bondingCurve"); ok {
+			newToken.BondingCurve = common.PublicKeyFromString(bondingCurve)
 		}
-		if newToken.Mint.String() == "" || newToken.Symbol == "" {
+		if user, ok := event.Fields.String("user"); ok {
+			newToken.User = common.PublicKeyFromString(user)
+		}
+		if newToken.Symbol == "" {
 			continue
 		}
 
 		tokens = append(tokens, newToken)
-
 	}
 
 	return tokens
@@ -1,44 +1,122 @@
 package dex
 
 import (
+	"blocsy/internal/solana/anchor"
 	"blocsy/internal/types"
+	"github.com/mr-tron/base58"
+	"strconv"
 )
 
-func HandlePumpFunAmmSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int) {
+// noCreator is the coin creator of pools made before creator fees.
+const noCreator = "11111111111111111111111111111111"
 
-	if index+1 >= len(transfers) {
-		return types.SolSwap{}, 0
+// HandlePumpFunAmmSwaps decodes a PumpSwap trade from the BuyEvent or
+// SellEvent of the pool, since the protocol and creator fee transfers mix
+// with the trade's own. The event reserves are from before the trade.
+func HandlePumpFunAmmSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
+	currentTransfer := transfers[index]
+	program := currentTransfer.ParentProgramId
+
+	inc := 0
+	for index+inc+1 < len(transfers) && sameInstruction(currentTransfer, transfers[index+inc+1]) {
+		inc++
 	}
 
-	currentTransfer := transfers[index]
-	nextTransfer := transfers[index+1]
-	if currentTransfer.ParentProgramId != nextTransfer.ParentProgramId {
-		return types.SolSwap{}, 0
+	pair, err := parentAccount(currentTransfer, accountKeys, "pool", 0)
+	if err != nil {
+		return types.SolSwap{}, inc, err
+	}
+	baseMint, err := parentAccount(currentTransfer, accountKeys, "baseMint", 3)
+	if err != nil {
+		return types.SolSwap{}, inc, err
+	}
+	quoteMint, err := parentAccount(currentTransfer, accountKeys, "quoteMint", 4)
+	if err != nil {
+		return types.SolSwap{}, inc, err
 	}
 
-	if len(currentTransfer.IxAccounts) < 2 || len(accountKeys) < (currentTransfer.IxAccounts)[1] {
-		return types.SolSwap{}, 0
+	if currentTransfer.EventData == "" {
+		return types.SolSwap{}, inc, types.NewDecodeError(program, types.ErrNoEvent, "")
+	}
+	data, err := base58.Decode(currentTransfer.EventData)
+	if err != nil {
+		return types.SolSwap{}, inc, types.NewDecodeError(program, types.ErrBadEncoding, "%v", err)
+	}
+	event, err := anchor.Default().DecodeEvent(program, data)
+	if err != nil {
+		return types.SolSwap{}, inc, err
 	}
 
-	pair := accountKeys[(currentTransfer.IxAccounts)[0]]
+	// Decimals of the mints, from the transfers of the trade.
+	decimals := func(mint string) int {
+		for _, transfer := range transfers[index : index+inc+1] {
+			if transfer.Mint == mint && transfer.Decimals >= 0 {
+				return transfer.Decimals
+			}
+		}
+		if mint == "So11111111111111111111111111111111111111112" {
+			return 9
+		}
+		return -1
+	}
+	baseDecimals, quoteDecimals := decimals(baseMint), decimals(quoteMint)
+	if baseDecimals < 0 {
+		return types.SolSwap{}, inc, types.NewDecodeError(program, types.ErrUnknownDecimals, "%s", baseMint)
+	}
+	if quoteDecimals < 0 {
+		return types.SolSwap{}, inc, types.NewDecodeError(program, types.ErrUnknownDecimals, "%s", quoteMint)
+	}
 
-	wallet := accountKeys[(currentTransfer.IxAccounts)[1]]
+	baseReserve, _ := event.Fields.Uint64("poolBaseTokenReserves")
+	quoteReserve, _ := event.Fields.Uint64("poolQuoteTokenReserves")
+	lpFee, _ := event.Fields.Uint64("lpFee")
+	protocolFee, _ := event.Fields.Uint64("protocolFee")
+	creatorFee, _ := event.Fields.Uint64("coinCreatorFee")
 
-	if currentTransfer.Authority != wallet {
-		currentTransfer = transfers[index+1]
-		nextTransfer = transfers[index]
+	s := types.SolSwap{
+		Pair:     pair,
+		Exchange: "PUMPFUN_AMM",
+		Fee:      uiAmount(lpFee+protocolFee+creatorFee, quoteDecimals),
+	}
+	switch event.Name {
+	case "BuyEvent":
+		baseAmount, _ := event.Fields.Uint64("baseAmountOut")
+		quoteAmount, _ := event.Fields.Uint64("userQuoteAmountIn")
+		quoteToPool, _ := event.Fields.Uint64("quoteAmountInWithLpFee")
+		s.TokenOut, s.AmountOut = quoteMint, uiAmount(quoteAmount, quoteDecimals)
+		s.TokenIn, s.AmountIn = baseMint, uiAmount(baseAmount, baseDecimals)
+		baseReserve = saturatingSub(baseReserve, baseAmount)
+		quoteReserve += quoteToPool
+	case "SellEvent":
+		baseAmount, _ := event.Fields.Uint64("baseAmountIn")
+		quoteAmount, _ := event.Fields.Uint64("userQuoteAmountOut")
+		quoteFromPool, _ := event.Fields.Uint64("quoteAmountOutWithoutLpFee")
+		s.TokenOut, s.AmountOut = baseMint, uiAmount(baseAmount, baseDecimals)
+		s.TokenIn, s.AmountIn = quoteMint, uiAmount(quoteAmount, quoteDecimals)
+		baseReserve += baseAmount
+		quoteReserve = saturatingSub(quoteReserve, quoteFromPool)
+	default:
+		return types.SolSwap{}, inc, types.NewDecodeError(program, types.ErrNoEvent, "%s, not a trade", event.Name)
 	}
+	s.BaseReserve = strconv.FormatUint(baseReserve, 10)
+	s.QuoteReserve = strconv.FormatUint(quoteReserve, 10)
 
-	s := types.SolSwap{
-		Pair:      pair,
-		Exchange:  "PUMPFUN_AMM",
-		Wallet:    wallet,
-		TokenOut:  currentTransfer.Mint,
-		TokenIn:   nextTransfer.Mint,
-		AmountIn:  nextTransfer.Amount,
-		AmountOut: currentTransfer.Amount,
+	if user, ok := event.Fields.String("user"); ok {
+		s.Wallet = user
+	} else if s.Wallet, err = parentAccount(currentTransfer, accountKeys, "user", 1); err != nil {
+		return types.SolSwap{}, inc, err
+	}
+	// Events before creator fees have no creator.
+	if creator, ok := event.Fields.String("coinCreator"); ok && creator != noCreator {
+		s.Creator = creator
 	}
 
-	return s, 1
+	return s, inc, nil
+}
 
+func saturatingSub(a uint64, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
 }
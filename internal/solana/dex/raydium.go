@@ -4,26 +4,26 @@ import (
 	"blocsy/internal/types"
 )
 
-func HandleRaydiumSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int) {
-	if index+1 >= len(transfers) {
-		return types.SolSwap{}, 0
-	}
-
+func HandleRaydiumSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
 	currentTransfer := transfers[index]
-	nextTransfer := transfers[index+1]
-	if currentTransfer.ParentProgramId != nextTransfer.ParentProgramId {
-		return types.SolSwap{}, 0
+	nextTransfer, err := pairedTransfer(index, transfers)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
-	if len(currentTransfer.IxAccounts) < 2 || len(accountKeys) < (currentTransfer.IxAccounts)[1] {
-		return types.SolSwap{}, 0
+	program := currentTransfer.ParentProgramId
+	pair, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 1)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
-	pair := accountKeys[(currentTransfer.IxAccounts)[1]]
-
-	wallet := accountKeys[(currentTransfer.IxAccounts)[16]]
+	walletIndex := 16
 	if len(currentTransfer.IxAccounts) == 18 {
-		wallet = accountKeys[(currentTransfer.IxAccounts)[17]]
+		walletIndex = 17
+	}
+	wallet, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, walletIndex)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
 	if currentTransfer.Authority != wallet {
@@ -41,6 +41,6 @@ func HandleRaydiumSwaps(index int, transfers []types.SolTransfer, accountKeys []
 		AmountOut: currentTransfer.Amount,
 	}
 
-	return s, 1
+	return s, 1, nil
 
 }
@@ -4,23 +4,22 @@ import (
 	"blocsy/internal/types"
 )
 
-func HandleRaydiumCPMMSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int) {
-	if index+1 >= len(transfers) {
-		return types.SolSwap{}, 0
-	}
-
+func HandleRaydiumCPMMSwaps(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
 	currentTransfer := transfers[index]
-	nextTransfer := transfers[index+1]
-	if currentTransfer.ParentProgramId != nextTransfer.ParentProgramId {
-		return types.SolSwap{}, 0
+	nextTransfer, err := pairedTransfer(index, transfers)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
 
-	if len(currentTransfer.IxAccounts) < 2 || len(accountKeys) < (currentTransfer.IxAccounts)[1] {
-		return types.SolSwap{}, 0
+	program := currentTransfer.ParentProgramId
+	pair, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 3)
+	if err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	wallet, err := accountAt(program, accountKeys, currentTransfer.IxAccounts, 0)
+	if err != nil {
+		return types.SolSwap{}, 0, err
 	}
-
-	pair := accountKeys[(currentTransfer.IxAccounts)[3]]
-	wallet := accountKeys[(currentTransfer.IxAccounts)[0]]
 
 	if currentTransfer.Authority != wallet {
 		currentTransfer = transfers[index+1]
@@ -37,6 +36,6 @@ func HandleRaydiumCPMMSwaps(index int, transfers []types.SolTransfer, accountKey
 		AmountOut: currentTransfer.Amount,
 	}
 
-	return s, 1
+	return s, 1, nil
 
 }
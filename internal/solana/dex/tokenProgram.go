@@ -4,14 +4,24 @@ import (
 	"blocsy/internal/types"
 )
 
-func HandleTokenSwaps(instructionData *types.ProcessInstructionData) types.SolSwap {
-	if len(*instructionData.Accounts) < 2 || len(instructionData.AccountKeys) < (*instructionData.Accounts)[1] {
-		return types.SolSwap{}
+func HandleTokenSwaps(instructionData *types.ProcessInstructionData) (types.SolSwap, error) {
+	var program string
+	if instructionData.ProgramId != nil {
+		program = *instructionData.ProgramId
+	}
+
+	wallet, err := accountAt(program, instructionData.AccountKeys, *instructionData.Accounts, 2)
+	if err != nil {
+		return types.SolSwap{}, err
+	}
+	tokenIn, err := accountAt(program, instructionData.AccountKeys, *instructionData.Accounts, 3)
+	if err != nil {
+		return types.SolSwap{}, err
 	}
 
 	transfer1, ok := FindTransfer(instructionData.Transfers, *instructionData.InnerIndex, (instructionData.InnerInstructionIndex)+1)
 	if !ok {
-		return types.SolSwap{}
+		return types.SolSwap{}, missingTransfer(*instructionData.InnerIndex, instructionData.InnerInstructionIndex+1)
 	}
 
 	//log.Printf("finding transfer %d % d", -1, instructionData.InnerInstructionIndex+2)
@@ -22,13 +32,13 @@ func HandleTokenSwaps(instructionData *types.ProcessInstructionData) types.SolSw
 	s := types.SolSwap{
 		Pair:      "",
 		Exchange:  "",
-		Wallet:    instructionData.AccountKeys[(*instructionData.Accounts)[2]],
+		Wallet:    wallet,
 		TokenOut:  "",
-		TokenIn:   instructionData.AccountKeys[(*instructionData.Accounts)[3]],
+		TokenIn:   tokenIn,
 		AmountIn:  transfer1.Amount,
 		AmountOut: "",
 	}
 
-	return s
+	return s, nil
 
 }
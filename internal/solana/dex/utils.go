@@ -1,7 +1,13 @@
 package dex
 
 import (
+	"blocsy/internal/solana/anchor"
 	"blocsy/internal/types"
+	"errors"
+	"github.com/mr-tron/base58"
+	"math"
+	"math/big"
+	"slices"
 )
 
 func FindTransfer(transfers []types.SolTransfer, innerIndex int, ixIndex int) (*types.SolTransfer, bool) {
@@ -13,6 +19,113 @@ func FindTransfer(transfers []types.SolTransfer, innerIndex int, ixIndex int) (*
 	return nil, false
 }
 
+// missingTransfer is the error for a FindTransfer miss. The caller knows the
+// program.
+func missingTransfer(innerIndex int, ixIndex int) error {
+	return types.NewDecodeError("", types.ErrNoCounterpart, "no transfer at %d/%d", innerIndex, ixIndex)
+}
+
+// accountAt is the key of the i-th account of the swap instruction.
+func accountAt(program string, accountKeys []string, ixAccounts []int, i int) (string, error) {
+	if i >= len(ixAccounts) {
+		return "", types.NewDecodeError(program, types.ErrMissingAccounts, "need %d, got %d", i+1, len(ixAccounts))
+	}
+	if ixAccounts[i] < 0 || ixAccounts[i] >= len(accountKeys) {
+		return "", types.NewDecodeError(program, types.ErrAccountIndex, "%d of %d keys", ixAccounts[i], len(accountKeys))
+	}
+	return accountKeys[ixAccounts[i]], nil
+}
+
+// parentAccount is the named account of the instruction that made transfer,
+// when the IDL of its program knows the instruction, or else the one at
+// position i.
+func parentAccount(transfer types.SolTransfer, accountKeys []string, name string, i int) (string, error) {
+	program := transfer.ParentProgramId
+	data, err := base58.Decode(transfer.ParentData)
+	if err != nil {
+		return "", types.NewDecodeError(program, types.ErrBadEncoding, "%v", err)
+	}
+	ix, err := anchor.Default().DecodeInstruction(program, data, accountKeys, transfer.IxAccounts)
+	if errors.Is(err, types.ErrUnknownInstruction) {
+		return accountAt(program, accountKeys, transfer.IxAccounts, i)
+	}
+	if err != nil {
+		return "", err
+	}
+	return ix.Account(name)
+}
+
+// pairedTransfer is the transfer after index, when the same program made it.
+func pairedTransfer(index int, transfers []types.SolTransfer) (types.SolTransfer, error) {
+	program := transfers[index].ParentProgramId
+	if index+1 >= len(transfers) {
+		return types.SolTransfer{}, types.NewDecodeError(program, types.ErrNoCounterpart, "last transfer")
+	}
+	if transfers[index+1].ParentProgramId != program {
+		return types.SolTransfer{}, types.NewDecodeError(program, types.ErrNoCounterpart, "next transfer is from %s", transfers[index+1].ParentProgramId)
+	}
+	return transfers[index+1], nil
+}
+
+// sameInstruction reports whether the same swap instruction made a and b.
+func sameInstruction(a types.SolTransfer, b types.SolTransfer) bool {
+	return a.ParentProgramId == b.ParentProgramId && slices.Equal(a.IxAccounts, b.IxAccounts)
+}
+
+// userLegs sums what the swap instruction behind transfers[index] moved out
+// of and into the user's token accounts, skipping the venue's own transfers,
+// like fees minted as LP. It also returns how many transfers after index
+// belong to the swap.
+func userLegs(index int, transfers []types.SolTransfer, userAccounts ...string) (types.SolSwap, int, error) {
+	current := transfers[index]
+	program := current.ParentProgramId
+
+	var tokenOut, tokenIn string
+	amountOut, amountIn := new(big.Float), new(big.Float)
+	last := index
+	for j := index; j < len(transfers); j++ {
+		transfer := transfers[j]
+		if !sameInstruction(current, transfer) {
+			break
+		}
+		if transfer.Type != "token" {
+			continue
+		}
+		amount, ok := new(big.Float).SetString(transfer.Amount)
+		if !ok {
+			continue
+		}
+		if slices.Contains(userAccounts, transfer.FromTokenAccount) {
+			tokenOut = transfer.Mint
+			amountOut.Add(amountOut, amount)
+		} else if slices.Contains(userAccounts, transfer.ToTokenAccount) {
+			tokenIn = transfer.Mint
+			amountIn.Add(amountIn, amount)
+		} else {
+			continue
+		}
+		last = j
+	}
+	if tokenOut == "" {
+		return types.SolSwap{}, 0, types.NewDecodeError(program, types.ErrNoCounterpart, "no transfer from the user")
+	}
+	if tokenIn == "" {
+		return types.SolSwap{}, 0, types.NewDecodeError(program, types.ErrNoCounterpart, "no transfer to the user")
+	}
+
+	return types.SolSwap{
+		TokenOut:  tokenOut,
+		AmountOut: amountOut.Text('f', -1),
+		TokenIn:   tokenIn,
+		AmountIn:  amountIn.Text('f', -1),
+	}, last - index, nil
+}
+
+// uiAmount is a raw token amount in whole tokens.
+func uiAmount(raw uint64, decimals int) string {
+	return new(big.Float).Quo(new(big.Float).SetUint64(raw), new(big.Float).SetFloat64(math.Pow10(decimals))).Text('f', -1)
+}
+
 func removeTransfer(transfers []types.SolTransfer, innerIndex int) []types.SolTransfer {
 	//for i := len(transfers) - 1; i >= 0; i-- {
 	//	if transfers[i].InnerIndex == innerIndex {
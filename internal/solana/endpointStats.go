@@ -0,0 +1,85 @@
+package solana
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+type endpointStats struct {
+	received   atomic.Int64
+	first      atomic.Int64
+	duplicates atomic.Int64
+	behindNs   atomic.Int64
+	pingNs     atomic.Int64
+	reconnects atomic.Int64
+	lastSlot   atomic.Uint64
+}
+
+type EndpointStats struct {
+	Address    string        `json:"address"`
+	Received   int64         `json:"received"`
+	First      int64         `json:"first"`
+	Duplicates int64         `json:"duplicates"`
+	AvgBehind  time.Duration `json:"avgBehind"`
+	Ping       time.Duration `json:"ping"`
+	Reconnects int64         `json:"reconnects"`
+	LastSlot   uint64        `json:"lastSlot"`
+	SlotLag    uint64        `json:"slotLag"`
+}
+
+func (s *BlockListener) observeSlot(e *GeyserEndpoint, slot uint64) {
+	for {
+		last := e.stats.lastSlot.Load()
+		if slot <= last || e.stats.lastSlot.CompareAndSwap(last, slot) {
+			break
+		}
+	}
+	for {
+		highest := s.highestSlot.Load()
+		if slot <= highest || s.highestSlot.CompareAndSwap(highest, slot) {
+			break
+		}
+	}
+}
+
+// Stats returns a snapshot per endpoint. AvgBehind is how far, on average, an
+// endpoint's copy of a transaction trailed the copy that won the race, and
+// SlotLag how many slots it is behind the most advanced endpoint.
+func (s *BlockListener) Stats() []EndpointStats {
+	highest := s.highestSlot.Load()
+	stats := make([]EndpointStats, 0, len(s.endpoints))
+
+	for _, e := range s.endpoints {
+		st := EndpointStats{
+			Address:    e.address,
+			Received:   e.stats.received.Load(),
+			First:      e.stats.first.Load(),
+			Duplicates: e.stats.duplicates.Load(),
+			Ping:       time.Duration(e.stats.pingNs.Load()),
+			Reconnects: e.stats.reconnects.Load(),
+			LastSlot:   e.stats.lastSlot.Load(),
+		}
+		if st.Duplicates > 0 {
+			st.AvgBehind = time.Duration(e.stats.behindNs.Load() / st.Duplicates)
+		}
+		if highest > st.LastSlot {
+			st.SlotLag = highest - st.LastSlot
+		}
+		stats = append(stats, st)
+	}
+
+	return stats
+}
+
+func (s *BlockListener) reportStats() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, st := range s.Stats() {
+			log.Printf("Geyser %s | received: %d first: %d duplicates: %d avg behind: %s ping: %s slot: %d lag: %d reconnects: %d",
+				st.Address, st.Received, st.First, st.Duplicates, st.AvgBehind, st.Ping, st.LastSlot, st.SlotLag, st.Reconnects)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package solana
 import (
 	"blocsy/internal/types"
 	"context"
+	"time"
 )
 
 type TokensAndPairsRepo interface {
@@ -40,16 +41,41 @@ type TxCacher interface {
 }
 
 type SwapsRepo interface {
+	SlotsRepo
 	MarkBlockProcessed(ctx context.Context, blockNumber int) error
 	InsertSwaps(ctx context.Context, swap []types.SwapLog) error
 	DeleteSwapsUsingTx(ctx context.Context, signature string) error
-	FindMissingBlocks(ctx context.Context) ([][]int, error)
+	FindMissingBlocks(ctx context.Context, window uint64) ([][]int, error)
+	RecordSlot(ctx context.Context, entry types.SlotLedgerEntry) error
+}
+
+type BackfillRepo interface {
+	SwapsRepo
+	PairsRepo
+	RecordFailedBlock(ctx context.Context, blockNumber int, reason string) error
+	FindRetryBlocks(ctx context.Context, limit int, maxAttempts int) ([]int, error)
+	ClearFailedBlock(ctx context.Context, blockNumber int) error
+}
+
+type SlotsRepo interface {
+	UpsertSlotStatus(ctx context.Context, status types.SlotStatus) error
+	FindSlotStatus(ctx context.Context, slot uint64) (string, error)
+	FinalizeSwaps(ctx context.Context) (int64, error)
+	FindDeadSlots(ctx context.Context, since time.Duration) ([]uint64, error)
+	RollbackSlot(ctx context.Context, slot uint64) error
+	PruneSlotHistory(ctx context.Context, keepSlots uint64) error
+}
+
+type PoolStateRepo interface {
+	InsertPoolStates(ctx context.Context, states []types.PoolState) error
 }
 
 type TokensRepo interface {
 	InsertToken(ctx context.Context, token types.Token) error
 	FindToken(ctx context.Context, address string) (*types.Token, error)
 	UpdateTokenSupply(ctx context.Context, address string, changeAmount string, action string) error
+	ApplyTokenSupplyChange(ctx context.Context, block uint64, signature string, ixIndex int, innerIndex int, address string, changeAmount string, action string) error
+	InsertTokenEvents(ctx context.Context, events []types.TokenEvent) error
 	UpdateTokenInfo(ctx context.Context, address string, metadata *types.Metadata) error
 	UpdateTokenDecimals(ctx context.Context, address string, decimals int) error
 }
@@ -69,3 +95,32 @@ type SolanaPairFinder interface {
 	FindPair(ctx context.Context, address string, token_ *string) (*types.Pair, *types.QuoteToken, error)
 	AddToQueue(pair PairProcessorQueue)
 }
+
+// Transport moves encoded blocks between the producers (listener, backfill)
+// and the tx processor.
+type Transport interface {
+	Publish(msg Message) error
+	Consume(ctx context.Context) (<-chan Delivery, error)
+	// DeadLetter parks msg on the dead-letter queue with reason in its headers.
+	DeadLetter(msg Message, reason string) error
+	ConsumeDeadLetters(ctx context.Context) (<-chan Delivery, error)
+	Close() error
+}
+
+// QueueDepth is implemented by transports that can tell how many messages are
+// waiting to be consumed.
+type QueueDepth interface {
+	Depth() (int, error)
+}
+
+// DeadLetterDepth is implemented by transports that can tell how many
+// messages are parked on the dead-letter queue.
+type DeadLetterDepth interface {
+	DeadLetterDepth() (int, error)
+}
+
+// PrefetchSetter is implemented by transports whose consumers can be told to
+// hold fewer unacked deliveries.
+type PrefetchSetter interface {
+	SetPrefetch(n int) error
+}
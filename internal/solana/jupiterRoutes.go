@@ -0,0 +1,154 @@
+package solana
+
+import (
+	"blocsy/internal/solana/anchor"
+	"blocsy/internal/solana/dex"
+	"blocsy/internal/types"
+	"github.com/mr-tron/base58"
+)
+
+// jupiterRoute is a Jupiter route instruction and the trade it makes. Its
+// legs are the transfers of the pool instructions it invoked.
+type jupiterRoute struct {
+	ixIndex int
+	// inner is the index of the route among the inner instructions of
+	// ixIndex, -1 for an outer route. Legs end before the inner index end.
+	inner   int
+	end     int
+	swap    types.SolSwap
+	emitted bool
+	hops    int
+}
+
+func (r *jupiterRoute) contains(transfer types.SolTransfer) bool {
+	ixIndex, innerIndex := transferPosition(transfer)
+	if ixIndex != r.ixIndex {
+		return false
+	}
+	if r.inner < 0 {
+		return true
+	}
+	return innerIndex > r.inner && innerIndex < r.end
+}
+
+// findJupiterRoutes decodes the Jupiter routes of tx, from their SwapEvents.
+// Routes that are a round trip make no trade and are left out.
+func findJupiterRoutes(tx *types.SolanaTx, accountKeys []string, diag *types.ParseDiagnostics) []*jupiterRoute {
+	program := anchor.Default().Program(JUPITER_V6_AGGREGATOR)
+	if program == nil {
+		return nil
+	}
+	isJupiter := func(ix types.Instruction) bool {
+		return ix.ProgramIdIndex < len(accountKeys) && accountKeys[ix.ProgramIdIndex] == JUPITER_V6_AGGREGATOR
+	}
+	decimals := func(mint string) int {
+		if d := FindMintDecimals(tx, mint); d >= 0 {
+			return d
+		}
+		if mint == "So11111111111111111111111111111111111111112" {
+			return 9
+		}
+		return -1
+	}
+
+	var routes []*jupiterRoute
+	var logs []types.LogDetails
+	add := func(ixIndex int, innerIndex int, ix *anchor.Instruction, events []*anchor.Event, end int) {
+		swap, err := dex.HandleJupiterRoute(ix, events, decimals)
+		if err != nil {
+			diag.Skip(ixIndex, innerIndex, JUPITER_V6_AGGREGATOR, err)
+			return
+		}
+		diag.Decode(JUPITER_V6_AGGREGATOR)
+		if swap.Wallet == "" {
+			return
+		}
+		swap.Source = Programs[JUPITER_V6_AGGREGATOR]
+		routes = append(routes, &jupiterRoute{ixIndex: ixIndex, inner: innerIndex, end: end, swap: swap})
+	}
+
+	for i, outer := range tx.Transaction.Message.Instructions {
+		var inner []types.Instruction
+		for _, innerInstruction := range tx.Meta.InnerInstructions {
+			if innerInstruction.Index == i {
+				inner = innerInstruction.Instructions
+			}
+		}
+
+		// The events emitted by self-CPI, and the route instructions, among
+		// the inner instructions.
+		events := make(map[int]*anchor.Event)
+		var innerRoutes []int
+		innerIxs := make(map[int]*anchor.Instruction)
+		for j, ix := range inner {
+			if !isJupiter(ix) {
+				continue
+			}
+			data, err := base58.Decode(ix.Data)
+			if err != nil {
+				continue
+			}
+			if event, err := program.DecodeEvent(data); err == nil {
+				events[j] = event
+				continue
+			}
+			decoded, err := program.DecodeInstruction(data, accountKeys, ix.Accounts)
+			if err != nil {
+				diag.Skip(i, j, JUPITER_V6_AGGREGATOR, err)
+				continue
+			}
+			if dex.IsJupiterRoute(decoded) {
+				innerRoutes = append(innerRoutes, j)
+				innerIxs[j] = decoded
+			}
+		}
+		eventsBetween := func(from int, to int) []*anchor.Event {
+			var between []*anchor.Event
+			for j := from; j < to; j++ {
+				if event, ok := events[j]; ok {
+					between = append(between, event)
+				}
+			}
+			return between
+		}
+
+		if isJupiter(outer) {
+			data, err := base58.Decode(outer.Data)
+			if err != nil {
+				diag.Skip(i, -1, JUPITER_V6_AGGREGATOR, types.NewDecodeError(JUPITER_V6_AGGREGATOR, types.ErrBadEncoding, "%v", err))
+				continue
+			}
+			ix, err := program.DecodeInstruction(data, accountKeys, outer.Accounts)
+			if err != nil {
+				diag.Skip(i, -1, JUPITER_V6_AGGREGATOR, err)
+				continue
+			}
+			if !dex.IsJupiterRoute(ix) {
+				continue
+			}
+			routeEvents := eventsBetween(0, len(inner))
+			// Program versions before the self-CPI events logged them.
+			if len(routeEvents) == 0 {
+				if logs == nil {
+					logs = GetLogs(tx.Meta.LogMessages)
+				}
+				if len(logs) == len(tx.Transaction.Message.Instructions) {
+					routeEvents = program.DecodeLogEvents(logs[i : i+1])
+				}
+			}
+			add(i, -1, ix, routeEvents, len(inner))
+			continue
+		}
+
+		// Routes invoked by another program, each up to the next one.
+		for k, j := range innerRoutes {
+			end := len(inner)
+			if k+1 < len(innerRoutes) {
+				end = innerRoutes[k+1]
+			}
+			add(i, j, innerIxs[j], eventsBetween(j+1, end), end)
+		}
+	}
+
+	return routes
+}
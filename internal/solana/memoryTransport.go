@@ -0,0 +1,117 @@
+package solana
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const memoryQueueSize = 10000
+
+var (
+	memoryQueuesMu sync.Mutex
+	memoryQueues   = make(map[string]chan Message)
+)
+
+// MemoryTransport is an in-process queue. Every MemoryTransport created for
+// the same queue name in one process shares the same buffer, so a listener and
+// a tx processor can run side by side without a broker.
+type MemoryTransport struct {
+	queue       chan Message
+	deadLetters chan Message
+	closed      chan struct{}
+	once        sync.Once
+}
+
+func NewMemoryTransport(queue string) *MemoryTransport {
+	return &MemoryTransport{
+		queue:       memoryQueue(queue),
+		deadLetters: memoryQueue(queue + deadLetterSuffix),
+		closed:      make(chan struct{}),
+	}
+}
+
+func memoryQueue(name string) chan Message {
+	memoryQueuesMu.Lock()
+	defer memoryQueuesMu.Unlock()
+
+	q, ok := memoryQueues[name]
+	if !ok {
+		q = make(chan Message, memoryQueueSize)
+		memoryQueues[name] = q
+	}
+	return q
+}
+
+func (t *MemoryTransport) Publish(msg Message) error {
+	return t.publish(t.queue, msg)
+}
+
+func (t *MemoryTransport) Depth() (int, error) {
+	return len(t.queue), nil
+}
+
+func (t *MemoryTransport) DeadLetterDepth() (int, error) {
+	return len(t.deadLetters), nil
+}
+
+func (t *MemoryTransport) DeadLetter(msg Message, reason string) error {
+	return t.publish(t.deadLetters, deadLetterMessage(msg, reason))
+}
+
+func (t *MemoryTransport) publish(queue chan Message, msg Message) error {
+	select {
+	case queue <- msg:
+		return nil
+	case <-t.closed:
+		return fmt.Errorf("memory transport closed")
+	}
+}
+
+func (t *MemoryTransport) Consume(ctx context.Context) (<-chan Delivery, error) {
+	return t.consume(ctx, t.queue), nil
+}
+
+func (t *MemoryTransport) ConsumeDeadLetters(ctx context.Context) (<-chan Delivery, error) {
+	return t.consume(ctx, t.deadLetters), nil
+}
+
+func (t *MemoryTransport) consume(ctx context.Context, queue chan Message) <-chan Delivery {
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg := <-queue:
+				delivery := Delivery{
+					Message: msg,
+					nack: func(requeue bool) error {
+						if requeue {
+							return t.publish(queue, msg)
+						}
+						return nil
+					},
+				}
+
+				select {
+				case out <- delivery:
+				case <-ctx.Done():
+					return
+				case <-t.closed:
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-t.closed:
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func (t *MemoryTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
@@ -15,7 +15,7 @@ func DecodeMetaplexData(encodedData string) (*struct {
 
 	decodedBytes, err := base58.Decode(encodedData)
 	if err != nil {
-		return nil, err
+		return nil, types.NewDecodeError(METAPLEX_TOKEN_METDATA, types.ErrBadEncoding, "%v", err)
 	}
 
 	deserialized := struct {
@@ -27,7 +27,7 @@ func DecodeMetaplexData(encodedData string) (*struct {
 
 	err = borsh.Deserialize(&deserialized, decodedBytes)
 	if err != nil {
-		return nil, err
+		return nil, types.NewDecodeError(METAPLEX_TOKEN_METDATA, types.ErrShortData, "%v", err)
 	}
 
 	return &deserialized, nil
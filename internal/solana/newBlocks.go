@@ -5,19 +5,28 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/mr-tron/base58"
 	pb "github.com/rpcpool/yellowstone-grpc/examples/golang/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"log"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
 	"time"
 )
 
+const defaultDedupWindow = 250000
+
 type tokenAuth struct{ token string }
 
 func (t tokenAuth) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
@@ -31,79 +40,182 @@ var kacp = keepalive.ClientParameters{
 	PermitWithoutStream: true,
 }
 
-func NewBlockListener(grpc string, qHandler *QueueHandler, authToken string) *BlockListener {
-	return &BlockListener{
-		Client:       nil,
-		Subscription: nil,
-		grpcAddress:  grpc,
+// NewBlockListener subscribes to every address in grpcAddresses at once.
+// authTokens are matched to addresses by position; a single token is shared
+// by all endpoints.
+func NewBlockListener(grpcAddresses []string, qHandler *QueueHandler, authTokens []string, checkpoint *SlotCheckpoint, backfill *BackfillService) *BlockListener {
+	if checkpoint == nil {
+		checkpoint = NewSlotCheckpoint("")
+	}
+
+	endpoints := make([]*GeyserEndpoint, 0, len(grpcAddresses))
+	for i, address := range grpcAddresses {
+		token := ""
+		if i < len(authTokens) {
+			token = authTokens[i]
+		} else if len(authTokens) > 0 {
+			token = authTokens[0]
+		}
+		endpoints = append(endpoints, &GeyserEndpoint{
+			address:   address,
+			authToken: token,
+		})
+	}
+
+	window := defaultDedupWindow
+	if v, err := strconv.Atoi(os.Getenv("SOL_GRPC_DEDUP_WINDOW")); err == nil && v > 0 {
+		window = v
+	}
+
+	batchSize, _ := strconv.Atoi(os.Getenv("SOL_GRPC_SLOT_BATCH_SIZE"))
+	flushAfter, _ := time.ParseDuration(os.Getenv("SOL_GRPC_SLOT_FLUSH_AFTER"))
+
+	level := os.Getenv("SOL_GRPC_COMMITMENT")
+	if level != types.CommitmentProcessed {
+		level = types.CommitmentConfirmed
+	}
+
+	s := &BlockListener{
+		endpoints:    endpoints,
 		queueHandler: qHandler,
-		authToken:    authToken,
-		pingId:       0,
+		backfill:     backfill,
+		checkpoint:   checkpoint,
+		seen:         NewSignatureWindow(window),
+		level:        level,
 	}
+	filters, err := LoadTxFilters(os.Getenv("SOL_GRPC_FILTERS"))
+	if err != nil {
+		log.Fatalf("Failed to load tx filters: %v", err)
+	}
+	s.filters = filters
+	s.routes = make(map[string]*txRoute)
+	s.filterQueues = make(map[string]string, len(filters))
+	for _, f := range filters {
+		s.filterQueues[f.Name] = f.Queue
+		if _, ok := s.routes[f.Queue]; ok {
+			continue
+		}
+
+		route := &txRoute{queue: f.Queue, handler: qHandler}
+		if f.Queue != queueName && qHandler != nil {
+			transport, err := NewTransportFor(f.Queue)
+			if err != nil {
+				log.Fatalf("Failed to create transport for %s: %v", f.Queue, err)
+			}
+			route.handler = NewQueueHandlerWithTransport(nil, nil, transport)
+		}
+		route.batcher = NewSlotBatcher(batchSize, flushAfter, func(block types.BlockData) error {
+			return s.publishBlock(route, block)
+		})
+		s.routes[f.Queue] = route
+	}
+	s.commitment = NewCommitmentTracker(s.publishSlotStatus)
+
+	if os.Getenv("SOL_GRPC_POOL_STATE") == "true" {
+		transport, err := NewPoolStateTransport()
+		if err != nil {
+			log.Fatalf("Failed to create pool state transport: %v", err)
+		}
+		s.pools = NewPoolStateTracker(transport)
+	}
+	return s
 }
 
 func (s *BlockListener) Listen() error {
 	log.SetFlags(0)
 	flag.Parse()
 
+	if len(s.endpoints) == 0 {
+		return fmt.Errorf("no geyser endpoints configured")
+	}
+
+	slot, err := s.checkpoint.Load()
+	if err != nil {
+		log.Printf("Failed to load slot checkpoint: %v", err)
+	} else if slot > 0 {
+		log.Printf("Loaded slot checkpoint %d", slot)
+	}
+
+	go s.reportStats()
+	for _, route := range s.routes {
+		go route.batcher.Run()
+	}
+	if s.pools != nil {
+		go s.pools.Run()
+	}
+
+	var wg sync.WaitGroup
+	for _, e := range s.endpoints {
+		wg.Add(1)
+		go func(e *GeyserEndpoint) {
+			defer wg.Done()
+			s.listenEndpoint(e)
+		}(e)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (s *BlockListener) listenEndpoint(e *GeyserEndpoint) {
 	for {
-		conn, err := s.grpcConnect(s.grpcAddress)
+		conn, err := s.grpcConnect(e)
 		if err != nil {
-			log.Printf("Failed to connect to %s. Retrying... | err: %v", s.grpcAddress, err)
+			log.Printf("Failed to connect to %s. Retrying... | err: %v", e.address, err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
 		if conn == nil {
-			log.Printf("Failed to connect to %s. Retrying...", s.grpcAddress)
+			log.Printf("Failed to connect to %s. Retrying...", e.address)
 			time.Sleep(5 * time.Second)
 			continue
 		}
 
-		err = s.grpcSubscribe(conn)
+		err = s.grpcSubscribe(e, conn)
+		conn.Close()
 		if err != nil {
-			log.Printf("Error in grpcSubscribe: %v. Reconnecting...", err)
-			conn.Close()
+			e.stats.reconnects.Add(1)
+			log.Printf("Error in grpcSubscribe (%s): %v. Reconnecting...", e.address, err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
-		defer conn.Close()
-		break
+		return
 	}
-
-	return nil
 }
 
-func (s *BlockListener) grpcConnect(addr string) (*grpc.ClientConn, error) {
+func (s *BlockListener) grpcConnect(e *GeyserEndpoint) (*grpc.ClientConn, error) {
 	pool, _ := x509.SystemCertPool()
 	opts := []grpc.DialOption{
 		grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "")),
 		grpc.WithKeepaliveParams(kacp),
-		grpc.WithPerRPCCredentials(tokenAuth{token: s.authToken}),
+		grpc.WithPerRPCCredentials(tokenAuth{token: e.authToken}),
 		grpc.WithDefaultCallOptions(
 			grpc.MaxCallRecvMsgSize(1024*1024*1024),
 			grpc.UseCompressor(gzip.Name),
 		),
 	}
-	return grpc.NewClient(addr, opts...)
+	return grpc.NewClient(e.address, opts...)
 }
 
-func (s *BlockListener) grpcSubscribe(conn *grpc.ClientConn) error {
+func (s *BlockListener) grpcSubscribe(e *GeyserEndpoint, conn *grpc.ClientConn) error {
 	var err error
 	client := pb.NewGeyserClient(conn)
-	s.Client = client
+	e.Client = client
 
-	subscription, err := s.prepareSubscription()
+	fromSlot := s.resumeSlot(e)
+	subscription, err := s.prepareSubscription(fromSlot)
 	if err != nil {
 		return fmt.Errorf("failed to prepare subscription: %v", err)
 	}
 
-	ctx := context.Background()
-	if s.authToken != "" {
-		md := metadata.New(map[string]string{"x-token": s.authToken})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if e.authToken != "" {
+		md := metadata.New(map[string]string{"x-token": e.authToken})
 		ctx = metadata.NewOutgoingContext(ctx, md)
 	}
 
-	stream, err := client.Subscribe(context.Background())
+	stream, err := client.Subscribe(ctx)
 	if err != nil {
 		return err
 	}
@@ -111,91 +223,139 @@ func (s *BlockListener) grpcSubscribe(conn *grpc.ClientConn) error {
 		return err
 	}
 
-	s.Subscription = stream
-	go s.keepAlive(ctx)
-	log.Printf("Subscribed to %s", s.grpcAddress)
-	var blockNumber uint64
-	var blockTime int64
+	e.Subscription = stream
+	go s.keepAlive(ctx, e)
+	log.Printf("Subscribed to %s", e.address)
+	received := false
 
 	for {
 
 		upd, err := stream.Recv()
 		if err != nil {
-			log.Printf("Error in grpcSubscribe: %v. Reconnecting...", err)
+			// A stream that dies before delivering anything after asking for
+			// from_slot means the node can no longer replay that far back.
+			if fromSlot > 0 && !received && status.Code(err) != codes.Unavailable {
+				log.Printf("Replay from slot %d rejected by %s: %v", fromSlot, e.address, err)
+				e.replayFailed = true
+			}
 			return err // reconnect outside
 		}
-
-		var capturedTS = time.Now().Unix()
-		var solanaTx types.SolanaTx
+		received = true
 
 		if tx := upd.GetTransaction(); tx != nil {
-			blockNumber = tx.Slot
-
-			var decodedSignatures []string
-			for _, sig := range tx.Transaction.Transaction.Signatures {
-				b58Sig := base58.Encode(sig)
-				decodedSignatures = append(decodedSignatures, b58Sig)
+			s.closeGap(e, tx.Slot)
+			s.observeSlot(e, tx.Slot)
+			e.stats.received.Add(1)
+
+			signature := base58.Encode(tx.Transaction.Signature)
+			first, behind := s.seen.Observe(signature, time.Now())
+			if !first {
+				e.stats.duplicates.Add(1)
+				e.stats.behindNs.Add(int64(behind))
+				continue
 			}
+			e.stats.first.Add(1)
 
-			var decodedAccountKeys []string
-			for _, key := range tx.Transaction.Transaction.Message.AccountKeys {
-				b58key := base58.Encode(key)
-				decodedAccountKeys = append(decodedAccountKeys, b58key)
-			}
-
-			for i, atl := range tx.Transaction.Transaction.Message.AddressTableLookups {
-				if i >= len(solanaTx.Transaction.Message.AddressTableLookups) {
-					solanaTx.Transaction.Message.AddressTableLookups = append(solanaTx.Transaction.Message.AddressTableLookups, types.AddressTableLookup{})
-				}
-				solanaTx.Transaction.Message.AddressTableLookups[i] = types.AddressTableLookup{
-					AccountKey:      base58.Encode(atl.AccountKey),
-					WritableIndexes: convertToIntSlice(atl.WritableIndexes),
-					ReadonlyIndexes: convertToIntSlice(atl.ReadonlyIndexes),
-				}
+			solanaTx := convertTransaction(tx)
+			if len(solanaTx.Transaction.Signatures) > 0 {
+				s.routeTransaction(upd.GetFilters(), solanaTx, tx.Slot)
 			}
-
-			solanaTx.Transaction.Signatures = decodedSignatures
-			solanaTx.Transaction.Message.AccountKeys = decodedAccountKeys
-			solanaTx.Transaction.Message.RecentBlockhash = base58.Encode(tx.Transaction.Transaction.Message.RecentBlockhash)
-			solanaTx.Transaction.Message.Instructions = make([]types.Instruction, len(tx.Transaction.Transaction.Message.Instructions))
-			solanaTx.Transaction.Message.Instructions = convertToInstructions(tx.Transaction.Transaction.Message.Instructions)
-
-			solanaTx.Meta.LogMessages = tx.Transaction.Meta.LogMessages
-			solanaTx.Meta.LoadedAddresses = types.LoadedAddresses{
-				Readonly: convertToBase58Strings(tx.Transaction.Meta.LoadedReadonlyAddresses),
-				Writable: convertToBase58Strings(tx.Transaction.Meta.LoadedWritableAddresses),
+			if s.pools != nil {
+				s.pools.ObserveTransaction(solanaTx, tx.Slot)
 			}
-			solanaTx.Meta.PreTokenBalances = convertToTokenBalanceSlice(tx.Transaction.Meta.PreTokenBalances)
-			solanaTx.Meta.PostTokenBalances = convertToTokenBalanceSlice(tx.Transaction.Meta.PostTokenBalances)
-			solanaTx.Meta.PreBalances = tx.Transaction.Meta.PreBalances
-			solanaTx.Meta.PostBalances = tx.Transaction.Meta.PostBalances
-			solanaTx.Meta.Fee = int64(tx.Transaction.Meta.Fee)
-			solanaTx.Meta.Err = &types.TransactionError{}
-
-			solanaTx.Meta.InnerInstructions = make([]types.InnerInstruction, len(tx.Transaction.Meta.InnerInstructions))
-			for i, instr := range tx.Transaction.Meta.InnerInstructions {
-				solanaTx.Meta.InnerInstructions[i] = types.InnerInstruction{
-					Index:        int(instr.Index),
-					Instructions: convertToInnerInstructions(instr.Instructions),
-				}
+		}
+		if acc := upd.GetAccount(); acc != nil && acc.Account != nil && s.pools != nil {
+			s.pools.ObserveAccount(
+				base58.Encode(acc.Account.Owner),
+				base58.Encode(acc.Account.Pubkey),
+				acc.Account.Data,
+				acc.Slot,
+				acc.Account.WriteVersion,
+			)
+		}
+		if slot := upd.GetSlot(); slot != nil {
+			if status := slotStatusName(slot.Status); status != "" {
+				s.commitment.Observe(slot.Slot, slot.GetParent(), status)
 			}
 		}
 		if bm := upd.GetBlockMeta(); bm != nil {
-			blockTime = bm.BlockTime.Timestamp
+			var blockTime int64
+			if bm.BlockTime != nil {
+				blockTime = bm.BlockTime.Timestamp
+			}
+			var publishErr error
+			for _, route := range s.routes {
+				publishErr = errors.Join(publishErr, route.batcher.Complete(bm.Slot, blockTime))
+			}
+			s.closeGap(e, bm.Slot)
+			s.observeSlot(e, bm.Slot)
+			// The checkpoint only covers slots that reached the queue.
+			if publishErr != nil {
+				s.slotFailed(bm.Slot, publishErr)
+			} else if err = s.checkpoint.Save(bm.Slot); err != nil {
+				log.Printf("Failed to save slot checkpoint %d: %v", bm.Slot, err)
+			}
 		}
+	}
 
-		if len(solanaTx.Transaction.Signatures) > 0 {
-			if blockTime == 0 {
-				blockTime = capturedTS
-			}
+}
+
+func convertTransaction(tx *pb.SubscribeUpdateTransaction) types.SolanaTx {
+	var solanaTx types.SolanaTx
+
+	var decodedSignatures []string
+	for _, sig := range tx.Transaction.Transaction.Signatures {
+		b58Sig := base58.Encode(sig)
+		decodedSignatures = append(decodedSignatures, b58Sig)
+	}
 
-			s.HandleTransaction(solanaTx, blockTime, blockNumber)
+	var decodedAccountKeys []string
+	for _, key := range tx.Transaction.Transaction.Message.AccountKeys {
+		b58key := base58.Encode(key)
+		decodedAccountKeys = append(decodedAccountKeys, b58key)
+	}
+
+	for i, atl := range tx.Transaction.Transaction.Message.AddressTableLookups {
+		if i >= len(solanaTx.Transaction.Message.AddressTableLookups) {
+			solanaTx.Transaction.Message.AddressTableLookups = append(solanaTx.Transaction.Message.AddressTableLookups, types.AddressTableLookup{})
+		}
+		solanaTx.Transaction.Message.AddressTableLookups[i] = types.AddressTableLookup{
+			AccountKey:      base58.Encode(atl.AccountKey),
+			WritableIndexes: convertToIntSlice(atl.WritableIndexes),
+			ReadonlyIndexes: convertToIntSlice(atl.ReadonlyIndexes),
+		}
+	}
+
+	solanaTx.Transaction.Signatures = decodedSignatures
+	solanaTx.Transaction.Message.AccountKeys = decodedAccountKeys
+	solanaTx.Transaction.Message.RecentBlockhash = base58.Encode(tx.Transaction.Transaction.Message.RecentBlockhash)
+	solanaTx.Transaction.Message.Instructions = make([]types.Instruction, len(tx.Transaction.Transaction.Message.Instructions))
+	solanaTx.Transaction.Message.Instructions = convertToInstructions(tx.Transaction.Transaction.Message.Instructions)
+
+	solanaTx.Meta.LogMessages = tx.Transaction.Meta.LogMessages
+	solanaTx.Meta.LoadedAddresses = types.LoadedAddresses{
+		Readonly: convertToBase58Strings(tx.Transaction.Meta.LoadedReadonlyAddresses),
+		Writable: convertToBase58Strings(tx.Transaction.Meta.LoadedWritableAddresses),
+	}
+	solanaTx.Meta.PreTokenBalances = convertToTokenBalanceSlice(tx.Transaction.Meta.PreTokenBalances)
+	solanaTx.Meta.PostTokenBalances = convertToTokenBalanceSlice(tx.Transaction.Meta.PostTokenBalances)
+	solanaTx.Meta.PreBalances = tx.Transaction.Meta.PreBalances
+	solanaTx.Meta.PostBalances = tx.Transaction.Meta.PostBalances
+	solanaTx.Meta.Fee = int64(tx.Transaction.Meta.Fee)
+	solanaTx.Meta.Err = &types.TransactionError{}
+
+	solanaTx.Meta.InnerInstructions = make([]types.InnerInstruction, len(tx.Transaction.Meta.InnerInstructions))
+	for i, instr := range tx.Transaction.Meta.InnerInstructions {
+		solanaTx.Meta.InnerInstructions[i] = types.InnerInstruction{
+			Index:        int(instr.Index),
+			Instructions: convertToInnerInstructions(instr.Instructions),
 		}
 	}
 
+	return solanaTx
 }
 
-func (s *BlockListener) keepAlive(ctx context.Context) {
+func (s *BlockListener) keepAlive(ctx context.Context, e *GeyserEndpoint) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
@@ -204,62 +364,205 @@ func (s *BlockListener) keepAlive(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if s.Subscription != nil {
-				s.pingId++
+			if e.Subscription != nil {
+				e.pingId++
 				ping := &pb.SubscribeRequest{
 					Ping: &pb.SubscribeRequestPing{
-						Id: s.pingId,
+						Id: e.pingId,
 					},
 				}
-				err := s.Subscription.Send(ping)
+				err := e.Subscription.Send(ping)
 				if err != nil {
 					return
 				}
 
-				_, err = s.Client.Ping(ctx, &pb.PingRequest{
-					Count: s.pingId,
+				start := time.Now()
+				_, err = e.Client.Ping(ctx, &pb.PingRequest{
+					Count: e.pingId,
 				})
 				if err != nil {
 					return
 				}
+				e.stats.pingNs.Store(int64(time.Since(start)))
 			}
 		}
 	}
 }
 
-func (s *BlockListener) prepareSubscription() (*pb.SubscribeRequest, error) {
-	voteFalse, failedFalse := false, false
-	var stringArray []string
+// resumeSlot returns the slot to replay from, or 0 to start at the tip. When a
+// replay was rejected the endpoint is marked as resyncing so the hole can be
+// backfilled over RPC once the live stream reports where it picked up.
+func (s *BlockListener) resumeSlot(e *GeyserEndpoint) uint64 {
+	last := s.checkpoint.Slot()
+	if last == 0 {
+		return 0
+	}
+
+	if e.replayFailed {
+		e.replayFailed = false
+		e.resyncing = true
+		return 0
+	}
+
+	return last + 1
+}
+
+// closeGap backfills the slots between the shared checkpoint and the first
+// live slot of a resyncing endpoint. Other endpoints may have kept the
+// checkpoint current in the meantime, in which case there is nothing to do.
+func (s *BlockListener) closeGap(e *GeyserEndpoint, slot uint64) {
+	if !e.resyncing {
+		return
+	}
+	e.resyncing = false
+
+	from := s.checkpoint.Slot() + 1
+	if from >= slot {
+		return
+	}
+
+	// The first live slot may be partial, so it is backfilled as well. Swap
+	// inserts ignore duplicates, which makes the overlap harmless.
+	if s.backfill == nil {
+		log.Printf("Missed slots %d --> %d but no backfill service is configured", from, slot)
+		return
+	}
+
+	log.Printf("Missed slots %d --> %d, handing over to backfill", from, slot)
+	go func() {
+		if err := s.backfill.HandleBackFill(context.Background(), int(from), int(slot), true); err != nil {
+			log.Printf("Failed to backfill slots %d --> %d: %v", from, slot, err)
+		}
+	}()
+}
+
+func (s *BlockListener) prepareSubscription(fromSlot uint64) (*pb.SubscribeRequest, error) {
+	filterByCommitment, interslotUpdates := false, true
+
+	transactions := make(map[string]*pb.SubscribeRequestFilterTransactions, len(s.filters))
+	for _, f := range s.filters {
+		vote, failed := f.Vote, f.Failed
+		transactions[f.Name] = &pb.SubscribeRequestFilterTransactions{
+			Vote:            &vote,
+			Failed:          &failed,
+			AccountInclude:  f.AccountInclude,
+			AccountRequired: f.AccountRequired,
+			AccountExclude:  f.AccountExclude,
+		}
+	}
 
 	sub := &pb.SubscribeRequest{
-		Transactions: map[string]*pb.SubscribeRequestFilterTransactions{
-			"tx_sub": {
-				Vote:            &voteFalse,
-				Failed:          &failedFalse,
-				AccountExclude:  stringArray,
-				AccountInclude:  stringArray,
-				AccountRequired: stringArray,
-				Signature:       nil,
-			},
-		},
+		Transactions: transactions,
 		BlocksMeta: map[string]*pb.SubscribeRequestFilterBlocksMeta{
 			"block_meta": {},
 		},
+		Slots: map[string]*pb.SubscribeRequestFilterSlots{
+			"slots": {
+				FilterByCommitment: &filterByCommitment,
+				InterslotUpdates:   &interslotUpdates,
+			},
+		},
+	}
+	if s.pools != nil {
+		sub.Accounts = poolAccountFilters()
+	}
+
+	commitment := pb.CommitmentLevel_CONFIRMED
+	if s.level == types.CommitmentProcessed {
+		commitment = pb.CommitmentLevel_PROCESSED
+	}
+	sub.Commitment = &commitment
+
+	if fromSlot > 0 {
+		sub.FromSlot = &fromSlot
 	}
-	confirmed := pb.CommitmentLevel_CONFIRMED
-	sub.Commitment = &confirmed
 
 	b, _ := json.Marshal(sub)
 	log.Printf("Subscription request: %s", b)
 	return sub, nil
 }
 
-func (s *BlockListener) HandleTransaction(transaction types.SolanaTx, blockTime int64, block uint64) {
+// poolAccountFilters subscribes to the pool state accounts of every program
+// PoolStateTracker can decode, one filter per program.
+func poolAccountFilters() map[string]*pb.SubscribeRequestFilterAccounts {
+	filters := make(map[string]*pb.SubscribeRequestFilterAccounts, len(poolAccountSizes))
+	for program, size := range poolAccountSizes {
+		filters["pools_"+Programs[program]] = &pb.SubscribeRequestFilterAccounts{
+			Owner: []string{program},
+			Filters: []*pb.SubscribeRequestFilterAccountsFilter{
+				{Filter: &pb.SubscribeRequestFilterAccountsFilter_Datasize{Datasize: size}},
+			},
+		}
+	}
+	return filters
+}
+
+// HandleTransaction buffers the transaction for the tx queue until its slot is
+// complete.
+func (s *BlockListener) HandleTransaction(transaction types.SolanaTx, block uint64) {
+	if route, ok := s.routes[queueName]; ok {
+		route.batcher.Add(block, transaction)
+	}
+}
+
+// routeTransaction buffers the transaction once for every queue one of the
+// filters it matched is routed to.
+func (s *BlockListener) routeTransaction(filters []string, transaction types.SolanaTx, block uint64) {
+	var queues []string
+	for _, name := range filters {
+		queue, ok := s.filterQueues[name]
+		if !ok || slices.Contains(queues, queue) {
+			continue
+		}
+		queues = append(queues, queue)
+		s.routes[queue].batcher.Add(block, transaction)
+	}
+}
+
+func (s *BlockListener) publishBlock(route *txRoute, block types.BlockData) error {
+	block.Commitment = s.level
+	block.Source = types.IngestSourceLive
+	if route.handler == nil {
+		return nil
+	}
+	if err := route.handler.AddToSolanaQueue(block); err != nil {
+		log.Printf("Failed to queue slot %d to %s: %v", block.Block, route.queue, err)
+		return fmt.Errorf("failed to queue slot %d to %s: %w", block.Block, route.queue, err)
+	}
+	return nil
+}
+
+// slotFailed records a slot that did not reach the queue for the backfill to
+// retry, since a later checkpoint will not cover it again.
+func (s *BlockListener) slotFailed(slot uint64, reason error) {
+	if s.backfill == nil {
+		log.Printf("Slot %d was not queued and no backfill service is configured: %v", slot, reason)
+		return
+	}
+
+	log.Printf("Slot %d was not queued, recording it for backfill", slot)
+	go s.backfill.recordFailedBlock(context.Background(), int(slot), reason)
+}
+
+func (s *BlockListener) publishSlotStatus(status types.SlotStatus) {
 	if s.queueHandler != nil {
-		s.queueHandler.AddToSolanaQueue(types.BlockData{
-			Transactions: []types.SolanaTx{transaction},
-			Block:        block,
-			Timestamp:    blockTime,
-		})
+		if err := s.queueHandler.AddSlotStatus(status); err != nil {
+			log.Printf("Failed to queue status of slot %d: %v", status.Slot, err)
+		}
+	}
+}
+
+func slotStatusName(status pb.SlotStatus) string {
+	switch status {
+	case pb.SlotStatus_SLOT_PROCESSED:
+		return types.CommitmentProcessed
+	case pb.SlotStatus_SLOT_CONFIRMED:
+		return types.CommitmentConfirmed
+	case pb.SlotStatus_SLOT_FINALIZED:
+		return types.CommitmentFinalized
+	case pb.SlotStatus_SLOT_DEAD:
+		return types.SlotDead
+	default:
+		return ""
 	}
 }
@@ -2,7 +2,9 @@ package solana
 
 import (
 	"blocsy/internal/types"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -45,7 +47,7 @@ func (n *Node) GetBlockMessage(ctx context.Context, blockNumber int) (*types.HTT
         ]
     }`, blockNumber)
 
-	req, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, strings.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request: %v", err)
 	}
@@ -91,7 +93,7 @@ func (n *Node) GetTx(ctx context.Context, hash string) (*types.SolanaTx, error)
         ]
     }`, hash)
 
-	req, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, strings.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request: %v", err)
 	}
@@ -120,6 +122,58 @@ func (n *Node) GetTx(ctx context.Context, hash string) (*types.SolanaTx, error)
 	return &txMessage.Result, nil
 }
 
+// GetSignaturesForAddress returns up to limit signatures involving address,
+// newest first, starting below before and stopping at until when they are set.
+func (n *Node) GetSignaturesForAddress(ctx context.Context, address string, before string, until string, limit int) ([]types.SignatureInfo, error) {
+	config := map[string]interface{}{
+		"commitment": "confirmed",
+		"limit":      limit,
+	}
+	if before != "" {
+		config["before"] = before
+	}
+	if until != "" {
+		config["until"] = until
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "1",
+		"method":  "getSignaturesForAddress",
+		"params":  []interface{}{address, config},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request: %v", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := n.sendRequestWithRetry(req, 3)
+	if err != nil {
+		return nil, fmt.Errorf("error making request after retries: %v", err)
+	}
+
+	if resp == nil {
+		return nil, fmt.Errorf("received nil response")
+	}
+
+	defer resp.Body.Close()
+
+	message := types.HTTPSignaturesMessage{}
+	if err = json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return nil, fmt.Errorf("error decoding message: %w", err)
+	}
+	if message.Error != nil {
+		return nil, fmt.Errorf("rpc error %d: %s", message.Error.Code, message.Error.Message)
+	}
+
+	return message.Result, nil
+}
+
 func (n *Node) sendRequestWithRetry(req *http.Request, retries int) (*http.Response, error) {
 	var res *http.Response
 	var err error
@@ -0,0 +1,163 @@
+package solana
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/time/rate"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultNodeRPS = 10
+	// nodeHealthWeight is the weight of the newest sample in the latency and
+	// error rate moving averages.
+	nodeHealthWeight = 0.2
+	nodeEjectAfter   = 3
+	nodeEjectBase    = 30 * time.Second
+	nodeEjectMax     = 10 * time.Minute
+)
+
+var errNoNodes = errors.New("no nodes left to try")
+
+// NodePool routes RPC requests to the healthiest node that is within its rate
+// limit. Nodes failing nodeEjectAfter times in a row are ejected for a growing
+// period and then given another chance.
+type NodePool struct {
+	nodes []*Node
+	mu    sync.Mutex
+}
+
+// NewNodePool limits every node to SOL_HTTPS_NODE_RPS requests per second.
+func NewNodePool(nodes []*Node) *NodePool {
+	rps, err := strconv.ParseFloat(os.Getenv("SOL_HTTPS_NODE_RPS"), 64)
+	if err != nil || rps <= 0 {
+		rps = defaultNodeRPS
+	}
+
+	for _, n := range nodes {
+		n.limiter = rate.NewLimiter(rate.Limit(rps), max(1, int(rps)))
+	}
+	return &NodePool{nodes: nodes}
+}
+
+// Pick returns the best node not in tried. When every node is ejected and
+// none was tried yet it waits for the first one to come back; a request that
+// already failed over gives up instead.
+func (p *NodePool) Pick(ctx context.Context, tried map[*Node]bool) (*Node, error) {
+	for {
+		healthy, wait := p.rank(tried, time.Now())
+		if len(healthy) > 0 {
+			for _, n := range healthy {
+				if n.limiter.Allow() {
+					return n, nil
+				}
+			}
+			if err := healthy[0].limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+			return healthy[0], nil
+		}
+		if wait == 0 || len(tried) > 0 {
+			return nil, errNoNodes
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// rank orders the usable nodes by score. If there are none it returns how long
+// until an ejected one may be tried again, or 0 if all were tried.
+func (p *NodePool) rank(tried map[*Node]bool, now time.Time) ([]*Node, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var healthy []*Node
+	var wait time.Duration
+	for _, n := range p.nodes {
+		if tried[n] {
+			continue
+		}
+		if until := n.ejectedUntil.Sub(now); until > 0 {
+			if wait == 0 || until < wait {
+				wait = until
+			}
+			continue
+		}
+		healthy = append(healthy, n)
+	}
+
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return healthy[i].score() < healthy[j].score()
+	})
+	return healthy, wait
+}
+
+// score is the average latency inflated by the error rate; untried nodes
+// score 0 so they get a first sample.
+func (n *Node) score() float64 {
+	return float64(n.timings.Load()) * (1 + 10*n.errorRate)
+}
+
+func (p *NodePool) Report(n *Node, latency time.Duration, err error) {
+	n.counter.Add(1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if avg := n.timings.Load(); avg == 0 {
+		n.timings.Store(int64(latency))
+	} else {
+		n.timings.Store(int64(float64(avg)*(1-nodeHealthWeight) + float64(latency)*nodeHealthWeight))
+	}
+
+	var failed float64
+	if err != nil {
+		failed = 1
+	}
+	n.errorRate = n.errorRate*(1-nodeHealthWeight) + failed*nodeHealthWeight
+
+	if err == nil {
+		n.failures, n.ejections = 0, 0
+		return
+	}
+
+	n.failures++
+	if n.failures < nodeEjectAfter {
+		return
+	}
+
+	backoff := min(nodeEjectBase<<n.ejections, nodeEjectMax)
+	n.ejectedUntil = time.Now().Add(backoff)
+	// Stop doubling at the cap, so the shift can't overflow on a node that
+	// stays down.
+	if backoff < nodeEjectMax {
+		n.ejections++
+	}
+	// A node coming back from ejection is ejected again on its next failure.
+	n.failures = nodeEjectAfter - 1
+	log.Printf("Ejecting %s for %s: %v", n.name, backoff, err)
+}
+
+// Len is the number of nodes in the pool, ejected or not.
+func (p *NodePool) Len() int {
+	return len(p.nodes)
+}
+
+func (p *NodePool) LogStats() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, n := range p.nodes {
+		log.Printf("%s: %d requests, avg latency %s, error rate %.2f, ejected until %s",
+			n.name, n.counter.Load(), time.Duration(n.timings.Load()), n.errorRate, n.ejectedUntil.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,37 @@
+package solana
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNodePoolEjectionCapped(t *testing.T) {
+	n := NewNode("dead", "http://127.0.0.1:0")
+	p := NewNodePool([]*Node{n})
+
+	var last time.Duration
+	for i := 0; i < 100; i++ {
+		p.Report(n, time.Millisecond, errors.New("down"))
+		if i < nodeEjectAfter-1 {
+			continue
+		}
+		ejected := time.Until(n.ejectedUntil)
+		if ejected <= 0 || ejected > nodeEjectMax {
+			t.Fatalf("failure %d: ejected for %s", i+1, ejected)
+		}
+		last = ejected
+	}
+	if last < nodeEjectMax-time.Second {
+		t.Fatalf("ejected for %s after 100 failures, want %s", last, nodeEjectMax)
+	}
+
+	// A success resets the backoff.
+	p.Report(n, time.Millisecond, nil)
+	for i := 0; i < nodeEjectAfter; i++ {
+		p.Report(n, time.Millisecond, errors.New("down"))
+	}
+	if ejected := time.Until(n.ejectedUntil); ejected > nodeEjectBase {
+		t.Fatalf("ejected for %s after recovering", ejected)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
 	"sync"
 	"time"
 )
@@ -116,6 +117,12 @@ func (ps *PairsService) AddToQueue(pair PairProcessorQueue) {
 		return
 	}
 
+	ps.processor.mu.RLock()
+	defer ps.processor.mu.RUnlock()
+	if ps.processor.closed {
+		return
+	}
+
 	if _, exists := ps.processor.seen.LoadOrStore(pair.address, struct{}{}); exists {
 		return
 	}
@@ -127,6 +134,26 @@ func (ps *PairsService) AddToQueue(pair PairProcessorQueue) {
 	}
 }
 
+// StopPairProcessor stops taking pairs and waits for the queued ones to be
+// looked up. It returns how many were still queued when ctx ended.
+func (ps *PairsService) StopPairProcessor(ctx context.Context) int {
+	if ps.processor == nil {
+		return 0
+	}
+
+	ps.processor.mu.Lock()
+	if !ps.processor.closed {
+		ps.processor.closed = true
+		close(ps.processor.queue)
+	}
+	ps.processor.mu.Unlock()
+
+	if waitGroup(ctx, &ps.processor.wg) {
+		return 0
+	}
+	return len(ps.processor.queue)
+}
+
 func identifyPair(owner string, accInfo client.AccountInfo, token_ *string) (string, string, string, string, error) {
 	var exchange, baseMint, tokenMint, baseMintIdentifier string
 
@@ -181,6 +208,36 @@ func identifyPair(owner string, accInfo client.AccountInfo, token_ *string) (str
 		baseMint = fbPool.MintA.String()
 		tokenMint = fbPool.MintB.String()
 		baseMintIdentifier = "mintA"
+	} else if owner == ORCA_SWAP || owner == ORCA_SWAP_V2 {
+		pool := types.OrcaTokenSwapPool{}
+		err := pool.Decode(accInfo.Data)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		exchange = "ORCA"
+		baseMint = pool.MintA.String()
+		tokenMint = pool.MintB.String()
+		baseMintIdentifier = "mintA"
+	} else if owner == LIFINITY_SWAP_V2 {
+		lifinity := types.LfinitySwapV2Layout{}
+		err := lifinity.Decode(accInfo.Data)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		exchange = "LIFINITY"
+		baseMint = lifinity.TokenBMint.String()
+		tokenMint = lifinity.TokenAMint.String()
+		baseMintIdentifier = "tokenBMint"
+	} else if owner == PHOENIX {
+		market := types.PhoenixMarketLayout{}
+		err := market.Decode(accInfo.Data)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		exchange = "PHOENIX"
+		baseMint = market.Header.QuoteParams.MintKey.String()
+		tokenMint = market.Header.BaseParams.MintKey.String()
+		baseMintIdentifier = "quoteParams"
 	} else if owner == PUMPFUN {
 		exchange = "PUMPFUN"
 		baseMint = "So11111111111111111111111111111111111111112"
@@ -199,3 +256,54 @@ func identifyPair(owner string, accInfo client.AccountInfo, token_ *string) (str
 
 	return exchange, baseMint, tokenMint, baseMintIdentifier, nil
 }
+
+// poolVaults are the token accounts a pool holds its reserves in, for the
+// layouts that name them. Swaps through a router may list only these.
+func poolVaults(owner string, accInfo client.AccountInfo) ([]common.PublicKey, error) {
+	switch owner {
+	case RAYDIUM_LIQ_POOL_V4:
+		pool := types.RaydiumV4Layout{}
+		if err := pool.Decode(accInfo.Data); err != nil {
+			return nil, err
+		}
+		return []common.PublicKey{pool.BaseVault, pool.QuoteVault}, nil
+	case RAYDIUM_CONCENTRATED_LIQ:
+		pool := types.RaydiumConcentratedLayout{}
+		if err := pool.Decode(accInfo.Data); err != nil {
+			return nil, err
+		}
+		return []common.PublicKey{pool.TokenVault0, pool.TokenVault1}, nil
+	case METEORA_DLMM_PROGRAM:
+		pool := types.MeteoraLayout{}
+		if err := pool.Decode(accInfo.Data); err != nil {
+			return nil, err
+		}
+		return []common.PublicKey{pool.ReserveX, pool.ReserveY}, nil
+	case ORCA_WHIRL_PROGRAM_ID:
+		pool := types.OrcaWhirlpool{}
+		if err := pool.Decode(accInfo.Data); err != nil {
+			return nil, err
+		}
+		return []common.PublicKey{pool.TokenVaultA, pool.TokenVaultB}, nil
+	case FLUXBEAM_PROGRAM, ORCA_SWAP, ORCA_SWAP_V2:
+		pool := types.FluxBeamPool{}
+		if err := pool.Decode(accInfo.Data); err != nil {
+			return nil, err
+		}
+		return []common.PublicKey{pool.TokenAccountA, pool.TokenAccountB}, nil
+	case LIFINITY_SWAP_V2:
+		pool := types.LfinitySwapV2Layout{}
+		if err := pool.Decode(accInfo.Data); err != nil {
+			return nil, err
+		}
+		return []common.PublicKey{pool.TokenAAccount, pool.TokenBAccount}, nil
+	case PHOENIX:
+		market := types.PhoenixMarketLayout{}
+		if err := market.Decode(accInfo.Data); err != nil {
+			return nil, err
+		}
+		return []common.PublicKey{market.Header.BaseParams.VaultKey, market.Header.QuoteParams.VaultKey}, nil
+	}
+	// The pool address is in every swap of the other venues.
+	return nil, nil
+}
@@ -0,0 +1,65 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"sync"
+)
+
+// ProgramParseStats counts the instructions of one program that were decoded,
+// and those skipped by reason.
+type ProgramParseStats struct {
+	Decoded int64            `json:"decoded"`
+	Skipped map[string]int64 `json:"skipped,omitempty"`
+}
+
+// ParseStats aggregates the parse diagnostics of every transaction processed.
+type ParseStats struct {
+	Transactions int64                        `json:"transactions"`
+	Programs     map[string]ProgramParseStats `json:"programs"`
+}
+
+type parseCounters struct {
+	mu           sync.Mutex
+	transactions int64
+	programs     map[string]*ProgramParseStats
+}
+
+func (c *parseCounters) add(diag types.ParseDiagnostics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.programs == nil {
+		c.programs = make(map[string]*ProgramParseStats)
+	}
+	program := func(id string) *ProgramParseStats {
+		st, ok := c.programs[id]
+		if !ok {
+			st = &ProgramParseStats{Skipped: make(map[string]int64)}
+			c.programs[id] = st
+		}
+		return st
+	}
+
+	c.transactions++
+	for id, n := range diag.Decoded {
+		program(id).Decoded += int64(n)
+	}
+	for _, skipped := range diag.Skipped {
+		program(skipped.Program).Skipped[skipped.Reason]++
+	}
+}
+
+func (c *parseCounters) snapshot() ParseStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := ParseStats{Transactions: c.transactions, Programs: make(map[string]ProgramParseStats, len(c.programs))}
+	for id, program := range c.programs {
+		skipped := make(map[string]int64, len(program.Skipped))
+		for reason, n := range program.Skipped {
+			skipped[reason] = n
+		}
+		st.Programs[id] = ProgramParseStats{Decoded: program.Decoded, Skipped: skipped}
+	}
+	return st
+}
@@ -0,0 +1,340 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"context"
+	"fmt"
+	bin "github.com/gagliardetto/binary"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	poolStateQueueName     = "solana-pool-state"
+	poolStateFlushInterval = time.Second
+	poolStateBatchSize     = 1000
+)
+
+// poolAccountSizes keeps tick arrays, positions, bin arrays and the like out of
+// the account subscription; only the pool state accounts have these sizes.
+var poolAccountSizes = map[string]uint64{
+	RAYDIUM_LIQ_POOL_V4:      752,
+	RAYDIUM_CONCENTRATED_LIQ: 1544,
+	ORCA_WHIRL_PROGRAM_ID:    653,
+	METEORA_DLMM_PROGRAM:     904,
+}
+
+var q64 = new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 64))
+
+// NewPoolStateTransport opens the queue pool snapshots are published on.
+func NewPoolStateTransport() (Transport, error) {
+	return NewTransportFor(poolStateQueueName)
+}
+
+type trackedPool struct {
+	state        types.PoolState
+	accountSlot  uint64
+	writeVersion uint64
+
+	// Raydium V4 keeps its reserves in the vaults, minus the pnl owed to the
+	// protocol, so those pools are only complete once both vaults were seen.
+	baseVault, quoteVault   string
+	baseNeedTakePnl         uint64
+	quoteNeedTakePnl        uint64
+	baseAmount, quoteAmount *uint64
+}
+
+type vaultRef struct {
+	pool string
+	base bool
+}
+
+// PoolStateTracker keeps the latest state of every pool account seen on the
+// account subscription and publishes the ones that changed once a second.
+type PoolStateTracker struct {
+	mu        sync.Mutex
+	pools     map[string]*trackedPool
+	vaults    map[string]vaultRef
+	dirty     map[string]struct{}
+	transport Transport
+}
+
+func NewPoolStateTracker(transport Transport) *PoolStateTracker {
+	return &PoolStateTracker{
+		pools:     make(map[string]*trackedPool),
+		vaults:    make(map[string]vaultRef),
+		dirty:     make(map[string]struct{}),
+		transport: transport,
+	}
+}
+
+// ObserveAccount decodes a pool account update. Every endpoint delivers the
+// same updates, so anything not newer than the last one seen is dropped.
+func (t *PoolStateTracker) ObserveAccount(owner, pubkey string, data []byte, slot, writeVersion uint64) {
+	t.mu.Lock()
+	p, ok := t.pools[pubkey]
+	if ok && (slot < p.accountSlot || (slot == p.accountSlot && writeVersion <= p.writeVersion)) {
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+
+	next, err := decodePoolAccount(owner, pubkey, data)
+	if err != nil {
+		log.Printf("Failed to decode pool %s (%s): %v", pubkey, Programs[owner], err)
+		return
+	}
+	next.state.Slot = slot
+	next.state.Timestamp = time.Now().UTC()
+	next.accountSlot = slot
+	next.writeVersion = writeVersion
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok = t.pools[pubkey]; ok {
+		if slot < p.accountSlot || (slot == p.accountSlot && writeVersion <= p.writeVersion) {
+			return
+		}
+		if p.baseVault == next.baseVault && p.quoteVault == next.quoteVault {
+			next.baseAmount, next.quoteAmount = p.baseAmount, p.quoteAmount
+		}
+	}
+	if next.baseVault != "" {
+		t.vaults[next.baseVault] = vaultRef{pool: pubkey, base: true}
+		t.vaults[next.quoteVault] = vaultRef{pool: pubkey, base: false}
+	}
+
+	t.pools[pubkey] = next
+	t.markDirty(pubkey, next)
+}
+
+// ObserveTransaction picks up vault balances of tracked Raydium V4 pools from
+// the post token balances of a transaction.
+func (t *PoolStateTracker) ObserveTransaction(tx types.SolanaTx, slot uint64) {
+	if len(tx.Meta.PostTokenBalances) == 0 {
+		return
+	}
+	keys := getAllAccountKeys(&tx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.vaults) == 0 {
+		return
+	}
+
+	for _, balance := range tx.Meta.PostTokenBalances {
+		if balance.AccountIndex >= len(keys) {
+			continue
+		}
+		ref, ok := t.vaults[keys[balance.AccountIndex]]
+		if !ok {
+			continue
+		}
+		amount, err := strconv.ParseUint(balance.UITokenAmount.Amount, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		p := t.pools[ref.pool]
+		if ref.base {
+			p.baseAmount = &amount
+		} else {
+			p.quoteAmount = &amount
+		}
+		if slot > p.state.Slot {
+			p.state.Slot = slot
+		}
+		p.state.Timestamp = time.Now().UTC()
+		t.markDirty(ref.pool, p)
+	}
+}
+
+// markDirty refreshes the derived V4 reserves and queues the pool for the next
+// flush. Callers hold t.mu.
+func (t *PoolStateTracker) markDirty(pubkey string, p *trackedPool) {
+	if p.baseVault != "" {
+		if p.baseAmount == nil || p.quoteAmount == nil {
+			return
+		}
+		base := saturatingSub(*p.baseAmount, p.baseNeedTakePnl)
+		quote := saturatingSub(*p.quoteAmount, p.quoteNeedTakePnl)
+		p.state.BaseReserve, p.state.QuoteReserve = &base, &quote
+		p.state.Price = 0
+		if base > 0 {
+			p.state.Price = float64(quote) / float64(base)
+		}
+	}
+	t.dirty[pubkey] = struct{}{}
+}
+
+func (t *PoolStateTracker) Run() {
+	ticker := time.NewTicker(poolStateFlushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.flush()
+	}
+}
+
+func (t *PoolStateTracker) flush() {
+	t.mu.Lock()
+	if len(t.dirty) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	states := make([]types.PoolState, 0, len(t.dirty))
+	for pubkey := range t.dirty {
+		states = append(states, t.pools[pubkey].state)
+	}
+	t.dirty = make(map[string]struct{})
+	t.mu.Unlock()
+
+	for start := 0; start < len(states); start += poolStateBatchSize {
+		end := min(start+poolStateBatchSize, len(states))
+		msg, err := EncodePoolStates(states[start:end])
+		if err != nil {
+			log.Printf("Failed to encode pool states: %v", err)
+			continue
+		}
+		if err = t.transport.Publish(msg); err != nil {
+			log.Printf("Failed to publish %d pool states: %v", end-start, err)
+		}
+	}
+}
+
+func decodePoolAccount(owner, pubkey string, data []byte) (*trackedPool, error) {
+	p := &trackedPool{state: types.PoolState{Pool: pubkey, Exchange: Programs[owner]}}
+
+	switch owner {
+	case RAYDIUM_LIQ_POOL_V4:
+		pool := types.RaydiumV4Layout{}
+		if err := pool.Decode(data); err != nil {
+			return nil, err
+		}
+		p.state.BaseMint = pool.BaseMint.String()
+		p.state.QuoteMint = pool.QuoteMint.String()
+		p.baseVault = pool.BaseVault.String()
+		p.quoteVault = pool.QuoteVault.String()
+		p.baseNeedTakePnl = uint64(pool.BaseNeedTakePnl)
+		p.quoteNeedTakePnl = uint64(pool.QuoteNeedTakePnl)
+	case RAYDIUM_CONCENTRATED_LIQ:
+		if len(data) < 8 {
+			return nil, fmt.Errorf("account too short: %d bytes", len(data))
+		}
+		pool := types.RaydiumConcentratedLayout{}
+		if err := pool.Decode(data[8:]); err != nil {
+			return nil, err
+		}
+		p.state.BaseMint = pool.TokenMint0.String()
+		p.state.QuoteMint = pool.TokenMint1.String()
+		p.state.Liquidity = pool.Liquidity.DecimalString()
+		p.state.Price = sqrtPriceX64ToPrice(pool.SqrtPriceX64)
+	case ORCA_WHIRL_PROGRAM_ID:
+		pool := types.OrcaWhirlpool{}
+		if err := pool.Decode(data); err != nil {
+			return nil, err
+		}
+		p.state.BaseMint = pool.TokenMintA.String()
+		p.state.QuoteMint = pool.TokenMintB.String()
+		p.state.Liquidity = pool.Liquidity.DecimalString()
+		p.state.Price = sqrtPriceX64ToPrice(pool.SqrtPrice)
+	case METEORA_DLMM_PROGRAM:
+		pool := types.MeteoraLayout{}
+		if err := pool.Decode(data); err != nil {
+			return nil, err
+		}
+		p.state.BaseMint = pool.TokenXMint.String()
+		p.state.QuoteMint = pool.TokenYMint.String()
+		p.state.Price = math.Pow(1+float64(pool.BinStep)/10000, float64(pool.ActiveId))
+	default:
+		return nil, fmt.Errorf("unknown pool owner: %s", owner)
+	}
+
+	return p, nil
+}
+
+func sqrtPriceX64ToPrice(sqrtPrice bin.Uint128) float64 {
+	f := new(big.Float).SetInt(sqrtPrice.BigInt())
+	f.Quo(f, q64)
+	price, _ := f.Mul(f, f).Float64()
+	return price
+}
+
+func saturatingSub(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// PoolStateWriter moves pool snapshots from the pool-state queue into the
+// pool_state table.
+type PoolStateWriter struct {
+	transport Transport
+	repo      PoolStateRepo
+}
+
+func NewPoolStateWriter(transport Transport, repo PoolStateRepo) *PoolStateWriter {
+	return &PoolStateWriter{transport: transport, repo: repo}
+}
+
+func (w *PoolStateWriter) Run(ctx context.Context) {
+	deliveries, err := w.transport.Consume(ctx)
+	if err != nil {
+		log.Fatalf("Failed to register a pool state consumer: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case x, ok := <-deliveries:
+			if !ok {
+				return
+			}
+
+			states, err := DecodePoolStates(x.Message)
+			if err != nil {
+				if err = w.transport.DeadLetter(x.Message, err.Error()); err != nil {
+					log.Printf("Failed to dead-letter pool states: %v", err)
+					x.Nack(true)
+					continue
+				}
+				x.Ack()
+				continue
+			}
+
+			if err = w.insert(ctx, states); err != nil {
+				log.Printf("Failed to insert %d pool states: %v", len(states), err)
+				x.Nack(true)
+				continue
+			}
+			if err = x.Ack(); err != nil {
+				log.Printf("Failed to ack pool states: %v", err)
+			}
+		}
+	}
+}
+
+func (w *PoolStateWriter) insert(ctx context.Context, states []types.PoolState) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := w.repo.InsertPoolStates(ctx, states)
+		if err == nil || attempt == insertAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
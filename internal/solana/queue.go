@@ -4,7 +4,6 @@ import (
 	"blocsy/internal/types"
 	"context"
 	"fmt"
-	"github.com/streadway/amqp"
 	"log"
 	"os"
 	"runtime"
@@ -12,318 +11,417 @@ import (
 	"time"
 )
 
-var (
-	queueName = "solana-tx"
-	txWorkers = 10
+var queueName = "solana-tx"
+
+const (
+	publishAttempts = 5
+	insertAttempts  = 5
+	retryBackoff    = time.Second
+	maxRetryBackoff = 30 * time.Second
+
+	// drainGrace is how long workers get to give back their deliveries once
+	// the drain deadline has cancelled their work.
+	drainGrace = 5 * time.Second
 )
 
 func NewSolanaQueueHandler(txHandler *TxHandler, pRepo SwapsRepo) *QueueHandler {
-	qh := &QueueHandler{
-		txHandler: txHandler,
-		pRepo:     pRepo,
-		workers:   200,
+	transport, err := NewTransport()
+	if err != nil {
+		log.Fatalf("Failed to create queue transport: %v", err)
 	}
-	qh.connectToRabbitMQ()
 
-	return qh
+	return NewQueueHandlerWithTransport(txHandler, pRepo, transport)
 }
 
-func (qh *QueueHandler) connectToRabbitMQ() {
-	rabbitMQURL := fmt.Sprintf("amqp://%s:%s@%s:%s/%s",
-		os.Getenv("RABBIT_MQ_USER"),
-		os.Getenv("RABBIT_MQ_PASS"),
-		os.Getenv("RABBIT_MQ_HOST"),
-		os.Getenv("RABBIT_MQ_PORT"),
-		os.Getenv("RABBIT_MQ_VHOST"),
-	)
-
-	var err error
-	qh.conn, err = amqp.Dial(rabbitMQURL)
-	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+func NewQueueHandlerWithTransport(txHandler *TxHandler, pRepo SwapsRepo, transport Transport) *QueueHandler {
+	encoding := os.Getenv("QUEUE_ENCODING")
+	if encoding == "" {
+		encoding = EncodingJSON
 	}
 
-	qh.ch, err = qh.conn.Channel()
-	if err != nil {
-		log.Fatalf("Failed to open a channel: %v", err)
+	slowInsert := defaultSlowInsert
+	if d, err := time.ParseDuration(os.Getenv("QUEUE_SLOW_INSERT")); err == nil && d > 0 {
+		slowInsert = d
 	}
 
-	log.Printf("Connected to RabbitMQ (is closed: %v)", qh.conn.IsClosed())
-}
-
-func (qh *QueueHandler) Close() {
-	if qh.ch != nil {
-		if err := qh.ch.Close(); err != nil {
-			log.Printf("Failed to close channel: %v", err)
-		}
+	qh := &QueueHandler{
+		txHandler:   txHandler,
+		pRepo:       pRepo,
+		transport:   transport,
+		encoding:    encoding,
+		minWorkers:  envInt("QUEUE_WORKERS_MIN", defaultMinWorkers),
+		maxWorkers:  envInt("QUEUE_WORKERS_MAX", defaultMaxWorkers),
+		txWorkers:   envInt("QUEUE_TX_WORKERS", defaultTxWorkers),
+		maxPrefetch: queuePrefetch(),
+		slowInsert:  slowInsert,
+		done:        make(chan struct{}),
 	}
-	if qh.conn != nil {
-		if err := qh.conn.Close(); err != nil {
-			log.Printf("Failed to close connection: %v", err)
-		}
+	qh.maxWorkers = max(qh.maxWorkers, qh.minWorkers)
+	qh.stats.Prefetch = qh.maxPrefetch
+	qh.workCtx, qh.stopWork = context.WithCancel(context.Background())
+	// Only consumers see the blocks the tx processor works on.
+	if txHandler != nil {
+		qh.recorder = NewBlockRecorderFromEnv()
 	}
+	return qh
 }
 
-func (qh *QueueHandler) reconnect() bool {
-	qh.Close()
-	backoff := time.Second
-	for i := 0; i < 5; i++ {
-		qh.connectToRabbitMQ()
-		if qh.conn != nil && qh.ch != nil {
-			log.Println("Successfully reconnected to RabbitMQ")
-			return true
-		}
-		log.Printf("Reconnection attempt %d failed", i+1)
-		time.Sleep(backoff)
-		backoff *= 2
+func (qh *QueueHandler) Close() {
+	if err := qh.transport.Close(); err != nil {
+		log.Printf("Failed to close queue transport: %v", err)
 	}
-	log.Println("Failed to reconnect to RabbitMQ after multiple attempts")
-	return false
 }
 
-func (qh *QueueHandler) setPrefetch(value int) {
-	err := qh.ch.Qos(
-		value,
-		0,
-		false,
-	)
+// ListenToSolanaQueue consumes until ctx is cancelled or the transport stops
+// delivering, and returns once the workers have finished the deliveries they
+// already hold. Their work is only cancelled by Drain, so a shutdown does not
+// cut a block in half.
+func (qh *QueueHandler) ListenToSolanaQueue(ctx context.Context) {
+	defer close(qh.done)
+
+	deliveries, err := qh.transport.Consume(ctx)
 	if err != nil {
-		log.Printf("Failed to set QoS: %v", err)
+		log.Fatalf("Failed to register a consumer: %v", err)
 	}
-}
-
-func (qh *QueueHandler) ListenToSolanaQueue(ctx context.Context) {
-	blocked := qh.conn.NotifyBlocked(make(chan amqp.Blocking))
-	go func() {
-		for b := range blocked {
-			if b.Active {
-				log.Printf("Connection blocked: %s", b.Reason)
-			} else {
-				log.Println("Connection unblocked")
-			}
-		}
-	}()
 
 	qh.ctx = ctx
-	qh.rabbitChan = make(chan amqp.Delivery, qh.workers)
-	qh.workerPool = make(map[int]context.CancelFunc)
+	qh.deliveries = deliveries
+	qh.workerPool = make(map[int]chan struct{})
+	qh.consumed = make(chan struct{})
 
-	for i := 0; i < qh.workers; i++ {
-		qh.startWorker(i, ctx)
+	for i := 0; i < qh.minWorkers; i++ {
+		qh.startWorker()
 	}
+	log.Printf("Started %d workers (up to %d)", qh.minWorkers, qh.maxWorkers)
 
-	qh.setPrefetch(100)
-
-	q, err := qh.ch.QueueDeclare(
-		queueName,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		log.Fatalf("Failed to declare a queue: %v", err)
-	}
-
-	msgs, err := qh.ch.Consume(
-		q.Name,
-		"",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		log.Fatalf("Failed to register a consumer: %v", err)
+	// The pool must not grow once the workers start leaving.
+	qh.scaleWorkers(ctx)
+	qh.workerWg.Wait()
+	if qh.recorder != nil {
+		if err = qh.recorder.Close(); err != nil {
+			log.Printf("Failed to close block recording: %v", err)
+		}
 	}
+	runtime.GC()
+}
 
-	go func() {
-		for d := range msgs {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				qh.rabbitChan <- d
-			}
-		}
-		close(qh.rabbitChan)
-	}()
+// Drain waits for ListenToSolanaQueue to return after its ctx was cancelled.
+// When ctx ends first the workers are cancelled, and whatever they still hold
+// is requeued. It returns the number of requeued deliveries.
+func (qh *QueueHandler) Drain(ctx context.Context) int64 {
+	log.Printf("Draining %d in-flight deliveries...", qh.inFlight.Load())
 
-	qh.workerWg.Wait()
-	runtime.GC()
+	select {
+	case <-qh.done:
+		return qh.requeued.Load()
+	case <-ctx.Done():
+	}
+
+	log.Printf("Drain deadline reached with %d deliveries in flight, requeueing them", qh.inFlight.Load())
+	qh.stopWork()
+	select {
+	case <-qh.done:
+	case <-time.After(drainGrace):
+		log.Printf("%d deliveries are still held by workers", qh.inFlight.Load())
+	}
+	return qh.requeued.Load()
 }
 
-func (qh *QueueHandler) startWorker(workerID int, parentCtx context.Context) {
-	ctx, cancel := context.WithCancel(parentCtx)
+func (qh *QueueHandler) startWorker() {
+	stop := make(chan struct{})
 	qh.mu.Lock()
-	qh.workerPool[workerID] = cancel
+	workerID := qh.nextWorker
+	qh.nextWorker++
+	qh.workerPool[workerID] = stop
 	qh.mu.Unlock()
 
 	qh.workerWg.Add(1)
 	go func() {
 		defer qh.workerWg.Done()
-		qh.solanaWorker(ctx)
+		qh.solanaWorker(qh.workCtx, stop)
+
+		// Stats counts only the workers still running.
+		qh.mu.Lock()
+		delete(qh.workerPool, workerID)
+		qh.mu.Unlock()
 	}()
-	log.Printf("Worker %d started", workerID)
 }
 
-func (qh *QueueHandler) stopWorker(workerID int) {
+// stopWorker lets the newest worker finish its delivery and leave.
+func (qh *QueueHandler) stopWorker() {
 	qh.mu.Lock()
 	defer qh.mu.Unlock()
 
-	if cancel, exists := qh.workerPool[workerID]; exists {
-		cancel()
-		delete(qh.workerPool, workerID)
-		log.Printf("Worker %d stopped", workerID)
+	newest := -1
+	for workerID := range qh.workerPool {
+		newest = max(newest, workerID)
+	}
+	if stop, exists := qh.workerPool[newest]; exists {
+		close(stop)
+		delete(qh.workerPool, newest)
 	}
 }
 
-func (qh *QueueHandler) AddToSolanaQueue(blockData types.BlockData) {
-	qh.mu.Lock()
-	defer qh.mu.Unlock()
+// AddToSolanaQueue returns once the transport has accepted the block, retrying
+// with backoff for a bounded number of attempts.
+func (qh *QueueHandler) AddToSolanaQueue(blockData types.BlockData) error {
+	msg, err := EncodeBlock(blockData, qh.encoding)
+	if err != nil {
+		return err
+	}
 
-	if qh.conn == nil || qh.conn.IsClosed() || qh.ch == nil {
-		log.Println("Connection or channel is closed, attempting to reconnect...")
-		if !qh.reconnect() {
-			log.Println("Reconnection failed. Exiting AddToSolanaQueue")
-			return
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err = qh.transport.Publish(msg)
+		if err == nil {
+			return nil
 		}
+		if attempt == publishAttempts {
+			return fmt.Errorf("failed to publish block %d after %d attempts: %w", blockData.Block, attempt, err)
+		}
+
+		log.Printf("Failed to publish block %d (attempt %d): %v", blockData.Block, attempt, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRetryBackoff)
 	}
+}
 
-	q, err := qh.ch.QueueDeclare(
-		queueName,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+func (qh *QueueHandler) AddSlotStatus(status types.SlotStatus) error {
+	msg, err := EncodeSlotStatus(status)
 	if err != nil {
-		log.Printf("Failed to declare a queue: %v", err)
-		return
+		return err
 	}
 
-	blockBytes, err := blockData.MarshalJSON()
-	if err != nil {
-		log.Printf("Failed to marshal SolanaBlockTx to JSON: %v", err)
-		return
+	if err = qh.transport.Publish(msg); err != nil {
+		return fmt.Errorf("failed to publish slot status %d: %w", status.Slot, err)
 	}
+	return nil
+}
 
-	err = qh.ch.Publish(
-		"",
-		q.Name,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        blockBytes,
-		})
-	if err != nil {
-		log.Printf("Failed to publish a message: %v", err)
+// deadLetter parks a delivery that cannot be processed and acks the original.
+// If even that fails the delivery is requeued so it is not lost.
+func (qh *QueueHandler) deadLetter(x Delivery, reason string) {
+	log.Printf("Dead-lettering message: %s", reason)
+
+	if err := qh.transport.DeadLetter(x.Message, reason); err != nil {
+		log.Printf("Failed to dead-letter message: %v", err)
+		if err = x.Nack(true); err != nil {
+			log.Printf("Failed to nack message: %v", err)
+		}
 		return
 	}
+
+	if err := x.Ack(); err != nil {
+		log.Printf("Failed to ack message: %v", err)
+	}
 }
 
-func (qh *QueueHandler) solanaWorker(ctx context.Context) {
-	defer qh.workerWg.Done()
+func (qh *QueueHandler) solanaWorker(ctx context.Context, stop <-chan struct{}) {
 	for {
 		select {
-		case x, ok := <-qh.rabbitChan:
+		case x, ok := <-qh.deliveries:
 			if !ok {
-				log.Println("Channel closed")
+				qh.endOnce.Do(func() { close(qh.consumed) })
 				return
 			}
 
-			blockData := types.BlockData{}
-			unmarshallErr := blockData.UnmarshalJSON(x.Body)
-			if unmarshallErr != nil {
-				log.Printf("Failed to unmarshal tx: %v", unmarshallErr)
-				nackErr := x.Nack(false, false)
-				if nackErr != nil {
-					log.Printf("Failed to nack message: %v", nackErr)
-					continue
-				}
-				continue
+			qh.inFlight.Add(1)
+			cancelled := qh.handleDelivery(ctx, x)
+			qh.inFlight.Add(-1)
+			if cancelled {
+				return
 			}
 
-			if qh.conn.IsClosed() || qh.ch == nil {
-				log.Println("Connection is closed or channel is nil, exiting worker...")
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleDelivery settles x one way or another. It returns true when the worker
+// was cancelled and x went back on the queue.
+func (qh *QueueHandler) handleDelivery(ctx context.Context, x Delivery) bool {
+	if IsSlotStatus(x.Message) {
+		qh.handleSlotStatus(ctx, x)
+		return false
+	}
 
-				if !qh.reconnect() {
-					log.Println("Failed to reconnect, exiting worker...")
-					return
-				}
-			}
+	blockData, decodeErr := DecodeBlock(x.Message)
+	if decodeErr != nil {
+		qh.deadLetter(x, fmt.Sprintf("decode: %v", decodeErr))
+		return false
+	}
+
+	if qh.recorder != nil {
+		qh.recorder.Record(blockData)
+	}
 
-			txChan := make(chan types.SolanaTx, len(blockData.Transactions))
-			swapsChan := make(chan []types.SwapLog)
-			var wg sync.WaitGroup
-
-			for i := 0; i < txWorkers; i++ {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					for tx := range txChan {
-						processedSwaps, err := qh.txHandler.ProcessTransaction(ctx, &tx, blockData.Timestamp, blockData.Block, blockData.IgnoreWS)
-						if err != nil {
-							continue
-						}
-						swapsChan <- processedSwaps
-
-					}
-				}()
+	if _, err := qh.handleBlock(ctx, blockData, true); err != nil {
+		if ctx.Err() != nil {
+			qh.requeued.Add(1)
+			if err = x.Nack(true); err != nil {
+				log.Printf("Failed to nack message: %v", err)
 			}
+			return true
+		}
+		qh.deadLetter(x, fmt.Sprintf("block %d: %v", blockData.Block, err))
+		return false
+	}
+	if blockData.Source == types.IngestSourceLive || blockData.Source == "" {
+		for last := qh.lastBlockTime.Load(); blockData.Timestamp > last; last = qh.lastBlockTime.Load() {
+			if qh.lastBlockTime.CompareAndSwap(last, blockData.Timestamp) {
+				break
+			}
+		}
+	}
 
-			go func() {
-				for _, tx := range blockData.Transactions {
-					txChan <- tx
-				}
-				close(txChan)
-			}()
+	if err := x.Ack(); err != nil {
+		log.Printf("Failed to ack message: %v", err)
+	}
+	return false
+}
 
-			go func() {
-				wg.Wait()
-				close(swapsChan)
-			}()
+// handleBlock is what a worker does with a block: skip dead slots, parse the
+// transactions and insert the swaps. The swaps are returned in transaction
+// order, so the same block always produces the same result. With record set,
+// the outcome goes to the slot ledger.
+func (qh *QueueHandler) handleBlock(ctx context.Context, blockData types.BlockData, record bool) ([]types.SwapLog, error) {
+	started := time.Now()
+	recordSlot := func(status string, swaps int) {
+		if record {
+			qh.recordSlot(ctx, blockData, status, swaps, started)
+		}
+	}
 
-			swaps := make([]types.SwapLog, 0)
-			for result := range swapsChan {
-				swaps = append(swaps, result...)
-			}
+	slotStatus, err := qh.pRepo.FindSlotStatus(ctx, blockData.Block)
+	if err != nil {
+		log.Printf("Failed to get status of slot %d: %v", blockData.Block, err)
+	}
+	if slotStatus == types.SlotDead {
+		log.Printf("Skipping block %d from a dead slot", blockData.Block)
+		recordSlot(types.SlotSkipped, 0)
+		return nil, nil
+	}
+	finalized := blockData.Commitment == types.CommitmentFinalized || slotStatus == types.CommitmentFinalized
 
-			if len(swaps) > 0 {
-				err := qh.insertBatch(ctx, swaps)
+	results := make([][]types.SwapLog, len(blockData.Transactions))
+	next := make(chan int, len(blockData.Transactions))
+	for i := range blockData.Transactions {
+		next <- i
+	}
+	close(next)
+
+	var wg sync.WaitGroup
+	for w := 0; w < qh.txWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				processedSwaps, _, err := qh.txHandler.ProcessTransaction(ctx, &blockData.Transactions[i], blockData.Timestamp, blockData.Block, blockData.IgnoreWS)
 				if err != nil {
-					log.Printf("Failed to insert swaps: %v", err)
-					return
+					continue
 				}
+				results[i] = processedSwaps
 			}
+		}()
+	}
+	wg.Wait()
 
-			err := x.Ack(false)
-			if err != nil {
-				log.Printf("Failed to ack message: %v", err)
-				return
-			}
+	swaps := make([]types.SwapLog, 0)
+	for _, result := range results {
+		swaps = append(swaps, result...)
+	}
+	for i := range swaps {
+		swaps[i].Finalized = finalized
+	}
 
-		case <-ctx.Done():
-			return
+	if len(swaps) > 0 {
+		if err = qh.insertBatch(ctx, swaps); err != nil {
+			if ctx.Err() == nil {
+				recordSlot(types.SlotFailed, 0)
+			}
+			return swaps, err
 		}
 	}
+	recordSlot(types.SlotOK, len(swaps))
+	return swaps, nil
+}
+
+// recordSlot writes the outcome of a block to the slot ledger. Address
+// backfills carry a few transactions of a slot, so they say nothing about it.
+func (qh *QueueHandler) recordSlot(ctx context.Context, blockData types.BlockData, status string, swaps int, started time.Time) {
+	source := blockData.Source
+	if source == types.IngestSourceAddress {
+		return
+	}
+	if source == "" {
+		source = types.IngestSourceLive
+	}
+
+	// The listener publishes a slot in pieces; a backfill fetches it whole.
+	var piece string
+	if source == types.IngestSourceLive && len(blockData.Transactions) > 0 && len(blockData.Transactions[0].Transaction.Signatures) > 0 {
+		piece = blockData.Transactions[0].Transaction.Signatures[0]
+	}
+
+	err := qh.pRepo.RecordSlot(ctx, types.SlotLedgerEntry{
+		Slot:         blockData.Block,
+		Source:       source,
+		Piece:        piece,
+		TxCount:      len(blockData.Transactions),
+		SwapCount:    swaps,
+		ProcessingMs: time.Since(started).Milliseconds(),
+		Status:       status,
+	})
+	if err != nil {
+		log.Printf("Failed to record slot %d in the ledger: %v", blockData.Block, err)
+	}
+}
+
+// ReplayBlock runs a recorded block through the worker logic without a queue.
+// It leaves the slot ledger alone.
+func (qh *QueueHandler) ReplayBlock(ctx context.Context, blockData types.BlockData) ([]types.SwapLog, error) {
+	return qh.handleBlock(ctx, blockData, false)
+}
+
+func (qh *QueueHandler) handleSlotStatus(ctx context.Context, x Delivery) {
+	status, err := DecodeSlotStatus(x.Message)
+	if err != nil {
+		qh.deadLetter(x, fmt.Sprintf("decode: %v", err))
+		return
+	}
+
+	if err = qh.pRepo.UpsertSlotStatus(ctx, status); err != nil {
+		qh.deadLetter(x, fmt.Sprintf("slot %d: %v", status.Slot, err))
+		return
+	}
+
+	if err = x.Ack(); err != nil {
+		log.Printf("Failed to ack message: %v", err)
+	}
 }
 
 func (qh *QueueHandler) insertBatch(ctx context.Context, swaps []types.SwapLog) error {
-	const maxRetries = 3
+	var err error
+	backoff := retryBackoff
 
-	for retry := 0; retry < maxRetries; retry++ {
-		if err := qh.pRepo.InsertSwaps(ctx, swaps); err != nil {
-			log.Printf("Failed to insert swaps batch (attempt %d): %v", retry+1, err)
-			time.Sleep(2 * time.Second)
-		} else {
+	for attempt := 1; attempt <= insertAttempts; attempt++ {
+		started := time.Now()
+		if err = qh.pRepo.InsertSwaps(ctx, swaps); err == nil {
+			qh.observeInsert(time.Since(started))
 			return nil
 		}
+		log.Printf("Failed to insert swaps batch (attempt %d): %v", attempt, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
 	}
 
-	return fmt.Errorf("failed to insert swaps after %d retries", maxRetries)
+	return fmt.Errorf("failed to insert swaps after %d attempts: %w", insertAttempts, err)
 }
@@ -0,0 +1,505 @@
+package solana
+
+import (
+	"context"
+	"fmt"
+	"github.com/streadway/amqp"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	rabbitPrefetch = 100
+	confirmTimeout = 10 * time.Second
+
+	// consumeRetryMax caps the wait between attempts to re-register a
+	// consumer whose channel closed.
+	consumeRetryMax = 30 * time.Second
+)
+
+// rabbitChannel is the part of *amqp.Channel the transport uses.
+type rabbitChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueInspect(name string) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	Close() error
+}
+
+// rabbitSession is a connection with one channel. Confirmed publishes and
+// each consumer get a session of their own, so losing one leaves the others
+// running.
+type rabbitSession struct {
+	conn     *amqp.Connection
+	ch       rabbitChannel
+	confirms chan amqp.Confirmation // confirm mode only
+	isClosed func() bool
+}
+
+func (s *rabbitSession) close() {
+	if err := s.ch.Close(); err != nil {
+		log.Printf("Failed to close channel: %v", err)
+	}
+	if s.conn != nil {
+		if err := s.conn.Close(); err != nil {
+			log.Printf("Failed to close connection: %v", err)
+		}
+	}
+}
+
+func dialRabbit(confirm bool) (*rabbitSession, error) {
+	rabbitMQURL := fmt.Sprintf("amqp://%s:%s@%s:%s/%s",
+		os.Getenv("RABBIT_MQ_USER"),
+		os.Getenv("RABBIT_MQ_PASS"),
+		os.Getenv("RABBIT_MQ_HOST"),
+		os.Getenv("RABBIT_MQ_PORT"),
+		os.Getenv("RABBIT_MQ_VHOST"),
+	)
+
+	conn, err := amqp.Dial(rabbitMQURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+
+	s := &rabbitSession{conn: conn, ch: ch, isClosed: conn.IsClosed}
+	if confirm {
+		if err = ch.Confirm(false); err != nil {
+			s.close()
+			return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+		}
+		s.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	}
+	return s, nil
+}
+
+// RabbitTransport publishes with confirms and parks failed messages on
+// <queue>.dlq, bound to the <queue>.dlx exchange.
+type RabbitTransport struct {
+	queue          string
+	dial           func(confirm bool) (*rabbitSession, error)
+	confirmTimeout time.Duration
+
+	mu          sync.Mutex
+	pub         *rabbitSession
+	subs        map[*rabbitSession]bool // one per consumer
+	prefetch    int                     // set by SetPrefetch, 0 for the default
+	closed      bool
+	declared    bool
+	dlqDeclared bool
+}
+
+func NewRabbitTransport(queue string) *RabbitTransport {
+	t := &RabbitTransport{queue: queue, dial: dialRabbit, confirmTimeout: confirmTimeout, subs: make(map[*rabbitSession]bool)}
+	if err := t.connect(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	return t
+}
+
+// connect opens the publishing session.
+func (t *RabbitTransport) connect() error {
+	pub, err := t.dial(true)
+	if err != nil {
+		return err
+	}
+	t.pub = pub
+	t.declared = false
+	t.dlqDeclared = false
+
+	log.Printf("Connected to RabbitMQ")
+	return nil
+}
+
+func (t *RabbitTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	for sub := range t.subs {
+		sub.close()
+		delete(t.subs, sub)
+	}
+	if t.pub != nil {
+		t.pub.close()
+		t.pub = nil
+	}
+	return nil
+}
+
+func (t *RabbitTransport) reconnect() bool {
+	if t.pub != nil {
+		t.pub.close()
+		t.pub = nil
+	}
+	backoff := time.Second
+	for i := 0; i < 5; i++ {
+		err := t.connect()
+		if err == nil {
+			log.Println("Successfully reconnected to RabbitMQ")
+			return true
+		}
+		log.Printf("Reconnection attempt %d failed: %v", i+1, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Println("Failed to reconnect to RabbitMQ after multiple attempts")
+	return false
+}
+
+func (t *RabbitTransport) declare() error {
+	if t.declared {
+		return nil
+	}
+
+	_, err := t.pub.ch.QueueDeclare(
+		t.queue,
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare a queue: %w", err)
+	}
+
+	t.declared = true
+	return nil
+}
+
+func (t *RabbitTransport) declareDeadLetter() error {
+	if t.dlqDeclared {
+		return nil
+	}
+
+	exchange := t.queue + ".dlx"
+	dlq := t.queue + deadLetterSuffix
+
+	if err := t.pub.ch.ExchangeDeclare(exchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+	if _, err := t.pub.ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+	if err := t.pub.ch.QueueBind(dlq, t.queue, exchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+
+	t.dlqDeclared = true
+	return nil
+}
+
+// ensureConnected reopens the publishing session. Consumers reopen their own.
+func (t *RabbitTransport) ensureConnected() error {
+	if t.closed {
+		return fmt.Errorf("transport is closed")
+	}
+	if t.pub == nil || t.pub.isClosed() {
+		log.Println("Connection or channel is closed, attempting to reconnect...")
+		if !t.reconnect() {
+			return fmt.Errorf("reconnection to RabbitMQ failed")
+		}
+	}
+	return nil
+}
+
+func (t *RabbitTransport) Publish(msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.ensureConnected(); err != nil {
+		return err
+	}
+	if err := t.declare(); err != nil {
+		return err
+	}
+
+	return t.publish("", t.queue, msg)
+}
+
+func (t *RabbitTransport) DeadLetter(msg Message, reason string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.ensureConnected(); err != nil {
+		return err
+	}
+	if err := t.declareDeadLetter(); err != nil {
+		return err
+	}
+
+	return t.publish(t.queue+".dlx", t.queue, deadLetterMessage(msg, reason))
+}
+
+// publish sends msg and waits for the broker to confirm it. Publishes are
+// serialised by t.mu, so the next confirmation always belongs to this message.
+func (t *RabbitTransport) publish(exchange, key string, msg Message) error {
+	err := t.pub.ch.Publish(
+		exchange,
+		key,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:     msg.ContentType,
+			ContentEncoding: msg.ContentEncoding,
+			Headers:         msg.Headers,
+			DeliveryMode:    amqp.Persistent,
+			Body:            msg.Body,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to publish a message: %w", err)
+	}
+
+	select {
+	case c, ok := <-t.pub.confirms:
+		if !ok {
+			return fmt.Errorf("channel closed before publish was confirmed")
+		}
+		if !c.Ack {
+			return fmt.Errorf("publish %d was nacked by the broker", c.DeliveryTag)
+		}
+		return nil
+	case <-time.After(t.confirmTimeout):
+		// A late confirm would be matched to the next publish; start over.
+		// Only the publishing session goes, consumers keep theirs.
+		t.pub.close()
+		t.pub = nil
+		return fmt.Errorf("timed out waiting for publish confirm")
+	}
+}
+
+// Depth is the number of messages waiting in the queue, not counting the ones
+// delivered and not yet acked.
+func (t *RabbitTransport) Depth() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.ensureConnected(); err != nil {
+		return 0, err
+	}
+	q, err := t.pub.ch.QueueInspect(t.queue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue: %w", err)
+	}
+	return q.Messages, nil
+}
+
+// DeadLetterDepth is the number of messages parked on the dead-letter queue.
+func (t *RabbitTransport) DeadLetterDepth() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.ensureConnected(); err != nil {
+		return 0, err
+	}
+	if err := t.declareDeadLetter(); err != nil {
+		return 0, err
+	}
+	q, err := t.pub.ch.QueueInspect(t.queue + deadLetterSuffix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect dead-letter queue: %w", err)
+	}
+	return q.Messages, nil
+}
+
+// SetPrefetch caps the unacked deliveries of the consumer's whole channel.
+// Unlike the per-consumer limit set in subscribe it applies to a running
+// consumer, and it is kept for the channels the consumer reopens.
+func (t *RabbitTransport) SetPrefetch(n int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prefetch = n
+	for sub := range t.subs {
+		if err := sub.ch.Qos(n, 0, true); err != nil {
+			return fmt.Errorf("failed to set prefetch: %w", err)
+		}
+	}
+	return nil
+}
+
+func (t *RabbitTransport) Consume(ctx context.Context) (<-chan Delivery, error) {
+	t.mu.Lock()
+	if err := t.ensureConnected(); err != nil {
+		t.mu.Unlock()
+		return nil, err
+	}
+	err := t.declare()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return t.consume(ctx, t.queue)
+}
+
+func (t *RabbitTransport) ConsumeDeadLetters(ctx context.Context) (<-chan Delivery, error) {
+	t.mu.Lock()
+	if err := t.ensureConnected(); err != nil {
+		t.mu.Unlock()
+		return nil, err
+	}
+	err := t.declareDeadLetter()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return t.consume(ctx, t.queue+deadLetterSuffix)
+}
+
+// subscribe opens a session of its own for a consumer of queue.
+func (t *RabbitTransport) subscribe(queue string) (*rabbitSession, <-chan amqp.Delivery, string, error) {
+	sub, err := t.dial(false)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if sub.conn != nil {
+		blocked := sub.conn.NotifyBlocked(make(chan amqp.Blocking))
+		go func() {
+			for b := range blocked {
+				if b.Active {
+					log.Printf("Connection blocked: %s", b.Reason)
+				} else {
+					log.Println("Connection unblocked")
+				}
+			}
+		}()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		sub.close()
+		return nil, nil, "", fmt.Errorf("transport is closed")
+	}
+
+	if err = sub.ch.Qos(queuePrefetch(), 0, false); err != nil {
+		log.Printf("Failed to set QoS: %v", err)
+	}
+	if t.prefetch > 0 {
+		if err = sub.ch.Qos(t.prefetch, 0, true); err != nil {
+			log.Printf("Failed to set prefetch: %v", err)
+		}
+	}
+
+	tag := fmt.Sprintf("%s-%d", queue, time.Now().UnixNano())
+	msgs, err := sub.ch.Consume(
+		queue,
+		tag,
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		sub.close()
+		return nil, nil, "", fmt.Errorf("failed to register a consumer: %w", err)
+	}
+
+	t.subs[sub] = true
+	return sub, msgs, tag, nil
+}
+
+// resubscribe replaces a consumer whose channel closed, retrying until it
+// succeeds, ctx is done or the transport is closed.
+func (t *RabbitTransport) resubscribe(ctx context.Context, queue string, old *rabbitSession) (*rabbitSession, <-chan amqp.Delivery, string, bool) {
+	t.mu.Lock()
+	if t.subs[old] {
+		delete(t.subs, old)
+		old.close()
+	}
+	closed := t.closed
+	t.mu.Unlock()
+
+	backoff := time.Second
+	for !closed {
+		sub, msgs, tag, err := t.subscribe(queue)
+		if err == nil {
+			log.Printf("Re-registered consumer of %s", queue)
+			return sub, msgs, tag, true
+		}
+		log.Printf("Failed to re-register consumer of %s: %v", queue, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, "", false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, consumeRetryMax)
+
+		t.mu.Lock()
+		closed = t.closed
+		t.mu.Unlock()
+	}
+	return nil, nil, "", false
+}
+
+// consume hands out the deliveries of queue until ctx is done or the
+// transport is closed. A consumer whose channel or connection closes is
+// registered again.
+func (t *RabbitTransport) consume(ctx context.Context, queue string) (<-chan Delivery, error) {
+	sub, msgs, tag, err := t.subscribe(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Once ctx is done the broker stops delivering; prefetched messages that
+	// were not handed out are requeued when the channel closes.
+	stop := func() {
+		if err := sub.ch.Cancel(tag, false); err != nil {
+			log.Printf("Failed to cancel consumer %s: %v", tag, err)
+		}
+	}
+
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for {
+			var d amqp.Delivery
+			var ok bool
+			select {
+			case d, ok = <-msgs:
+				if !ok {
+					if ctx.Err() != nil {
+						return
+					}
+					log.Printf("Consumer of %s stopped, re-registering", queue)
+					if sub, msgs, tag, ok = t.resubscribe(ctx, queue, sub); !ok {
+						return
+					}
+					continue
+				}
+			case <-ctx.Done():
+				stop()
+				return
+			}
+
+			delivery := Delivery{
+				Message: Message{Body: d.Body, ContentType: d.ContentType, ContentEncoding: d.ContentEncoding, Headers: d.Headers},
+				ack:     func() error { return d.Ack(false) },
+				nack:    func(requeue bool) error { return d.Nack(false, requeue) },
+			}
+
+			select {
+			case out <- delivery:
+			case <-ctx.Done():
+				stop()
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
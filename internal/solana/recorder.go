@@ -0,0 +1,267 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	recordingPrefix  = "blocks-"
+	recordingSuffix  = ".jsonl.zst"
+	recordingPartial = ".partial"
+
+	defaultRecordMaxBytes = 256 << 20
+	defaultRecordMaxAge   = time.Hour
+)
+
+// BlockRecorder appends every block it is given to zstd compressed JSON lines
+// files. A file is written as <name>.partial and renamed to
+// blocks-<first slot>-<last slot>.jsonl.zst when it is rotated.
+type BlockRecorder struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+
+	mu       sync.Mutex
+	file     *os.File
+	zw       *zstd.Encoder
+	written  int64
+	opened   time.Time
+	minSlot  uint64
+	maxSlot  uint64
+	lastFail time.Time
+}
+
+// NewBlockRecorderFromEnv records into QUEUE_RECORD_DIR, or returns nil when it
+// is not set. Files rotate after QUEUE_RECORD_MAX_MB of JSON or
+// QUEUE_RECORD_ROTATE, whichever comes first.
+func NewBlockRecorderFromEnv() *BlockRecorder {
+	dir := os.Getenv("QUEUE_RECORD_DIR")
+	if dir == "" {
+		return nil
+	}
+
+	maxBytes := int64(defaultRecordMaxBytes)
+	if mb, err := strconv.Atoi(os.Getenv("QUEUE_RECORD_MAX_MB")); err == nil && mb > 0 {
+		maxBytes = int64(mb) << 20
+	}
+	maxAge := defaultRecordMaxAge
+	if d, err := time.ParseDuration(os.Getenv("QUEUE_RECORD_ROTATE")); err == nil && d > 0 {
+		maxAge = d
+	}
+
+	return &BlockRecorder{dir: dir, maxBytes: maxBytes, maxAge: maxAge}
+}
+
+// Record never fails the caller; a recording problem is logged at most once a
+// minute and the block is dropped from the recording.
+func (r *BlockRecorder) Record(block types.BlockData) {
+	body, err := block.MarshalJSON()
+	if err == nil {
+		err = r.write(block.Block, body)
+	}
+	if err != nil {
+		r.mu.Lock()
+		if time.Since(r.lastFail) > time.Minute {
+			r.lastFail = time.Now()
+			log.Printf("Failed to record block %d: %v", block.Block, err)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *BlockRecorder) write(slot uint64, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != nil && (r.written >= r.maxBytes || time.Since(r.opened) >= r.maxAge) {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+
+	if r.written == 0 || slot < r.minSlot {
+		r.minSlot = slot
+	}
+	if slot > r.maxSlot {
+		r.maxSlot = slot
+	}
+
+	body = append(body, '\n')
+	if _, err := r.zw.Write(body); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	r.written += int64(len(body))
+	return nil
+}
+
+func (r *BlockRecorder) open() error {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create recording dir: %w", err)
+	}
+
+	r.opened = time.Now()
+	name := filepath.Join(r.dir, fmt.Sprintf("%s%d%s%s", recordingPrefix, r.opened.UnixNano(), recordingSuffix, recordingPartial))
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+	zw, err := zstd.NewWriter(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	r.file, r.zw, r.written, r.minSlot, r.maxSlot = f, zw, 0, 0, 0
+	return nil
+}
+
+// rotate closes the current file and gives it its final name. Callers hold r.mu.
+func (r *BlockRecorder) rotate() error {
+	if r.file == nil {
+		return nil
+	}
+
+	partial := r.file.Name()
+	err := r.zw.Close()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file, r.zw = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to close recording: %w", err)
+	}
+
+	if r.written == 0 {
+		return os.Remove(partial)
+	}
+	name := filepath.Join(r.dir, fmt.Sprintf("%s%d-%d%s", recordingPrefix, r.minSlot, r.maxSlot, recordingSuffix))
+	if _, err = os.Stat(name); err == nil {
+		name = strings.TrimSuffix(name, recordingSuffix) + fmt.Sprintf("-%d%s", r.opened.UnixNano(), recordingSuffix)
+	}
+	if err = os.Rename(partial, name); err != nil {
+		return fmt.Errorf("failed to finish recording: %w", err)
+	}
+	log.Printf("Recorded slots %d --> %d to %s", r.minSlot, r.maxSlot, name)
+	return nil
+}
+
+func (r *BlockRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotate()
+}
+
+// ReadRecordings returns the recorded blocks of slots from to to (0 = no upper
+// bound) found under path, a recording or a directory of them. Blocks are
+// ordered by slot, and by recording order within a slot, so replays are
+// repeatable. Unfinished .partial recordings are read up to where they end.
+func ReadRecordings(ctx context.Context, path string, from, to uint64) ([]types.BlockData, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recordings: %w", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recordings: %w", err)
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasPrefix(e.Name(), recordingPrefix) {
+				continue
+			}
+			if first, last, ok := recordingRange(e.Name()); ok && (last < from || (to != 0 && first > to)) {
+				continue
+			}
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+		sort.Strings(files)
+	}
+
+	var blocks []types.BlockData
+	for _, name := range files {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if blocks, err = readRecording(name, from, to, blocks); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].Block < blocks[j].Block })
+	return blocks, nil
+}
+
+func readRecording(name string, from, to uint64, blocks []types.BlockData) ([]types.BlockData, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return blocks, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		return blocks, fmt.Errorf("failed to decompress %s: %w", name, err)
+	}
+	defer zr.Close()
+
+	br := bufio.NewReaderSize(zr, 1<<20)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var block types.BlockData
+			if uerr := block.UnmarshalJSON(line); uerr != nil {
+				return blocks, fmt.Errorf("failed to decode a block in %s: %w", name, uerr)
+			}
+			if block.Block >= from && (to == 0 || block.Block <= to) {
+				blocks = append(blocks, block)
+			}
+		}
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			// A recorder that died mid-write leaves a truncated frame behind.
+			if strings.HasSuffix(name, recordingPartial) || errors.Is(err, io.ErrUnexpectedEOF) {
+				log.Printf("Stopped reading %s early: %v", name, err)
+				return blocks, nil
+			}
+			return blocks, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+}
+
+// recordingRange parses blocks-<first>-<last>.jsonl.zst.
+func recordingRange(name string) (uint64, uint64, bool) {
+	if !strings.HasSuffix(name, recordingSuffix) {
+		return 0, 0, false
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, recordingPrefix), recordingSuffix), "-")
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	first, err1 := strconv.ParseUint(parts[0], 10, 64)
+	last, err2 := strconv.ParseUint(parts[1], 10, 64)
+	return first, last, err1 == nil && err2 == nil
+}
@@ -0,0 +1,49 @@
+package solana
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	r := &BlockRecorder{dir: dir, maxBytes: 1 << 10, maxAge: defaultRecordMaxAge}
+
+	block := sampleBlock(2)
+	for _, slot := range []uint64{105, 101, 103, 101, 110, 102} {
+		block.Block = slot
+		r.Record(block)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) < 2 {
+		t.Fatalf("expected the recording to rotate, got %d files", len(entries))
+	}
+	for _, e := range entries {
+		if _, _, ok := recordingRange(e.Name()); !ok {
+			t.Fatalf("unexpected recording name %s", e.Name())
+		}
+	}
+
+	blocks, err := ReadRecordings(context.Background(), dir, 101, 105)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slots []uint64
+	for _, b := range blocks {
+		slots = append(slots, b.Block)
+	}
+	want := []uint64{101, 101, 102, 103, 105}
+	if len(slots) != len(want) {
+		t.Fatalf("got slots %v, want %v", slots, want)
+	}
+	for i := range want {
+		if slots[i] != want[i] {
+			t.Fatalf("got slots %v, want %v", slots, want)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package solana
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSegmentDir   = "queue"
+	segmentExt          = ".seg"
+	segmentMaxBytes     = 64 << 20
+	segmentHeaderSize   = 12
+	segmentPollInterval = 200 * time.Millisecond
+)
+
+type segmentMeta struct {
+	ContentType     string                 `json:"content_type,omitempty"`
+	ContentEncoding string                 `json:"content_encoding,omitempty"`
+	Headers         map[string]interface{} `json:"headers,omitempty"`
+}
+
+type segmentPosition struct {
+	segment uint64
+	offset  int64
+}
+
+type segmentRecord struct {
+	msg   Message
+	end   segmentPosition
+	acked bool
+}
+
+// SegmentTransport is an append-only queue on local disk. Messages are written
+// to numbered segment files as
+//
+//	[meta len][body len][crc32] meta body
+//
+// and the consumer's position is kept in an offset file next to them, so a
+// producer and a consumer in different processes can share a directory.
+// Each directory supports one producer and one consumer at a time. Segments
+// are removed once the consumer has moved past them.
+type SegmentTransport struct {
+	dir   string
+	queue string
+
+	mu      sync.Mutex
+	file    *os.File
+	segment uint64
+	size    int64
+
+	ackMu    sync.Mutex
+	inflight []*segmentRecord
+
+	dlqOnce     sync.Once
+	deadLetters *SegmentTransport
+	dlqErr      error
+}
+
+func NewSegmentTransport(dir, queue string) (*SegmentTransport, error) {
+	if dir == "" {
+		dir = defaultSegmentDir
+	}
+	if err := os.MkdirAll(filepath.Join(dir, queue), 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create segment dir: %w", err)
+	}
+
+	return &SegmentTransport{dir: filepath.Join(dir, queue), queue: queue}, nil
+}
+
+// deadLetterQueue lives in a sibling directory with its own consumer offset.
+func (t *SegmentTransport) deadLetterQueue() (*SegmentTransport, error) {
+	t.dlqOnce.Do(func() {
+		t.deadLetters, t.dlqErr = NewSegmentTransport(filepath.Dir(t.dir), t.queue+deadLetterSuffix)
+	})
+	return t.deadLetters, t.dlqErr
+}
+
+func (t *SegmentTransport) DeadLetter(msg Message, reason string) error {
+	dlq, err := t.deadLetterQueue()
+	if err != nil {
+		return err
+	}
+	return dlq.Publish(deadLetterMessage(msg, reason))
+}
+
+func (t *SegmentTransport) ConsumeDeadLetters(ctx context.Context) (<-chan Delivery, error) {
+	dlq, err := t.deadLetterQueue()
+	if err != nil {
+		return nil, err
+	}
+	return dlq.Consume(ctx)
+}
+
+func (t *SegmentTransport) segmentPath(segment uint64) string {
+	return filepath.Join(t.dir, fmt.Sprintf("%020d%s", segment, segmentExt))
+}
+
+func (t *SegmentTransport) offsetPath() string {
+	return filepath.Join(t.dir, "consumer.offset")
+}
+
+func (t *SegmentTransport) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list segments: %w", err)
+	}
+
+	var segments []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, n)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+	return segments, nil
+}
+
+// openSegment starts a fresh segment after the newest one on disk. A producer
+// never appends to an existing file, so a record torn by a crash is always at
+// the end of a sealed segment and the consumer can skip past it.
+func (t *SegmentTransport) openSegment() error {
+	if t.file != nil {
+		if err := t.file.Close(); err != nil {
+			log.Printf("Failed to close segment %d: %v", t.segment, err)
+		}
+		t.file = nil
+	}
+
+	segments, err := t.segments()
+	if err != nil {
+		return err
+	}
+
+	next := t.segment + 1
+	if len(segments) > 0 && segments[len(segments)-1] >= next {
+		next = segments[len(segments)-1] + 1
+	}
+
+	f, err := os.OpenFile(t.segmentPath(next), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot create segment: %w", err)
+	}
+
+	t.file = f
+	t.segment = next
+	t.size = 0
+	return nil
+}
+
+func (t *SegmentTransport) Publish(msg Message) error {
+	meta, err := json.Marshal(segmentMeta{ContentType: msg.ContentType, ContentEncoding: msg.ContentEncoding, Headers: msg.Headers})
+	if err != nil {
+		return fmt.Errorf("cannot encode message meta: %w", err)
+	}
+
+	record := make([]byte, segmentHeaderSize+len(meta)+len(msg.Body))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(meta)))
+	binary.BigEndian.PutUint32(record[4:8], uint32(len(msg.Body)))
+	copy(record[segmentHeaderSize:], meta)
+	copy(record[segmentHeaderSize+len(meta):], msg.Body)
+	binary.BigEndian.PutUint32(record[8:12], crc32.ChecksumIEEE(record[segmentHeaderSize:]))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil || t.size >= segmentMaxBytes {
+		if err = t.openSegment(); err != nil {
+			return err
+		}
+	}
+
+	n, err := t.file.Write(record)
+	t.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("cannot append to segment: %w", err)
+	}
+
+	return nil
+}
+
+func (t *SegmentTransport) loadOffset() (segmentPosition, error) {
+	b, err := os.ReadFile(t.offsetPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return segmentPosition{}, nil
+		}
+		return segmentPosition{}, fmt.Errorf("cannot read consumer offset: %w", err)
+	}
+
+	var pos segmentPosition
+	if _, err = fmt.Sscanf(string(b), "%d %d", &pos.segment, &pos.offset); err != nil {
+		return segmentPosition{}, fmt.Errorf("invalid consumer offset: %w", err)
+	}
+	return pos, nil
+}
+
+func (t *SegmentTransport) saveOffset(pos segmentPosition) error {
+	tmp := t.offsetPath() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d", pos.segment, pos.offset)), 0o644); err != nil {
+		return fmt.Errorf("cannot write consumer offset: %w", err)
+	}
+	if err := os.Rename(tmp, t.offsetPath()); err != nil {
+		return fmt.Errorf("cannot replace consumer offset: %w", err)
+	}
+
+	segments, err := t.segments()
+	if err != nil {
+		return err
+	}
+	for _, s := range segments {
+		if s >= pos.segment {
+			break
+		}
+		if err = os.Remove(t.segmentPath(s)); err != nil {
+			log.Printf("Failed to remove consumed segment %d: %v", s, err)
+		}
+	}
+	return nil
+}
+
+// ack marks rec as done and commits the offset of the longest acknowledged
+// prefix, so nothing unacknowledged is skipped after a restart.
+func (t *SegmentTransport) ack(rec *segmentRecord) error {
+	t.ackMu.Lock()
+	defer t.ackMu.Unlock()
+
+	rec.acked = true
+
+	var commit *segmentPosition
+	for len(t.inflight) > 0 && t.inflight[0].acked {
+		commit = &t.inflight[0].end
+		t.inflight = t.inflight[1:]
+	}
+
+	if commit == nil {
+		return nil
+	}
+	return t.saveOffset(*commit)
+}
+
+func (t *SegmentTransport) Consume(ctx context.Context) (<-chan Delivery, error) {
+	pos, err := t.loadOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Delivery)
+	requeued := make(chan *segmentRecord, 1024)
+
+	deliver := func(rec *segmentRecord) bool {
+		delivery := Delivery{
+			Message: rec.msg,
+			ack:     func() error { return t.ack(rec) },
+			nack: func(requeue bool) error {
+				if requeue {
+					requeued <- rec
+					return nil
+				}
+				return t.ack(rec)
+			},
+		}
+
+		select {
+		case out <- delivery:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case rec := <-requeued:
+				if !deliver(rec) {
+					return
+				}
+				continue
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			rec, next, err := t.read(pos)
+			if err != nil {
+				log.Printf("Failed to read segment %d at %d: %v", pos.segment, pos.offset, err)
+			}
+			if rec == nil {
+				if next == pos {
+					select {
+					case <-time.After(segmentPollInterval):
+					case rec := <-requeued:
+						if !deliver(rec) {
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+				}
+				pos = next
+				continue
+			}
+
+			pos = next
+			t.ackMu.Lock()
+			t.inflight = append(t.inflight, rec)
+			t.ackMu.Unlock()
+
+			if !deliver(rec) {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// read returns the record at pos and the position after it. When no complete
+// record is available it returns nil and either pos (wait for more data) or
+// the start of the following segment if this one is sealed.
+func (t *SegmentTransport) read(pos segmentPosition) (*segmentRecord, segmentPosition, error) {
+	segments, err := t.segments()
+	if err != nil {
+		return nil, pos, err
+	}
+
+	var later *uint64
+	current := false
+	for i, s := range segments {
+		if s == pos.segment {
+			current = true
+		}
+		if s > pos.segment {
+			later = &segments[i]
+			break
+		}
+	}
+
+	skip := func() (*segmentRecord, segmentPosition, error) {
+		if later == nil {
+			return nil, pos, nil
+		}
+		return nil, segmentPosition{segment: *later}, nil
+	}
+
+	if !current {
+		return skip()
+	}
+
+	f, err := os.Open(t.segmentPath(pos.segment))
+	if err != nil {
+		return nil, pos, fmt.Errorf("cannot open segment: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, segmentHeaderSize)
+	if _, err = f.ReadAt(header, pos.offset); err != nil {
+		return skip()
+	}
+
+	metaLen := int64(binary.BigEndian.Uint32(header[0:4]))
+	bodyLen := int64(binary.BigEndian.Uint32(header[4:8]))
+	sum := binary.BigEndian.Uint32(header[8:12])
+
+	payload := make([]byte, metaLen+bodyLen)
+	if _, err = f.ReadAt(payload, pos.offset+segmentHeaderSize); err != nil {
+		return skip()
+	}
+
+	end := segmentPosition{segment: pos.segment, offset: pos.offset + segmentHeaderSize + metaLen + bodyLen}
+	if crc32.ChecksumIEEE(payload) != sum {
+		if later == nil {
+			return nil, pos, nil
+		}
+		rec, next, _ := skip()
+		return rec, next, fmt.Errorf("corrupt record, skipping rest of segment")
+	}
+
+	var meta segmentMeta
+	if err = json.Unmarshal(payload[:metaLen], &meta); err != nil {
+		return nil, end, fmt.Errorf("cannot decode message meta: %w", err)
+	}
+
+	rec := &segmentRecord{
+		msg: Message{Body: payload[metaLen:], ContentType: meta.ContentType, ContentEncoding: meta.ContentEncoding, Headers: meta.Headers},
+		end: end,
+	}
+	return rec, end, nil
+}
+
+func (t *SegmentTransport) Close() error {
+	if t.deadLetters != nil {
+		if err := t.deadLetters.Close(); err != nil {
+			log.Printf("Failed to close dead-letter segments: %v", err)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	return err
+}
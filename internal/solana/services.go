@@ -6,10 +6,11 @@ import (
 	"context"
 	solClient "github.com/blocto/solana-go-sdk/client"
 	"github.com/rpcpool/yellowstone-grpc/examples/golang/proto"
-	"github.com/streadway/amqp"
+	"golang.org/x/time/rate"
 	"net/http"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type SolanaService struct {
@@ -25,9 +26,11 @@ type TokenFinder struct {
 	processor *TokenProcessor
 }
 type TokenProcessor struct {
-	queue chan string
-	seen  sync.Map
-	wg    sync.WaitGroup
+	queue  chan string
+	seen   sync.Map
+	wg     sync.WaitGroup
+	mu     sync.RWMutex // guards closed against sends on a closed queue
+	closed bool
 }
 
 type PairsService struct {
@@ -39,9 +42,11 @@ type PairsService struct {
 	processor *PairProcessor
 }
 type PairProcessor struct {
-	queue chan PairProcessorQueue
-	seen  sync.Map
-	wg    sync.WaitGroup
+	queue  chan PairProcessorQueue
+	seen   sync.Map
+	wg     sync.WaitGroup
+	mu     sync.RWMutex // guards closed against sends on a closed queue
+	closed bool
 }
 type PairProcessorQueue struct {
 	address string
@@ -49,36 +54,57 @@ type PairProcessorQueue struct {
 }
 
 type TxHandler struct {
-	sh     *SwapHandler
-	solSvc *SolanaService
-	repo   TokensAndPairsRepo
-	pRepo  SwapsRepo
+	sh         *SwapHandler
+	solSvc     *SolanaService
+	repo       TokensAndPairsRepo
+	pRepo      SwapsRepo
+	parseStats parseCounters
 
-	Wg        sync.WaitGroup
+	Wg        sync.WaitGroup // token and supply writes still in flight
 	TxChan    chan types.SolanaBlockTx
 	Websocket *websocket.WebSocketServer
 }
 
 type BackfillService struct {
 	solSvc       *SolanaService
-	pRepo        SwapsRepo
+	pRepo        BackfillRepo
 	queueHandler *QueueHandler
-	nodeUrls     []*Node
+	nodes        *NodePool
 }
 
 type QueueHandler struct {
 	txHandler *TxHandler
-	conn      *amqp.Connection
-	ch        *amqp.Channel
+	transport Transport
+	encoding  string
+	recorder  *BlockRecorder
 	mu        sync.Mutex
 	pRepo     SwapsRepo
 
 	ctx context.Context
 
-	rabbitChan chan amqp.Delivery
+	deliveries <-chan Delivery
 	workerWg   sync.WaitGroup
-	workers    int
-	workerPool map[int]context.CancelFunc
+	workerPool map[int]chan struct{} // closed to stop the worker
+	nextWorker int
+	consumed   chan struct{} // closed once the deliveries channel has closed
+	endOnce    sync.Once
+
+	minWorkers  int
+	maxWorkers  int
+	txWorkers   int // transactions of a block parsed in parallel
+	maxPrefetch int
+	slowInsert  time.Duration
+
+	stats         QueueStats   // guarded by mu
+	insertNs      atomic.Int64 // moving average of swap insert latency
+	lastBlockTime atomic.Int64 // newest live block handled
+
+	// workCtx outlives the consumer so a shutdown can finish what it holds.
+	workCtx  context.Context
+	stopWork context.CancelFunc
+	done     chan struct{}
+	inFlight atomic.Int64
+	requeued atomic.Int64
 }
 
 type SwapHandler struct {
@@ -90,15 +116,48 @@ type Node struct {
 	name    string
 	url     string
 	cli     *http.Client
-	counter atomic.Int64
-	timings atomic.Int64
+	counter atomic.Int64 // requests made through a NodePool
+	timings atomic.Int64 // moving average of their latency in ns
+
+	// Guarded by the NodePool the node belongs to.
+	limiter      *rate.Limiter
+	errorRate    float64
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
 }
 
 type BlockListener struct {
+	endpoints    []*GeyserEndpoint
+	queueHandler *QueueHandler
+	backfill     *BackfillService
+	checkpoint   *SlotCheckpoint
+	seen         *SignatureWindow
+	filters      []TxFilter
+	routes       map[string]*txRoute // by queue
+	filterQueues map[string]string   // filter name -> queue
+	commitment   *CommitmentTracker
+	pools        *PoolStateTracker
+	level        string
+	highestSlot  atomic.Uint64
+}
+
+// txRoute batches the transactions of one queue into slots.
+type txRoute struct {
+	queue   string
+	handler *QueueHandler
+	batcher *SlotBatcher
+}
+
+type GeyserEndpoint struct {
 	Client       proto.GeyserClient
 	Subscription proto.Geyser_SubscribeClient
-	grpcAddress  string
-	queueHandler *QueueHandler
+	address      string
 	authToken    string
 	pingId       int32
+
+	replayFailed bool
+	resyncing    bool
+
+	stats endpointStats
 }
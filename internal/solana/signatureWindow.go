@@ -0,0 +1,43 @@
+package solana
+
+import (
+	"sync"
+	"time"
+)
+
+// SignatureWindow remembers the most recent signatures seen across all geyser
+// endpoints so only the first copy of a transaction is forwarded. Once full,
+// the oldest signature is forgotten to make room for the next one.
+type SignatureWindow struct {
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	order []string
+	next  int
+}
+
+func NewSignatureWindow(size int) *SignatureWindow {
+	return &SignatureWindow{
+		seen:  make(map[string]time.Time, size),
+		order: make([]string, size),
+	}
+}
+
+// Observe reports whether sig is new. For a repeat it also returns how long
+// after the first copy this one arrived.
+func (w *SignatureWindow) Observe(sig string, now time.Time) (bool, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if firstAt, ok := w.seen[sig]; ok {
+		return false, now.Sub(firstAt)
+	}
+
+	if evicted := w.order[w.next]; evicted != "" {
+		delete(w.seen, evicted)
+	}
+	w.order[w.next] = sig
+	w.next = (w.next + 1) % len(w.order)
+	w.seen[sig] = now
+
+	return true, 0
+}
@@ -0,0 +1,180 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSlotBatchSize  = 2000
+	defaultSlotFlushAfter = 10 * time.Second
+	slotDuration          = 400 * time.Millisecond
+	completedSlotsKept    = 512
+)
+
+type slotBuffer struct {
+	txs     []types.SolanaTx
+	firstAt time.Time
+}
+
+// SlotBatcher collects the transactions of a slot and publishes them as one
+// BlockData once the slot's block meta arrives. A slot that grows past maxTxs
+// is flushed early, and one whose meta never shows up is flushed after maxAge
+// with an estimated block time.
+type SlotBatcher struct {
+	mu        sync.Mutex
+	slots     map[uint64]*slotBuffer
+	completed map[uint64]int64
+	failed    map[uint64]error // publish errors of slots not completed yet
+	maxTxs    int
+	maxAge    time.Duration
+	publish   func(types.BlockData) error
+
+	lastMetaSlot uint64
+	lastMetaTime int64
+}
+
+func NewSlotBatcher(maxTxs int, maxAge time.Duration, publish func(types.BlockData) error) *SlotBatcher {
+	if maxTxs <= 0 {
+		maxTxs = defaultSlotBatchSize
+	}
+	if maxAge <= 0 {
+		maxAge = defaultSlotFlushAfter
+	}
+
+	return &SlotBatcher{
+		slots:     make(map[uint64]*slotBuffer),
+		completed: make(map[uint64]int64),
+		failed:    make(map[uint64]error),
+		maxTxs:    maxTxs,
+		maxAge:    maxAge,
+		publish:   publish,
+	}
+}
+
+func (b *SlotBatcher) Add(slot uint64, tx types.SolanaTx) {
+	var ready []types.BlockData
+
+	b.mu.Lock()
+	if blockTime, ok := b.completed[slot]; ok {
+		// Straggler for a slot that was already published.
+		ready = append(ready, types.BlockData{Transactions: []types.SolanaTx{tx}, Block: slot, Timestamp: blockTime})
+	} else {
+		buf, ok := b.slots[slot]
+		if !ok {
+			buf = &slotBuffer{firstAt: time.Now()}
+			b.slots[slot] = buf
+		}
+		buf.txs = append(buf.txs, tx)
+
+		if len(buf.txs) >= b.maxTxs {
+			ready = append(ready, types.BlockData{Transactions: buf.txs, Block: slot, Timestamp: b.estimateBlockTime(slot, buf.firstAt)})
+			buf.txs = nil
+		}
+	}
+	b.mu.Unlock()
+
+	b.publishAll(ready)
+}
+
+// Complete publishes everything buffered for slot with its real block time. It
+// returns the error of any part of the slot that failed to publish, including
+// the parts flushed early.
+func (b *SlotBatcher) Complete(slot uint64, blockTime int64) error {
+	var ready []types.BlockData
+
+	b.mu.Lock()
+	if _, ok := b.completed[slot]; ok {
+		b.mu.Unlock()
+		return nil
+	}
+
+	if blockTime > 0 && slot > b.lastMetaSlot {
+		b.lastMetaSlot = slot
+		b.lastMetaTime = blockTime
+	}
+	if blockTime == 0 {
+		blockTime = b.estimateBlockTime(slot, time.Now())
+	}
+
+	if buf, ok := b.slots[slot]; ok {
+		if len(buf.txs) > 0 {
+			ready = append(ready, types.BlockData{Transactions: buf.txs, Block: slot, Timestamp: blockTime})
+		}
+		delete(b.slots, slot)
+	}
+
+	b.completed[slot] = blockTime
+	for s := range b.completed {
+		if s+completedSlotsKept < slot {
+			delete(b.completed, s)
+		}
+	}
+	earlier := b.failed[slot]
+	delete(b.failed, slot)
+	for s := range b.failed {
+		if s+completedSlotsKept < slot {
+			delete(b.failed, s)
+		}
+	}
+	b.mu.Unlock()
+
+	return errors.Join(earlier, b.publishAll(ready))
+}
+
+// Run flushes slots whose block meta is overdue.
+func (b *SlotBatcher) Run() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var ready []types.BlockData
+
+		b.mu.Lock()
+		for slot, buf := range b.slots {
+			if time.Since(buf.firstAt) < b.maxAge {
+				continue
+			}
+			if len(buf.txs) > 0 {
+				ready = append(ready, types.BlockData{Transactions: buf.txs, Block: slot, Timestamp: b.estimateBlockTime(slot, buf.firstAt)})
+			}
+			delete(b.slots, slot)
+		}
+		b.mu.Unlock()
+
+		b.publishAll(ready)
+	}
+}
+
+// estimateBlockTime extrapolates from the newest block meta using the nominal
+// slot duration, falling back to when the slot was first seen.
+func (b *SlotBatcher) estimateBlockTime(slot uint64, seenAt time.Time) int64 {
+	if b.lastMetaTime == 0 {
+		return seenAt.Unix()
+	}
+
+	offset := (time.Duration(slot) - time.Duration(b.lastMetaSlot)) * slotDuration
+	return b.lastMetaTime + int64(offset/time.Second)
+}
+
+// publishAll publishes blocks and returns their errors. Failures of slots that
+// are not completed yet are kept for Complete to report.
+func (b *SlotBatcher) publishAll(blocks []types.BlockData) error {
+	var errs []error
+	for _, block := range blocks {
+		err := b.publish(block)
+		if err == nil {
+			continue
+		}
+		errs = append(errs, err)
+
+		b.mu.Lock()
+		if _, ok := b.completed[block.Block]; !ok {
+			b.failed[block.Block] = errors.Join(b.failed[block.Block], err)
+		}
+		b.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
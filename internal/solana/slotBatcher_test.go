@@ -0,0 +1,37 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSlotBatcherReportsPublishErrors(t *testing.T) {
+	failing := map[uint64]bool{100: true}
+	var published []uint64
+	b := NewSlotBatcher(2, time.Minute, func(block types.BlockData) error {
+		if failing[block.Block] {
+			return errors.New("queue down")
+		}
+		published = append(published, block.Block)
+		return nil
+	})
+
+	// The first half of slot 100 is flushed early and fails.
+	b.Add(100, types.SolanaTx{})
+	b.Add(100, types.SolanaTx{})
+	failing[100] = false
+	b.Add(100, types.SolanaTx{})
+	if err := b.Complete(100, 1700000000); err == nil {
+		t.Fatal("slot 100 completed without the error of its early flush")
+	}
+
+	b.Add(101, types.SolanaTx{})
+	if err := b.Complete(101, 1700000000); err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 2 || published[0] != 100 || published[1] != 101 {
+		t.Fatalf("published %v", published)
+	}
+}
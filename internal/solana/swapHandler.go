@@ -5,6 +5,7 @@ import (
 	"blocsy/internal/types"
 	"context"
 	"math/big"
+	"strconv"
 	"time"
 )
 
@@ -16,18 +17,31 @@ func NewSwapHandler(tf SolanaTokenFinder, pf SolanaPairFinder) *SwapHandler {
 }
 
 func (sh *SwapHandler) HandleSwaps(ctx context.Context, transfers []types.SolTransfer, tx *types.SolanaTx, timestamp int64, block uint64) []types.SwapLog {
-	if !validateTX(tx) {
+	return sh.handleSwaps(ctx, transfers, tx, timestamp, block, nil)
+}
+
+// handleSwaps records in diag the swap instructions it could not decode.
+func (sh *SwapHandler) handleSwaps(ctx context.Context, transfers []types.SolTransfer, tx *types.SolanaTx, timestamp int64, block uint64, diag *types.ParseDiagnostics) []types.SwapLog {
+	if !validateTX(tx) || len(tx.Transaction.Signatures) == 0 {
 		return []types.SwapLog{}
 	}
 	swaps := make([]types.SolSwap, 0)
 	accountKeys := getAllAccountKeys(tx)
+	routes := findJupiterRoutes(tx, accountKeys, diag)
 
 	for i := 0; i < len(transfers); i++ {
 		transfer := transfers[i]
-		if found, _ := IgnorePrograms[transfer.ParentProgramId]; found {
-			continue
+		// The trade of a route goes before its legs.
+		route := findRoute(routes, transfer)
+		if route != nil && !route.emitted {
+			route.emitted = true
+			swaps = append(swaps, route.swap)
+		}
+		swap, inc, err := processTransfer(i, transfers, accountKeys)
+		if err != nil {
+			ixIndex, innerIndex := transferPosition(transfer)
+			diag.Skip(ixIndex, innerIndex, transfer.ParentProgramId, err)
 		}
-		swap, inc := processTransfer(i, transfers, accountKeys)
 
 		if source := Programs[transfer.ParentProgramId]; source != "" {
 			swap.Source = source
@@ -37,8 +51,13 @@ func (sh *SwapHandler) HandleSwaps(ctx context.Context, transfers []types.SolTra
 			if swap.TokenIn == swap.TokenOut {
 				continue
 			}
+			if route != nil {
+				route.hops++
+				swap.Hop = route.hops
+			}
+			diag.Decode(transfer.ParentProgramId)
 			swaps = append(swaps, swap)
-		} else if transfer.Type != "native" && (validateSupportedDex(transfer.ParentProgramId) || transfer.ParentProgramId == "") {
+		} else if route == nil && transfer.Type != "native" && (validateSupportedDex(transfer.ParentProgramId) || transfer.ParentProgramId == "") {
 			if _, found := QuoteTokens[transfer.Mint]; found {
 				continue
 			}
@@ -59,6 +78,11 @@ func (sh *SwapHandler) HandleSwaps(ctx context.Context, transfers []types.SolTra
 		}
 		i += inc
 	}
+	for _, route := range routes {
+		if !route.emitted {
+			swaps = append(swaps, route.swap)
+		}
+	}
 
 	builtSwaps := make([]types.SwapLog, 0)
 	balanceSheet := map[string]map[string]float64{}
@@ -131,9 +155,23 @@ func (sh *SwapHandler) HandleSwaps(ctx context.Context, transfers []types.SolTra
 			Pair:        swap.Pair,
 			Token:       token,
 			Processed:   false,
+			Hop:         swap.Hop,
+			Creator:     swap.Creator,
+		}
+		if swap.Fee != "" {
+			s.Fee, _ = strconv.ParseFloat(swap.Fee, 64)
+		}
+		if swap.BaseReserve != "" {
+			s.BaseReserve = &swap.BaseReserve
+			s.QuoteReserve = &swap.QuoteReserve
 		}
 		builtSwaps = append(builtSwaps, s)
 
+		// Legs of a route are already counted in its trade.
+		if swap.Hop > 0 {
+			continue
+		}
+
 		if _, found := balanceSheet[swap.Wallet]; !found {
 			balanceSheet[swap.Wallet] = map[string]float64{
 				token: amountInF - amountOutF,
@@ -145,7 +183,7 @@ func (sh *SwapHandler) HandleSwaps(ctx context.Context, transfers []types.SolTra
 
 	finalSwaps := make([]types.SwapLog, 0)
 	for _, swap := range builtSwaps {
-		if balanceSheet[swap.Wallet][swap.Token] == 0 {
+		if swap.Hop == 0 && balanceSheet[swap.Wallet][swap.Token] == 0 {
 			continue
 		}
 		if _, found := IgnoreToUsers[swap.Wallet]; found {
@@ -157,8 +195,28 @@ func (sh *SwapHandler) HandleSwaps(ctx context.Context, transfers []types.SolTra
 	return finalSwaps
 }
 
-func processTransfer(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int) {
-	type handlerFunc func(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int)
+// transferPosition is the index of the outer instruction a transfer belongs
+// to, and its index among the inner instructions or -1. For inner transfers,
+// SolTransfer keeps the outer index in InnerIndex.
+func transferPosition(transfer types.SolTransfer) (int, int) {
+	if transfer.InnerIndex < 0 {
+		return transfer.IxIndex, -1
+	}
+	return transfer.InnerIndex, transfer.IxIndex
+}
+
+// findRoute is the Jupiter route the transfer is a leg of, or nil.
+func findRoute(routes []*jupiterRoute, transfer types.SolTransfer) *jupiterRoute {
+	for _, route := range routes {
+		if route.contains(transfer) {
+			return route
+		}
+	}
+	return nil
+}
+
+func processTransfer(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error) {
+	type handlerFunc func(index int, transfers []types.SolTransfer, accountKeys []string) (types.SolSwap, int, error)
 	handlers := map[string]handlerFunc{
 		RAYDIUM_LIQ_POOL_V4:      dex.HandleRaydiumSwaps,
 		RAYDIUM_CONCENTRATED_LIQ: dex.HandleRaydiumConcentratedSwaps,
@@ -166,19 +224,29 @@ func processTransfer(index int, transfers []types.SolTransfer, accountKeys []str
 		RAYDIUM_LAUNCHPAD:        dex.HandleRaydiumLaunchpadSwaps,
 		ORCA_WHIRL_PROGRAM_ID:    dex.HandleOrcaSwaps,
 		METEORA_DLMM_PROGRAM:     dex.HandleMeteoraSwaps,
+		METEORA_POOLS_PROGRAM:    dex.HandleMeteoraPoolsSwaps,
 		PUMPFUN:                  dex.HandlePumpFunSwaps,
 		PUMPFUN_AMM:              dex.HandlePumpFunAmmSwaps,
+		LIFINITY_SWAP_V2:         dex.HandleLifinitySwaps,
+		PHOENIX:                  dex.HandlePhoenixSwaps,
+		FLUXBEAM_PROGRAM:         dex.HandleFluxbeamSwaps,
+		ORCA_SWAP:                dex.HandleOrcaTokenSwaps,
+		ORCA_SWAP_V2:             dex.HandleOrcaTokenSwaps,
 	}
 
 	programId := transfers[index].ParentProgramId
 
 	handler, exists := handlers[programId]
 	if !exists {
-		return types.SolSwap{}, 0
+		return types.SolSwap{}, 0, nil
 	}
 
+	if err := checkAccounts(programId, transfers[index].IxAccounts, 0, accountKeys); err != nil {
+		return types.SolSwap{}, 0, err
+	}
+	// Other instructions of the program, like adding liquidity.
 	if !validateDexInstruction(programId, transfers[index].IxAccounts, accountKeys) {
-		return types.SolSwap{}, 0
+		return types.SolSwap{}, 0, nil
 	}
 
 	return handler(index, transfers, accountKeys)
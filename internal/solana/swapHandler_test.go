@@ -0,0 +1,455 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/mr-tron/base58"
+	"testing"
+)
+
+const (
+	wsolMint  = "So11111111111111111111111111111111111111112"
+	tokenMint = "2b1kV6DkPAnxd5ixfnxCpjxmKwqjjaYmCZfHsFu24GXo"
+)
+
+type tokenQueueStub struct{ SolanaTokenFinder }
+
+func (tokenQueueStub) AddToQueue(string) {}
+
+type pairQueueStub struct{ SolanaPairFinder }
+
+func (pairQueueStub) AddToQueue(PairProcessorQueue) {}
+
+// txFixture builds a transaction of one venue instruction whose token
+// movements are its inner instructions. Accounts are named; token accounts
+// resolve to their owner and mint through the post balances.
+type txFixture struct {
+	keys     []string
+	balances []types.TokenBalance
+	inner    []types.Instruction
+}
+
+func (f *txFixture) index(key string) int {
+	for i, k := range f.keys {
+		if k == key {
+			return i
+		}
+	}
+	f.keys = append(f.keys, key)
+	return len(f.keys) - 1
+}
+
+func (f *txFixture) indexes(keys ...string) []int {
+	accounts := make([]int, len(keys))
+	for i, key := range keys {
+		accounts[i] = f.index(key)
+	}
+	return accounts
+}
+
+func (f *txFixture) tokenAccount(account string, owner string, mint string, decimals int) {
+	f.balances = append(f.balances, types.TokenBalance{
+		AccountIndex:  f.index(account),
+		Mint:          mint,
+		Owner:         owner,
+		ProgramId:     TOKEN_PROGRAM,
+		UITokenAmount: types.UITokenAmount{Decimals: decimals, UiAmountString: "1"},
+	})
+}
+
+func (f *txFixture) token(tag byte, amount uint64, accounts ...string) {
+	data := binary.LittleEndian.AppendUint64([]byte{tag}, amount)
+	f.inner = append(f.inner, types.Instruction{ProgramIdIndex: f.index(TOKEN_PROGRAM), Accounts: f.indexes(accounts...), Data: base58.Encode(data)})
+}
+
+func (f *txFixture) transfer(from string, to string, authority string, amount uint64) {
+	f.token(3, amount, from, to, authority)
+}
+
+func (f *txFixture) mintTo(mint string, to string, authority string, amount uint64) {
+	f.token(7, amount, mint, to, authority)
+}
+
+func (f *txFixture) tx(program string, accounts ...string) *types.SolanaTx {
+	outer := types.Instruction{ProgramIdIndex: f.index(program), Accounts: f.indexes(accounts...), Data: base58.Encode([]byte{1})}
+	tx := &types.SolanaTx{}
+	tx.Transaction.Signatures = []string{"fixture"}
+	tx.Transaction.Message.AccountKeys = f.keys
+	tx.Transaction.Message.Instructions = []types.Instruction{outer}
+	tx.Meta.InnerInstructions = []types.InnerInstruction{{Index: 0, Instructions: f.inner}}
+	tx.Meta.PostTokenBalances = f.balances
+	return tx
+}
+
+func handleFixture(t *testing.T, tx *types.SolanaTx) []types.SwapLog {
+	t.Helper()
+	var diag types.ParseDiagnostics
+	transfers, _, _, _ := parseTransaction(tx, &diag)
+	sh := NewSwapHandler(tokenQueueStub{}, pairQueueStub{})
+	swaps := sh.handleSwaps(context.Background(), transfers, tx, 1700000000, 300, &diag)
+	if len(diag.Skipped) > 0 {
+		t.Fatalf("skipped %+v", diag.Skipped)
+	}
+	return swaps
+}
+
+func checkSwap(t *testing.T, swaps []types.SwapLog, want types.SwapLog) {
+	t.Helper()
+	if len(swaps) != 1 {
+		t.Fatalf("got %d swaps: %+v", len(swaps), swaps)
+	}
+	got := swaps[0]
+	if got.Wallet != want.Wallet || got.Pair != want.Pair || got.Source != want.Source || got.Action != want.Action ||
+		got.Token != tokenMint || got.AmountOut != want.AmountOut || got.AmountIn != want.AmountIn {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLifinitySwap(t *testing.T) {
+	f := &txFixture{}
+	f.tokenAccount("userSol", "trader", wsolMint, 9)
+	f.tokenAccount("userToken", "trader", tokenMint, 6)
+	f.tokenAccount("poolSol", "lifinityAuthority", wsolMint, 9)
+	f.tokenAccount("poolToken", "lifinityAuthority", tokenMint, 6)
+	f.tokenAccount("poolFee", "lifinityAuthority", "poolLp", 6)
+	f.transfer("userSol", "poolSol", "trader", 2_000_000_000)
+	f.mintTo("poolLp", "poolFee", "lifinityAuthority", 15)
+	f.transfer("poolToken", "userToken", "lifinityAuthority", 4_500_000_000)
+	tx := f.tx(LIFINITY_SWAP_V2, "lifinityAuthority", "amm", "trader", "userSol", "userToken", "poolSol", "poolToken",
+		"poolLp", "poolFee", TOKEN_PROGRAM, "oracleMain", "oracleSub", "oraclePc")
+
+	checkSwap(t, handleFixture(t, tx), types.SwapLog{
+		Wallet: "trader", Pair: "amm", Source: "LIFINITY_SWAP_V2", Action: "BUY", AmountOut: 2, AmountIn: 4500,
+	})
+}
+
+func TestMeteoraPoolsSwap(t *testing.T) {
+	vault := func(f *txFixture, accounts ...string) {
+		f.inner = append(f.inner, types.Instruction{ProgramIdIndex: f.index(METEORA_VAULT_PROGRAM), Accounts: f.indexes(accounts...), Data: base58.Encode([]byte{1})})
+	}
+
+	f := &txFixture{}
+	f.tokenAccount("userToken", "trader", tokenMint, 6)
+	f.tokenAccount("userSol", "trader", wsolMint, 9)
+	f.tokenAccount("tokenVault", "aVault", tokenMint, 6)
+	f.tokenAccount("solVault", "bVault", wsolMint, 9)
+	f.tokenAccount("aVaultLp", "pool", "aVaultLpMint", 6)
+	f.tokenAccount("bVaultLp", "pool", "bVaultLpMint", 9)
+	f.tokenAccount("protocolFee", "feeOwner", tokenMint, 6)
+	// The input goes into the token vault for vault LP, and the output comes
+	// out of the SOL vault for burnt vault LP.
+	vault(f, "aVault", "tokenVault", "aVaultLpMint", "userToken", "aVaultLp", "trader", TOKEN_PROGRAM)
+	f.transfer("userToken", "tokenVault", "trader", 995_000_000)
+	f.mintTo("aVaultLpMint", "aVaultLp", "aVault", 990_000_000)
+	vault(f, "bVault", "solVault", "bVaultLpMint", "userSol", "bVaultLp", "pool", TOKEN_PROGRAM)
+	f.token(8, 480_000_000, "bVaultLp", "bVaultLpMint", "pool")
+	f.transfer("solVault", "userSol", "bVault", 500_000_000)
+	f.transfer("userToken", "protocolFee", "trader", 5_000_000)
+	tx := f.tx(METEORA_POOLS_PROGRAM, "pool", "userToken", "userSol", "aVault", "bVault", "tokenVault", "solVault",
+		"aVaultLpMint", "bVaultLpMint", "aVaultLp", "bVaultLp", "protocolFee", "trader", METEORA_VAULT_PROGRAM, TOKEN_PROGRAM)
+
+	checkSwap(t, handleFixture(t, tx), types.SwapLog{
+		Wallet: "trader", Pair: "pool", Source: "METEORA_POOLS_PROGRAM", Action: "SELL", AmountOut: 1000, AmountIn: 0.5,
+	})
+
+	// Adding liquidity moves both tokens into the vaults. It passes the user,
+	// not the vault program, as account 13.
+	f = &txFixture{}
+	f.tokenAccount("userToken", "provider", tokenMint, 6)
+	f.tokenAccount("userSol", "provider", wsolMint, 9)
+	f.tokenAccount("userPoolLp", "provider", "poolLpMint", 6)
+	f.tokenAccount("tokenVault", "aVault", tokenMint, 6)
+	f.tokenAccount("solVault", "bVault", wsolMint, 9)
+	f.tokenAccount("aVaultLp", "pool", "aVaultLpMint", 6)
+	f.tokenAccount("bVaultLp", "pool", "bVaultLpMint", 9)
+	vault(f, "aVault", "tokenVault", "aVaultLpMint", "userToken", "aVaultLp", "provider", TOKEN_PROGRAM)
+	f.transfer("userToken", "tokenVault", "provider", 1_000_000_000)
+	f.mintTo("aVaultLpMint", "aVaultLp", "aVault", 990_000_000)
+	vault(f, "bVault", "solVault", "bVaultLpMint", "userSol", "bVaultLp", "provider", TOKEN_PROGRAM)
+	f.transfer("userSol", "solVault", "provider", 500_000_000)
+	f.mintTo("bVaultLpMint", "bVaultLp", "bVault", 480_000_000)
+	f.mintTo("poolLpMint", "userPoolLp", "pool", 700_000_000)
+	tx = f.tx(METEORA_POOLS_PROGRAM, "pool", "poolLpMint", "userPoolLp", "aVaultLp", "bVaultLp", "aVault", "bVault",
+		"aVaultLpMint", "bVaultLpMint", "tokenVault", "solVault", "userToken", "userSol", "provider", METEORA_VAULT_PROGRAM, TOKEN_PROGRAM)
+	if accounts := tx.Transaction.Message.Instructions[0].Accounts; validateDexInstruction(METEORA_POOLS_PROGRAM, accounts, tx.Transaction.Message.AccountKeys) {
+		t.Fatal("add liquidity taken for a swap")
+	}
+	for _, swap := range handleFixture(t, tx) {
+		if swap.Action == "BUY" || swap.Action == "SELL" {
+			t.Fatalf("add liquidity decoded as %+v", swap)
+		}
+	}
+}
+
+func TestPhoenixSwap(t *testing.T) {
+	f := &txFixture{}
+	f.tokenAccount("traderBase", "trader", tokenMint, 6)
+	f.tokenAccount("traderQuote", "trader", wsolMint, 9)
+	f.tokenAccount("baseVault", "market", tokenMint, 6)
+	f.tokenAccount("quoteVault", "market", wsolMint, 9)
+	// The fills are logged through a self-CPI around the transfers.
+	logIx := types.Instruction{ProgramIdIndex: f.index(PHOENIX), Accounts: f.indexes("logAuthority"), Data: base58.Encode([]byte{15})}
+	f.inner = append(f.inner, logIx)
+	f.transfer("traderBase", "baseVault", "trader", 1_000_000_000)
+	f.transfer("quoteVault", "traderQuote", "market", 500_000_000)
+	f.inner = append(f.inner, logIx)
+	tx := f.tx(PHOENIX, PHOENIX, "logAuthority", "market", "trader", "traderBase", "traderQuote", "baseVault", "quoteVault", TOKEN_PROGRAM)
+	tx.Transaction.Message.Instructions[0].Data = base58.Encode([]byte{0})
+
+	checkSwap(t, handleFixture(t, tx), types.SwapLog{
+		Wallet: "trader", Pair: "market", Source: "PHOENIX", Action: "SELL", AmountOut: 1000, AmountIn: 0.5,
+	})
+}
+
+func TestPhoenixWithdrawFunds(t *testing.T) {
+	f := &txFixture{}
+	f.tokenAccount("traderBase", "trader", tokenMint, 6)
+	f.tokenAccount("traderQuote", "trader", wsolMint, 9)
+	f.tokenAccount("baseVault", "market", tokenMint, 6)
+	f.tokenAccount("quoteVault", "market", wsolMint, 9)
+	// Free funds of both sides go back to the trader.
+	f.transfer("baseVault", "traderBase", "market", 1_000_000_000)
+	f.transfer("quoteVault", "traderQuote", "market", 500_000_000)
+	tx := f.tx(PHOENIX, PHOENIX, "logAuthority", "market", "trader", "traderBase", "traderQuote", "baseVault", "quoteVault", TOKEN_PROGRAM)
+	tx.Transaction.Message.Instructions[0].Data = base58.Encode([]byte{12})
+
+	for _, swap := range handleFixture(t, tx) {
+		if swap.Action == "BUY" || swap.Action == "SELL" {
+			t.Fatalf("withdrawal decoded as %+v", swap)
+		}
+	}
+}
+
+func TestFluxbeamSwap(t *testing.T) {
+	f := &txFixture{}
+	f.tokenAccount("userSol", "trader", wsolMint, 9)
+	f.tokenAccount("userToken", "trader", tokenMint, 6)
+	f.tokenAccount("poolSol", "swapAuthority", wsolMint, 9)
+	f.tokenAccount("poolToken", "swapAuthority", tokenMint, 6)
+	f.tokenAccount("poolFee", "feeOwner", "poolLp", 6)
+	f.transfer("userSol", "poolSol", "trader", 250_000_000)
+	f.mintTo("poolLp", "poolFee", "swapAuthority", 3)
+	f.transfer("poolToken", "userToken", "swapAuthority", 80_000_000)
+	tx := f.tx(FLUXBEAM_PROGRAM, "pool", "swapAuthority", "trader", "userSol", "poolSol", "poolToken", "userToken",
+		"poolLp", "poolFee", wsolMint, tokenMint, TOKEN_PROGRAM, TOKEN_PROGRAM, TOKEN_PROGRAM)
+
+	checkSwap(t, handleFixture(t, tx), types.SwapLog{
+		Wallet: "trader", Pair: "pool", Source: "FLUXBEAM_PROGRAM", Action: "BUY", AmountOut: 0.25, AmountIn: 80,
+	})
+}
+
+func TestOrcaTokenSwap(t *testing.T) {
+	for _, program := range []string{ORCA_SWAP, ORCA_SWAP_V2} {
+		f := &txFixture{}
+		f.tokenAccount("userSol", "trader", wsolMint, 9)
+		f.tokenAccount("userToken", "trader", tokenMint, 6)
+		f.tokenAccount("poolSol", "swapAuthority", wsolMint, 9)
+		f.tokenAccount("poolToken", "swapAuthority", tokenMint, 6)
+		f.transfer("userToken", "poolToken", "trader", 12_000_000)
+		f.transfer("poolSol", "userSol", "swapAuthority", 30_000_000)
+		tx := f.tx(program, "pool", "swapAuthority", "trader", "userToken", "poolToken", "poolSol", "userSol",
+			"poolLp", "poolFee", TOKEN_PROGRAM)
+
+		checkSwap(t, handleFixture(t, tx), types.SwapLog{
+			Wallet: "trader", Pair: "pool", Source: Programs[program], Action: "SELL", AmountOut: 12, AmountIn: 0.03,
+		})
+
+		// A deposit passes the same accounts.
+		tx.Transaction.Message.Instructions[0].Data = base58.Encode([]byte{2})
+		for _, swap := range handleFixture(t, tx) {
+			if swap.Action == "BUY" || swap.Action == "SELL" {
+				t.Fatalf("%s deposit decoded as %+v", program, swap)
+			}
+		}
+	}
+}
+
+func TestPumpFunAmmSwap(t *testing.T) {
+	trader := common.PublicKeyFromBytes(bytes.Repeat([]byte{7}, 32))
+	creator := common.PublicKeyFromBytes(bytes.Repeat([]byte{9}, 32))
+
+	// A BuyEvent with the pool reserves from before the trade.
+	event := []byte{103, 244, 82, 31, 44, 245, 119, 119}
+	for _, v := range []uint64{1700000000, 5_000_000_000, 1_100_000_000, 0, 0, 100_000_000_000, 20_000_000_000,
+		1_000_000_000, 20, 2_000_000, 5, 500_000, 1_002_000_000, 1_003_000_000} {
+		event = binary.LittleEndian.AppendUint64(event, v)
+	}
+	for i := 0; i < 6; i++ {
+		event = append(event, trader.Bytes()...)
+	}
+	event = append(event, creator.Bytes()...)
+	event = binary.LittleEndian.AppendUint64(event, 5)
+	event = binary.LittleEndian.AppendUint64(event, 500_000)
+
+	fixture := func() *txFixture {
+		f := &txFixture{}
+		f.tokenAccount("userToken", trader.ToBase58(), tokenMint, 6)
+		f.tokenAccount("userSol", trader.ToBase58(), wsolMint, 9)
+		f.tokenAccount("poolToken", "pool", tokenMint, 6)
+		f.tokenAccount("poolSol", "pool", wsolMint, 9)
+		f.tokenAccount("protocolFeeSol", "protocolFee", wsolMint, 9)
+		f.tokenAccount("creatorVault", "creatorVaultAuthority", wsolMint, 9)
+		// The fee transfers come between the trade's own.
+		f.transfer("userSol", "poolSol", trader.ToBase58(), 1_002_000_000)
+		f.transfer("userSol", "protocolFeeSol", trader.ToBase58(), 500_000)
+		f.transfer("poolToken", "userToken", "pool", 5_000_000_000)
+		f.transfer("userSol", "creatorVault", trader.ToBase58(), 500_000)
+		return f
+	}
+	build := func(f *txFixture) *types.SolanaTx {
+		tx := f.tx(PUMPFUN_AMM, "pool", trader.ToBase58(), "globalConfig", tokenMint, wsolMint, "userToken", "userSol",
+			"poolToken", "poolSol", "protocolFee", "protocolFeeSol", TOKEN_PROGRAM, TOKEN_PROGRAM, "system", "ata",
+			"eventAuthority", PUMPFUN_AMM, "creatorVault", "creatorVaultAuthority")
+		buy := binary.LittleEndian.AppendUint64([]byte{102, 6, 61, 18, 1, 218, 235, 234}, 5_000_000_000)
+		buy = binary.LittleEndian.AppendUint64(buy, 1_100_000_000)
+		tx.Transaction.Message.Instructions[0].Data = base58.Encode(buy)
+		return tx
+	}
+	check := func(swaps []types.SwapLog) {
+		t.Helper()
+		checkSwap(t, swaps, types.SwapLog{
+			Wallet: trader.ToBase58(), Pair: "pool", Source: "PUMPFUN_AMM", Action: "BUY", AmountOut: 1.003, AmountIn: 5000,
+		})
+		s := swaps[0]
+		if s.Fee != 0.003 || s.BaseReserve == nil || *s.BaseReserve != "95000000000" || *s.QuoteReserve != "21002000000" {
+			t.Fatalf("fee %v, reserves %v %v", s.Fee, s.BaseReserve, s.QuoteReserve)
+		}
+		if s.Creator != creator.ToBase58() {
+			t.Fatalf("creator %s", s.Creator)
+		}
+	}
+
+	// The event emitted through a self-CPI.
+	f := fixture()
+	selfCPI := append([]byte{0xe4, 0x45, 0xa5, 0x2e, 0x51, 0xcb, 0x9a, 0x1d}, event...)
+	f.inner = append(f.inner, types.Instruction{ProgramIdIndex: f.index(PUMPFUN_AMM), Accounts: f.indexes("eventAuthority"), Data: base58.Encode(selfCPI)})
+	check(handleFixture(t, build(f)))
+
+	// The event only logged.
+	tx := build(fixture())
+	tx.Meta.LogMessages = []string{
+		"Program " + PUMPFUN_AMM + " invoke [1]",
+		"Program log: Instruction: Buy",
+		"Program data: " + base64.StdEncoding.EncodeToString(event),
+		"Program " + PUMPFUN_AMM + " success",
+	}
+	check(handleFixture(t, tx))
+}
+
+func TestIdentifyPair(t *testing.T) {
+	wsol := common.PublicKeyFromString(wsolMint)
+	token := common.PublicKeyFromString(tokenMint)
+
+	lifinity := make([]byte, 1024)
+	copy(lifinity[254:], token.Bytes())
+	copy(lifinity[286:], wsol.Bytes())
+
+	phoenix := make([]byte, 1024)
+	copy(phoenix[48:], token.Bytes())
+	copy(phoenix[128:], wsol.Bytes())
+
+	orca := make([]byte, 324)
+	copy(orca[131:], wsol.Bytes())
+	copy(orca[163:], token.Bytes())
+
+	for owner, data := range map[string][]byte{LIFINITY_SWAP_V2: lifinity, PHOENIX: phoenix, ORCA_SWAP: orca} {
+		_, quote, base, _, err := identifyPair(owner, client.AccountInfo{Data: data}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", owner, err)
+		}
+		if quote != wsolMint || base != tokenMint {
+			t.Fatalf("%s: quote %s, token %s", owner, quote, base)
+		}
+	}
+}
+
+// jupiterRouteFixture is a SOL -> USDC -> token route of the trader through a
+// Lifinity pool and an Orca token-swap pool, each hop followed by its
+// SwapEvent. With cpi set the route is invoked by another program.
+func jupiterRouteFixture(cpi bool) *types.SolanaTx {
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	amm := common.PublicKeyFromBytes(bytes.Repeat([]byte{3}, 32))
+
+	f := &txFixture{}
+	f.tokenAccount("userSol", "trader", wsolMint, 9)
+	f.tokenAccount("userUsdc", "trader", usdcMint, 6)
+	f.tokenAccount("userToken", "trader", tokenMint, 6)
+	f.tokenAccount("lifinitySol", "lifinityAuthority", wsolMint, 9)
+	f.tokenAccount("lifinityUsdc", "lifinityAuthority", usdcMint, 6)
+	f.tokenAccount("lifinityFee", "lifinityAuthority", "lifinityLp", 6)
+	f.tokenAccount("orcaUsdc", "swapAuthority", usdcMint, 6)
+	f.tokenAccount("orcaToken", "swapAuthority", tokenMint, 6)
+
+	swapEvent := func(inputMint string, inputAmount uint64, outputMint string, outputAmount uint64) types.Instruction {
+		data := []byte{0xe4, 0x45, 0xa5, 0x2e, 0x51, 0xcb, 0x9a, 0x1d, 64, 198, 205, 232, 38, 8, 113, 226}
+		data = append(data, amm.Bytes()...)
+		data = append(data, common.PublicKeyFromString(inputMint).Bytes()...)
+		data = binary.LittleEndian.AppendUint64(data, inputAmount)
+		data = append(data, common.PublicKeyFromString(outputMint).Bytes()...)
+		data = binary.LittleEndian.AppendUint64(data, outputAmount)
+		return types.Instruction{ProgramIdIndex: f.index(JUPITER_V6_AGGREGATOR), Accounts: f.indexes("jupiterEventAuthority"), Data: base58.Encode(data)}
+	}
+	pool := func(program string, accounts ...string) types.Instruction {
+		return types.Instruction{ProgramIdIndex: f.index(program), Accounts: f.indexes(accounts...), Data: base58.Encode([]byte{1})}
+	}
+
+	route := []string{TOKEN_PROGRAM, "trader", "userSol", "userToken", "userToken", tokenMint, JUPITER_V6_AGGREGATOR,
+		"jupiterEventAuthority", JUPITER_V6_AGGREGATOR, LIFINITY_SWAP_V2, ORCA_SWAP}
+	routeData := base58.Encode([]byte{229, 23, 203, 151, 122, 227, 173, 42})
+	if cpi {
+		f.inner = append(f.inner, types.Instruction{ProgramIdIndex: f.index(JUPITER_V6_AGGREGATOR), Accounts: f.indexes(route...), Data: routeData})
+	}
+
+	f.inner = append(f.inner, pool(LIFINITY_SWAP_V2, "lifinityAuthority", "lifinityAmm", "trader", "userSol", "userUsdc", "lifinitySol",
+		"lifinityUsdc", "lifinityLp", "lifinityFee", TOKEN_PROGRAM, "oracleMain", "oracleSub", "oraclePc"))
+	f.transfer("userSol", "lifinitySol", "trader", 1_000_000_000)
+	f.mintTo("lifinityLp", "lifinityFee", "lifinityAuthority", 15)
+	f.transfer("lifinityUsdc", "userUsdc", "lifinityAuthority", 150_000_000)
+	f.inner = append(f.inner, swapEvent(wsolMint, 1_000_000_000, usdcMint, 150_000_000))
+
+	f.inner = append(f.inner, pool(ORCA_SWAP, "orcaPool", "swapAuthority", "trader", "userUsdc", "orcaUsdc", "orcaToken", "userToken",
+		"orcaLp", "orcaFee", TOKEN_PROGRAM))
+	f.transfer("userUsdc", "orcaUsdc", "trader", 150_000_000)
+	f.transfer("orcaToken", "userToken", "swapAuthority", 3_000_000_000)
+	f.inner = append(f.inner, swapEvent(usdcMint, 150_000_000, tokenMint, 3_000_000_000))
+
+	if cpi {
+		return f.tx("tradingBot", "trader")
+	}
+	tx := f.tx(JUPITER_V6_AGGREGATOR, route...)
+	tx.Transaction.Message.Instructions[0].Data = routeData
+	return tx
+}
+
+func TestJupiterRoute(t *testing.T) {
+	for _, cpi := range []bool{false, true} {
+		swaps := handleFixture(t, jupiterRouteFixture(cpi))
+
+		// The trade of the route, then its legs in route order.
+		checkSwap(t, swaps[:1], types.SwapLog{
+			Wallet: "trader", Source: "JUPITER_V6_AGGREGATOR", Action: "BUY", AmountOut: 1, AmountIn: 3000,
+		})
+		if swaps[0].Hop != 0 {
+			t.Fatalf("cpi %v: route trade at hop %d", cpi, swaps[0].Hop)
+		}
+		legs := swaps[1:]
+		if len(legs) != 2 {
+			t.Fatalf("cpi %v: got %d legs: %+v", cpi, len(legs), legs)
+		}
+		for i, want := range []types.SwapLog{
+			{Pair: "lifinityAmm", Source: "LIFINITY_SWAP_V2", AmountOut: 1, AmountIn: 150},
+			{Pair: "orcaPool", Source: "ORCA_SWAP", AmountOut: 150, AmountIn: 3000},
+		} {
+			leg := legs[i]
+			if leg.Hop != i+1 || leg.Wallet != "trader" || leg.Pair != want.Pair || leg.Source != want.Source ||
+				leg.AmountOut != want.AmountOut || leg.AmountIn != want.AmountIn {
+				t.Fatalf("cpi %v: leg %d %+v, want %+v", cpi, i, leg, want)
+			}
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"blocsy/internal/types"
 	"github.com/blocto/solana-go-sdk/common"
 	"github.com/mr-tron/base58"
-	"log"
 )
 
 func determineSystemInstructionType(tag byte) string {
@@ -23,42 +22,39 @@ func determineSystemInstructionType(tag byte) string {
 
 }
 
-func DecodeSystemProgramData(encodedData string) types.SystemProgramData {
+func DecodeSystemProgramData(encodedData string) (types.SystemProgramData, error) {
+	data := types.SystemProgramData{}
+
 	decodedBytes, err := base58.Decode(encodedData)
 	if err != nil {
-		log.Fatalf("Error decoding Base58: %v", err)
+		return data, types.NewDecodeError(SYSTEM_PROGRAM, types.ErrBadEncoding, "%v", err)
+	}
+	if len(decodedBytes) < 4 {
+		return data, types.NewDecodeError(SYSTEM_PROGRAM, types.ErrShortData, "%d bytes", len(decodedBytes))
 	}
 
-	data := types.SystemProgramData{}
 	data.RawType = decodedBytes[0]
 	data.Type = determineSystemInstructionType(decodedBytes[0])
 
 	switch data.RawType {
 	case 0:
 		if len(decodedBytes) < 52 {
-			return data
+			return data, types.NewDecodeError(SYSTEM_PROGRAM, types.ErrShortData, "%s needs 52 bytes, got %d", data.Type, len(decodedBytes))
 		}
-		lamports, _ := unpackU64(decodedBytes[4:12])
-		data.Lamports = lamports
-
-		space, _ := unpackU64(decodedBytes[12:20])
-		data.Space = space
-
+		data.Lamports, _, _ = unpackU64(decodedBytes[4:12])
+		data.Space, _, _ = unpackU64(decodedBytes[12:20])
 		data.ProgramID = common.PublicKeyFromBytes(decodedBytes[20:52])
 	case 2, 8:
 		if len(decodedBytes) < 12 {
-			return data
+			return data, types.NewDecodeError(SYSTEM_PROGRAM, types.ErrShortData, "%s needs 12 bytes, got %d", data.Type, len(decodedBytes))
 		}
-
-		lamports, _ := unpackU64(decodedBytes[4:])
-		data.Lamports = lamports
+		data.Lamports, _, _ = unpackU64(decodedBytes[4:])
 	case 1:
 		if len(decodedBytes) < 36 {
-			return data
+			return data, types.NewDecodeError(SYSTEM_PROGRAM, types.ErrShortData, "%s needs 36 bytes, got %d", data.Type, len(decodedBytes))
 		}
 		data.ProgramID = common.PublicKeyFromBytes(decodedBytes[4:])
-
 	}
 
-	return data
+	return data, nil
 }
@@ -0,0 +1,172 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"github.com/blocto/solana-go-sdk/common"
+	"strconv"
+	"time"
+)
+
+// ParseTokenEvents collects the token program instructions that change
+// authorities, freeze or close accounts, or delegate balances, in
+// instruction order.
+func ParseTokenEvents(tx *types.SolanaTx, block uint64, timestamp int64) []types.TokenEvent {
+	accountKeys := getAllAccountKeys(tx)
+	events := make([]types.TokenEvent, 0)
+
+	var signature string
+	if len(tx.Transaction.Signatures) > 0 {
+		signature = tx.Transaction.Signatures[0]
+	}
+
+	for instructionIndex, instruction := range tx.Transaction.Message.Instructions {
+		if event, ok := decodeTokenEvent(instruction, accountKeys, tx); ok {
+			event.IxIndex = instructionIndex
+			event.InnerIndex = -1
+			events = append(events, event)
+		}
+
+		for _, inner := range tx.Meta.InnerInstructions {
+			if inner.Index != instructionIndex {
+				continue
+			}
+			for ixIndex, innerInstruction := range inner.Instructions {
+				if event, ok := decodeTokenEvent(innerInstruction, accountKeys, tx); ok {
+					event.IxIndex = instructionIndex
+					event.InnerIndex = ixIndex
+					events = append(events, event)
+				}
+			}
+		}
+	}
+
+	for i := range events {
+		events[i].Signature = signature
+		events[i].Slot = block
+		events[i].Timestamp = time.Unix(timestamp, 0).UTC()
+	}
+
+	return events
+}
+
+func decodeTokenEvent(ix types.Instruction, accountKeys []string, tx *types.SolanaTx) (types.TokenEvent, bool) {
+	if ix.ProgramIdIndex >= len(accountKeys) || !isTokenProgram(accountKeys[ix.ProgramIdIndex]) {
+		return types.TokenEvent{}, false
+	}
+	program := accountKeys[ix.ProgramIdIndex]
+	if checkAccounts(program, ix.Accounts, 0, accountKeys) != nil {
+		return types.TokenEvent{}, false
+	}
+	account := func(i int) string {
+		return accountKeys[ix.Accounts[i]]
+	}
+
+	data, err := DecodeTokenProgramData(program, ix.Data)
+	if err != nil {
+		return types.TokenEvent{}, false
+	}
+	event := types.TokenEvent{Program: program}
+
+	switch data.Type {
+	case "SetAuthority":
+		// [account or mint, current authority]
+		if len(ix.Accounts) < 2 {
+			return types.TokenEvent{}, false
+		}
+		event.Type = types.TokenEventSetAuthority
+		event.Account = account(0)
+		event.Authority = account(1)
+		event.AuthorityType = authorityTypeName(data.AuthorityType)
+		if data.NewAuthority != (common.PublicKey{}) {
+			event.NewAuthority = data.NewAuthority.String()
+		}
+		// Only the token account authorities act on a token account.
+		if event.AuthorityType == "accountOwner" || event.AuthorityType == "closeAccount" {
+			event.Mint = tokenAccountMint(tx, event.Account)
+		} else {
+			event.Mint = event.Account
+		}
+
+	case "FreezeAccount", "ThawAccount":
+		// [account, mint, freeze authority]
+		if len(ix.Accounts) < 3 {
+			return types.TokenEvent{}, false
+		}
+		event.Type = types.TokenEventFreeze
+		if data.Type == "ThawAccount" {
+			event.Type = types.TokenEventThaw
+		}
+		event.Account = account(0)
+		event.Mint = account(1)
+		event.Authority = account(2)
+
+	case "CloseAccount":
+		// [account, destination, owner]
+		if len(ix.Accounts) < 3 {
+			return types.TokenEvent{}, false
+		}
+		event.Type = types.TokenEventClose
+		event.Account = account(0)
+		event.Destination = account(1)
+		event.Authority = account(2)
+		event.Mint = tokenAccountMint(tx, event.Account)
+
+	case "Approve":
+		// [source, delegate, owner]
+		if len(ix.Accounts) < 3 {
+			return types.TokenEvent{}, false
+		}
+		event.Type = types.TokenEventApprove
+		event.Account = account(0)
+		event.Delegate = account(1)
+		event.Authority = account(2)
+		event.Mint = tokenAccountMint(tx, event.Account)
+		event.Amount = strconv.FormatUint(data.Amount, 10)
+
+	case "ApproveChecked":
+		// [source, mint, delegate, owner]
+		if len(ix.Accounts) < 4 {
+			return types.TokenEvent{}, false
+		}
+		event.Type = types.TokenEventApprove
+		event.Account = account(0)
+		event.Mint = account(1)
+		event.Delegate = account(2)
+		event.Authority = account(3)
+		event.Amount = strconv.FormatUint(data.Amount, 10)
+
+	case "Revoke":
+		// [source, owner]
+		if len(ix.Accounts) < 2 {
+			return types.TokenEvent{}, false
+		}
+		event.Type = types.TokenEventRevoke
+		event.Account = account(0)
+		event.Authority = account(1)
+		event.Mint = tokenAccountMint(tx, event.Account)
+
+	default:
+		return types.TokenEvent{}, false
+	}
+
+	return event, true
+}
+
+// tokenAccountMint finds the mint of a token account from the balances, which
+// include accounts closed by the transaction, or from the instructions that
+// created it.
+func tokenAccountMint(tx *types.SolanaTx, tokenAccount string) string {
+	accountKeys := getAllAccountKeys(tx)
+	for _, balances := range [][]types.TokenBalance{tx.Meta.PreTokenBalances, tx.Meta.PostTokenBalances} {
+		for _, balance := range balances {
+			if balance.AccountIndex < len(accountKeys) && accountKeys[balance.AccountIndex] == tokenAccount {
+				return balance.Mint
+			}
+		}
+	}
+
+	if details, found := findUserAccount(tokenAccount, tx); found {
+		return details.MintAddress
+	}
+	return ""
+}
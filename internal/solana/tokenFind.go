@@ -109,6 +109,12 @@ func (tf *TokenFinder) AddToQueue(token string) {
 		return
 	}
 
+	tf.processor.mu.RLock()
+	defer tf.processor.mu.RUnlock()
+	if tf.processor.closed {
+		return
+	}
+
 	if _, exists := tf.processor.seen.LoadOrStore(token, struct{}{}); exists {
 		return
 	}
@@ -120,3 +126,23 @@ func (tf *TokenFinder) AddToQueue(token string) {
 		log.Printf("Queue is full! Token %s discarded.", token)
 	}
 }
+
+// StopTokenProcessor stops taking tokens and waits for the queued ones to be
+// looked up. It returns how many were still queued when ctx ended.
+func (tf *TokenFinder) StopTokenProcessor(ctx context.Context) int {
+	if tf.processor == nil {
+		return 0
+	}
+
+	tf.processor.mu.Lock()
+	if !tf.processor.closed {
+		tf.processor.closed = true
+		close(tf.processor.queue)
+	}
+	tf.processor.mu.Unlock()
+
+	if waitGroup(ctx, &tf.processor.wg) {
+		return 0
+	}
+	return len(tf.processor.queue)
+}
@@ -2,10 +2,10 @@ package solana
 
 import (
 	"blocsy/internal/types"
+	"bytes"
 	"encoding/binary"
 	"github.com/blocto/solana-go-sdk/common"
 	"github.com/mr-tron/base58"
-	"log"
 )
 
 func determineTokenInstructionType(tag byte) string {
@@ -60,25 +60,138 @@ func determineTokenInstructionType(tag byte) string {
 		return "AmountToUiAmount"
 	case 24:
 		return "UiAmountToAmount"
+
+	// Token-2022 only
+	case 25:
+		return "InitializeMintCloseAuthority"
+	case 26:
+		return "TransferFeeExtension"
+	case 27:
+		return "ConfidentialTransferExtension"
+	case 28:
+		return "DefaultAccountStateExtension"
+	case 29:
+		return "Reallocate"
+	case 30:
+		return "MemoTransferExtension"
+	case 31:
+		return "CreateNativeMint"
+	case 32:
+		return "InitializeNonTransferableMint"
+	case 33:
+		return "InterestBearingMintExtension"
+	case 34:
+		return "CpiGuardExtension"
+	case 35:
+		return "InitializePermanentDelegate"
+	case 36:
+		return "TransferHookExtension"
+	case 37:
+		return "ConfidentialTransferFeeExtension"
+	case 38:
+		return "WithdrawExcessLamports"
+	case 39:
+		return "MetadataPointerExtension"
+	case 40:
+		return "GroupPointerExtension"
+	case 41:
+		return "GroupMemberPointerExtension"
+	case 42:
+		return "ConfidentialMintBurnExtension"
+	case 43:
+		return "ScaledUiAmountExtension"
+	case 44:
+		return "PausableExtension"
 	default:
 		return "InvalidInstruction"
 	}
 }
 
-func unpackU64(data []byte) (uint64, []byte) {
+// Token-2022 extension instructions carry a sub-instruction byte after the
+// tag. These initialize an extension on the mint (accounts[0]) when the
+// sub-instruction is 0; the ones without a sub-instruction always do.
+var mintExtensionInstructions = map[byte]string{
+	25: "mintCloseAuthority",
+	26: "transferFeeConfig",
+	27: "confidentialTransferMint",
+	28: "defaultAccountState",
+	32: "nonTransferable",
+	33: "interestBearingConfig",
+	35: "permanentDelegate",
+	36: "transferHook",
+	37: "confidentialTransferFeeConfig",
+	39: "metadataPointer",
+	40: "groupPointer",
+	41: "groupMemberPointer",
+	42: "confidentialMintBurn",
+	43: "scaledUiAmountConfig",
+	44: "pausableConfig",
+}
+
+// extensionsWithoutSubInstruction are initialized by the tag alone.
+var extensionsWithoutSubInstruction = map[byte]bool{25: true, 32: true, 35: true}
+
+// tokenMetadataInitialize is the spl-token-metadata-interface discriminator
+// Token-2022 accepts to store metadata on the mint itself.
+var tokenMetadataInitialize = []byte{0xd2, 0xe1, 0x1e, 0xa2, 0x58, 0xb8, 0x4d, 0x8d}
+
+// transferFeeTransferCheckedWithFee is the TransferFeeExtension
+// sub-instruction that moves tokens and withholds a fee.
+const transferFeeTransferCheckedWithFee = 1
+
+// authorityTypes names SetAuthority's authority type; 4 and up are Token-2022.
+var authorityTypes = []string{
+	"mintTokens",
+	"freezeAccount",
+	"accountOwner",
+	"closeAccount",
+	"transferFeeConfig",
+	"withheldWithdraw",
+	"closeMint",
+	"interestRate",
+	"permanentDelegate",
+	"confidentialTransferMint",
+	"transferHookProgramId",
+	"confidentialTransferFeeConfig",
+	"metadataPointer",
+	"groupPointer",
+	"groupMemberPointer",
+	"scaledUiAmount",
+	"pause",
+}
+
+func authorityTypeName(authorityType byte) string {
+	if int(authorityType) < len(authorityTypes) {
+		return authorityTypes[authorityType]
+	}
+	return "unknown"
+}
+
+func unpackU64(data []byte) (uint64, []byte, error) {
 	if len(data) < 8 {
-		log.Fatalf("Insufficient data to decode u64")
+		return 0, data, types.ErrShortData
 	}
 	value := binary.LittleEndian.Uint64(data[:8])
-	return value, data[8:]
+	return value, data[8:], nil
 }
 
-func DecodeTokenProgramData(encodedData string) types.TokenProgramData {
+// DecodeTokenProgramData decodes an instruction of programId, the SPL token
+// program or Token-2022.
+func DecodeTokenProgramData(programId string, encodedData string) (types.TokenProgramData, error) {
 	data := types.TokenProgramData{}
 
 	decodedBytes, err := base58.Decode(encodedData)
 	if err != nil {
-		return data
+		return data, types.NewDecodeError(programId, types.ErrBadEncoding, "%v", err)
+	}
+	if len(decodedBytes) == 0 {
+		return data, types.NewDecodeError(programId, types.ErrShortData, "empty data")
+	}
+
+	if bytes.HasPrefix(decodedBytes, tokenMetadataInitialize) {
+		data.Type = "InitializeTokenMetadata"
+		data.Extension = "tokenMetadata"
+		return data, nil
 	}
 
 	// Extract instruction tag
@@ -87,61 +200,81 @@ func DecodeTokenProgramData(encodedData string) types.TokenProgramData {
 
 	data.RawType = instructionTag
 	data.Type = determineTokenInstructionType(instructionTag)
+	if data.Type == "InvalidInstruction" {
+		return data, types.NewDecodeError(programId, types.ErrUnknownInstruction, "tag %d", instructionTag)
+	}
+
+	short := func(need int) error {
+		return types.NewDecodeError(programId, types.ErrShortData, "%s needs %d bytes, got %d", data.Type, need+1, len(decodedBytes))
+	}
 
 	// Decode based on instruction tag
 	switch instructionTag {
 	case 3, 4, 7, 8: // Transfer, Approve, MintTo, Burn
-		amount, _ := unpackU64(remainingBytes)
+		amount, _, err := unpackU64(remainingBytes)
+		if err != nil {
+			return data, short(8)
+		}
 		data.Amount = amount
 
 	case 6: // SetAuthority
-		if len(remainingBytes) < 1 {
-			return data
+		if len(remainingBytes) < 2 {
+			return data, short(2)
 		}
-		authorityType := remainingBytes[0]
+		data.AuthorityType = remainingBytes[0]
 		remainingBytes = remainingBytes[1:]
-		data.AuthorityType = authorityType
 
-		if len(remainingBytes) >= 32 {
-			data.NewAuthority = common.PublicKeyFromBytes(remainingBytes[:32])
+		// COption<Pubkey>: none revokes the authority.
+		if remainingBytes[0] == 1 {
+			if len(remainingBytes) < 33 {
+				return data, short(34)
+			}
+			data.NewAuthority = common.PublicKeyFromBytes(remainingBytes[1:33])
 		}
 
 	case 12, 13, 14, 15: // TransferChecked,ApproveChecked,MintToChecked,BurnChecked
-		amount, rest := unpackU64(remainingBytes)
-		if len(rest) < 1 {
-			return data
+		if len(remainingBytes) < 9 {
+			return data, short(9)
 		}
-		decimals := rest[0]
-		data.Decimals = int(decimals)
-		data.Amount = amount
+		data.Amount, remainingBytes, _ = unpackU64(remainingBytes)
+		data.Decimals = int(remainingBytes[0])
 
 	case 16, 18: // InitializeAccount2,InitializeAccount3
 		if len(remainingBytes) < 32 {
-			return data
+			return data, short(32)
 		}
 		data.Owner = common.PublicKeyFromBytes(remainingBytes[:32])
 
 	case 20, 0: // InitializeMint2,InitializeMint
-		if len(remainingBytes) < 1 {
-			return data
+		if len(remainingBytes) < 33 {
+			return data, short(33)
 		}
-		decimals := remainingBytes[0]
-		remainingBytes = remainingBytes[1:]
-		data.Decimals = int(decimals)
+		data.Decimals = int(remainingBytes[0])
+		data.MintAuthority = common.PublicKeyFromBytes(remainingBytes[1:33])
 
-		if len(remainingBytes) < 32 {
-			return data
+		remainingBytes = remainingBytes[33:]
+		if len(remainingBytes) >= 33 && remainingBytes[0] == 1 {
+			data.FreezeAuthority = common.PublicKeyFromBytes(remainingBytes[1:33])
 		}
-		data.MintAuthority = common.PublicKeyFromBytes(remainingBytes[:32])
-
-		remainingBytes = remainingBytes[32:]
-
-		if len(remainingBytes) >= 32 {
-			data.FreezeAuthority = common.PublicKeyFromBytes(remainingBytes[:32])
 
+	case 26: // TransferFeeExtension
+		if len(remainingBytes) > 0 && remainingBytes[0] == transferFeeTransferCheckedWithFee {
+			if len(remainingBytes) < 18 {
+				return data, short(18)
+			}
+			data.Type = "TransferCheckedWithFee"
+			data.Amount, _, _ = unpackU64(remainingBytes[1:])
+			data.Decimals = int(remainingBytes[9])
+			data.Fee, _, _ = unpackU64(remainingBytes[10:])
+			return data, nil
 		}
+	}
 
+	if extension, ok := mintExtensionInstructions[instructionTag]; ok {
+		if extensionsWithoutSubInstruction[instructionTag] || (len(remainingBytes) > 0 && remainingBytes[0] == 0) {
+			data.Extension = extension
+		}
 	}
 
-	return data
+	return data, nil
 }
@@ -0,0 +1,102 @@
+package solana
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	TransportRabbitMQ = "rabbitmq"
+	TransportMemory   = "memory"
+	TransportFile     = "file"
+)
+
+const (
+	deadLetterSuffix = ".dlq"
+
+	HeaderFailureReason = "x-failure-reason"
+	HeaderFailedAt      = "x-failed-at"
+	HeaderDeadLetters   = "x-dead-letters"
+)
+
+type Message struct {
+	Body            []byte
+	ContentType     string
+	ContentEncoding string
+	Headers         map[string]interface{}
+}
+
+// Delivery is a consumed Message that must be acked or nacked exactly once.
+type Delivery struct {
+	Message
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+func (d Delivery) Ack() error {
+	if d.ack == nil {
+		return nil
+	}
+	return d.ack()
+}
+
+func (d Delivery) Nack(requeue bool) error {
+	if d.nack == nil {
+		return nil
+	}
+	return d.nack(requeue)
+}
+
+// NewTransport picks the transport named by QUEUE_TRANSPORT, defaulting to
+// RabbitMQ.
+func NewTransport() (Transport, error) {
+	return NewTransportFor(queueName)
+}
+
+// NewTransportFor is NewTransport for a queue other than the block queue.
+func NewTransportFor(queue string) (Transport, error) {
+	kind := strings.ToLower(os.Getenv("QUEUE_TRANSPORT"))
+
+	switch kind {
+	case "", TransportRabbitMQ:
+		return NewRabbitTransport(queue), nil
+	case TransportMemory:
+		return NewMemoryTransport(queue), nil
+	case TransportFile:
+		return NewSegmentTransport(os.Getenv("QUEUE_SEGMENT_DIR"), queue)
+	default:
+		return nil, fmt.Errorf("unknown queue transport %q", kind)
+	}
+}
+
+// deadLetterMessage copies msg with the failure reason, time and the number of
+// times it has been dead-lettered recorded in its headers.
+func deadLetterMessage(msg Message, reason string) Message {
+	headers := make(map[string]interface{}, len(msg.Headers)+3)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderFailureReason] = reason
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[HeaderDeadLetters] = int32(HeaderInt(msg.Headers, HeaderDeadLetters) + 1)
+
+	return Message{Body: msg.Body, ContentType: msg.ContentType, ContentEncoding: msg.ContentEncoding, Headers: headers}
+}
+
+// HeaderInt reads a numeric header regardless of how the transport decoded it.
+func HeaderInt(headers map[string]interface{}, key string) int {
+	switch v := headers[key].(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
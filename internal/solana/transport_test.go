@@ -0,0 +1,231 @@
+package solana
+
+import (
+	"context"
+	"github.com/streadway/amqp"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, deliveries <-chan Delivery) Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			t.Fatal("deliveries closed")
+		}
+		return d
+	case <-time.After(2 * time.Second):
+		t.Fatal("no delivery")
+	}
+	return Delivery{}
+}
+
+func TestMemoryTransportRoundTrip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	producer := NewMemoryTransport(t.Name())
+	consumer := NewMemoryTransport(t.Name())
+	defer consumer.Close()
+
+	for _, body := range []string{"a", "b"} {
+		if err := producer.Publish(Message{Body: []byte(body), ContentType: "application/json"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if depth, _ := consumer.Depth(); depth != 2 {
+		t.Fatalf("depth %d", depth)
+	}
+
+	deliveries, _ := consumer.Consume(ctx)
+	d := receive(t, deliveries)
+	if string(d.Body) != "a" || d.ContentType != "application/json" {
+		t.Fatalf("got %q %q", d.Body, d.ContentType)
+	}
+	if err := d.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	// The requeued message goes behind the rest.
+	if d = receive(t, deliveries); string(d.Body) != "b" {
+		t.Fatalf("got %q", d.Body)
+	}
+	if err := consumer.DeadLetter(d.Message, "bad block"); err != nil {
+		t.Fatal(err)
+	}
+	d.Ack()
+	if d = receive(t, deliveries); string(d.Body) != "a" {
+		t.Fatalf("requeued %q", d.Body)
+	}
+	d.Ack()
+
+	deadLetters, _ := consumer.ConsumeDeadLetters(ctx)
+	d = receive(t, deadLetters)
+	if string(d.Body) != "b" || d.Headers[HeaderFailureReason] != "bad block" || HeaderInt(d.Headers, HeaderDeadLetters) != 1 {
+		t.Fatalf("dead letter %q %v", d.Body, d.Headers)
+	}
+}
+
+func TestSegmentTransportResume(t *testing.T) {
+	dir := t.TempDir()
+
+	producer, err := NewSegmentTransport(dir, "q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"a", "b", "c"} {
+		if err = producer.Publish(Message{Body: []byte(body), Headers: map[string]interface{}{"slot": body}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	producer.Close()
+
+	consumer, _ := NewSegmentTransport(dir, "q")
+	ctx, cancel := context.WithCancel(context.Background())
+	deliveries, err := consumer.Consume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := receive(t, deliveries), receive(t, deliveries)
+	if string(a.Body) != "a" || string(b.Body) != "b" || b.Headers["slot"] != "b" {
+		t.Fatalf("got %q %q %v", a.Body, b.Body, b.Headers)
+	}
+	// Out of order, so the offset only moves once a is acked too.
+	b.Ack()
+	if pos, _ := consumer.loadOffset(); pos != (segmentPosition{}) {
+		t.Fatalf("committed %+v before a was acked", pos)
+	}
+	a.Ack()
+	c := receive(t, deliveries)
+	if string(c.Body) != "c" {
+		t.Fatalf("got %q", c.Body)
+	}
+	// c is left unacked when the consumer stops.
+	cancel()
+
+	// A new producer never appends to an existing segment.
+	producer, _ = NewSegmentTransport(dir, "q")
+	if err = producer.Publish(Message{Body: []byte("d")}); err != nil {
+		t.Fatal(err)
+	}
+	producer.Close()
+	if segments, _ := producer.segments(); len(segments) != 2 {
+		t.Fatalf("segments %v", segments)
+	}
+
+	consumer, _ = NewSegmentTransport(dir, "q")
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	deliveries, _ = consumer.Consume(ctx)
+	c, d := receive(t, deliveries), receive(t, deliveries)
+	if string(c.Body) != "c" || string(d.Body) != "d" {
+		t.Fatalf("resumed with %q %q", c.Body, d.Body)
+	}
+	c.Ack()
+	d.Ack()
+
+	// The consumed segment is removed.
+	if _, err = os.Stat(consumer.segmentPath(1)); !os.IsNotExist(err) {
+		t.Fatalf("first segment kept: %v", err)
+	}
+}
+
+// fakeChannel is a broker channel that never confirms a publish. Each
+// consumer it registers is handed to the test on consumers.
+type fakeChannel struct {
+	mu        sync.Mutex
+	closed    bool
+	consumers chan chan amqp.Delivery
+}
+
+func (c *fakeChannel) Publish(string, string, bool, bool, amqp.Publishing) error { return nil }
+func (c *fakeChannel) Consume(string, string, bool, bool, bool, bool, amqp.Table) (<-chan amqp.Delivery, error) {
+	msgs := make(chan amqp.Delivery)
+	c.consumers <- msgs
+	return msgs, nil
+}
+func (c *fakeChannel) Cancel(string, bool) error                                { return nil }
+func (c *fakeChannel) Qos(int, int, bool) error                                 { return nil }
+func (c *fakeChannel) QueueInspect(string) (amqp.Queue, error)                  { return amqp.Queue{}, nil }
+func (c *fakeChannel) QueueBind(string, string, string, bool, amqp.Table) error { return nil }
+func (c *fakeChannel) QueueDeclare(string, bool, bool, bool, bool, amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{}, nil
+}
+func (c *fakeChannel) ExchangeDeclare(string, string, bool, bool, bool, bool, amqp.Table) error {
+	return nil
+}
+func (c *fakeChannel) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+func (c *fakeChannel) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func TestRabbitTransportConsumerOutlivesPublisher(t *testing.T) {
+	consumers := make(chan chan amqp.Delivery, 2)
+	var channels []*fakeChannel
+	tr := &RabbitTransport{
+		queue:          "q",
+		confirmTimeout: 10 * time.Millisecond,
+		subs:           make(map[*rabbitSession]bool),
+		dial: func(confirm bool) (*rabbitSession, error) {
+			ch := &fakeChannel{consumers: consumers}
+			channels = append(channels, ch)
+			return &rabbitSession{ch: ch, confirms: make(chan amqp.Confirmation), isClosed: ch.isClosed}, nil
+		},
+	}
+	if err := tr.connect(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deliveries, err := tr.Consume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := <-consumers
+
+	// The dead letter is never confirmed, which drops the publishing session.
+	if err = tr.DeadLetter(Message{Body: []byte("a")}, "bad block"); err == nil {
+		t.Fatal("unconfirmed publish succeeded")
+	}
+	if !channels[0].isClosed() || channels[1].isClosed() {
+		t.Fatal("confirm timeout closed the consumer channel")
+	}
+	msgs <- amqp.Delivery{Body: []byte("b")}
+	if d := receive(t, deliveries); string(d.Body) != "b" {
+		t.Fatalf("got %q", d.Body)
+	}
+
+	// A consumer whose channel closes is registered again.
+	close(msgs)
+	select {
+	case msgs = <-consumers:
+	case <-time.After(2 * time.Second):
+		t.Fatal("consumer not re-registered")
+	}
+	msgs <- amqp.Delivery{Body: []byte("c")}
+	if d := receive(t, deliveries); string(d.Body) != "c" {
+		t.Fatalf("got %q", d.Body)
+	}
+
+	// Closing the transport ends the deliveries.
+	tr.Close()
+	close(msgs)
+	select {
+	case _, ok := <-deliveries:
+		if ok {
+			t.Fatal("delivery after close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("deliveries not closed")
+	}
+}
@@ -0,0 +1,92 @@
+package solana
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// TxFilter is one named transaction filter of the geyser subscription. The
+// node matches transactions against it, and the listener publishes whatever it
+// matched to Queue.
+type TxFilter struct {
+	Name  string `json:"name"`
+	Queue string `json:"queue"`
+	// DexPrograms adds every registered DEX program to AccountInclude.
+	DexPrograms     bool     `json:"dexPrograms"`
+	AccountInclude  []string `json:"accountInclude"`
+	AccountRequired []string `json:"accountRequired"`
+	AccountExclude  []string `json:"accountExclude"`
+	Vote            bool     `json:"vote"`
+	Failed          bool     `json:"failed"`
+}
+
+// DefaultTxFilters sends DEX swaps, token creations and every token program
+// instruction, like mints, burns and authority changes, to the tx queue.
+func DefaultTxFilters() []TxFilter {
+	return []TxFilter{
+		{Name: "dex_swaps", Queue: queueName, DexPrograms: true},
+		{Name: "token_creations", Queue: queueName, AccountInclude: []string{METAPLEX_TOKEN_METDATA}},
+		{Name: "token_programs", Queue: queueName, AccountInclude: []string{TOKEN_PROGRAM, TOKEN_2022_PROGRAM}},
+	}
+}
+
+// LoadTxFilters reads a JSON array of filters from path, or returns the
+// defaults when path is empty.
+func LoadTxFilters(path string) ([]TxFilter, error) {
+	if path == "" {
+		return resolveTxFilters(DefaultTxFilters())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tx filters: %w", err)
+	}
+
+	var filters []TxFilter
+	if err = json.Unmarshal(data, &filters); err != nil {
+		return nil, fmt.Errorf("failed to parse tx filters %s: %w", path, err)
+	}
+	return resolveTxFilters(filters)
+}
+
+func resolveTxFilters(filters []TxFilter) ([]TxFilter, error) {
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("no tx filters configured")
+	}
+
+	names := make(map[string]bool, len(filters))
+	for i := range filters {
+		f := &filters[i]
+		if f.Name == "" {
+			return nil, fmt.Errorf("tx filter %d has no name", i)
+		}
+		if names[f.Name] {
+			return nil, fmt.Errorf("duplicate tx filter %q", f.Name)
+		}
+		names[f.Name] = true
+
+		if f.Queue == "" {
+			f.Queue = queueName
+		}
+		if f.DexPrograms {
+			f.AccountInclude = append(f.AccountInclude, dexPrograms()...)
+		}
+		// An empty filter would match every transaction on the chain.
+		if len(f.AccountInclude) == 0 && len(f.AccountRequired) == 0 {
+			return nil, fmt.Errorf("tx filter %q includes no accounts", f.Name)
+		}
+	}
+
+	return filters, nil
+}
+
+func dexPrograms() []string {
+	programs := make([]string, 0, len(Programs))
+	for program := range Programs {
+		programs = append(programs, program)
+	}
+	sort.Strings(programs)
+	return programs
+}
@@ -6,6 +6,7 @@ import (
 	"blocsy/internal/types"
 	"context"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -21,10 +22,26 @@ func NewTxHandler(sh *SwapHandler, solSvc *SolanaService, repo TokensAndPairsRep
 
 }
 
-func (t *TxHandler) ProcessTransaction(ctx context.Context, tx *types.SolanaTx, timestamp int64, block uint64, ignoreWS bool) ([]types.SwapLog, error) {
-	transfers, burns, mints, tokensCreated := ParseTransaction(tx)
+// ProcessTransaction parses tx, stores what it created and returns its swaps.
+// The diagnostics list the instructions it could not decode. A decoder panic
+// is recovered and returned as an error.
+func (t *TxHandler) ProcessTransaction(ctx context.Context, tx *types.SolanaTx, timestamp int64, block uint64, ignoreWS bool) (swaps []types.SwapLog, diag types.ParseDiagnostics, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = types.NewDecodeError("", types.ErrPanic, "%v", r)
+			diag.Skip(-1, -1, "", err)
+			swaps = nil
+			if len(tx.Transaction.Signatures) > 0 {
+				log.Printf("Recovered from a parser panic in %s: %v", tx.Transaction.Signatures[0], r)
+			}
+		}
+		t.parseStats.add(diag)
+	}()
+
+	transfers, burns, mints, tokensCreated := parseTransaction(tx, &diag)
+	tokenEvents := ParseTokenEvents(tx, block, timestamp)
 	logs := GetLogs(tx.Meta.LogMessages)
-	swaps := t.sh.HandleSwaps(ctx, transfers, tx, timestamp, block)
+	swaps = t.sh.handleSwaps(ctx, transfers, tx, timestamp, block, &diag)
 
 	pumpFunTokens := dex.HandlePumpFunNewToken(logs, PUMPFUN)
 	go func() {
@@ -36,9 +53,15 @@ func (t *TxHandler) ProcessTransaction(ctx context.Context, tx *types.SolanaTx,
 		}
 	}()
 
+	t.Wg.Add(1)
 	go func() {
+		defer t.Wg.Done()
 
 		pumpFunTokenMints := make(map[string]bool)
+		createdByMint := make(map[string]types.Token, len(tokensCreated))
+		for _, token := range tokensCreated {
+			createdByMint[token.Address] = token
+		}
 
 		for _, pfToken := range pumpFunTokens {
 			deployer := pfToken.User.String()
@@ -55,6 +78,14 @@ func (t *TxHandler) ProcessTransaction(ctx context.Context, tx *types.SolanaTx,
 				Deployer:         &deployer,
 				Metadata:         &pfToken.Uri,
 			}
+			if created, ok := createdByMint[pfTokenData.Address]; ok {
+				pfTokenData.Program = created.Program
+				pfTokenData.Extensions = created.Extensions
+				pfTokenData.MintAuthority = created.MintAuthority
+				pfTokenData.FreezeAuthority = created.FreezeAuthority
+				pfTokenData.MintAuthorityRevoked = created.MintAuthorityRevoked
+				pfTokenData.FreezeAuthorityRevoked = created.FreezeAuthorityRevoked
+			}
 			err := t.repo.InsertToken(ctx, pfTokenData)
 			if err != nil {
 				log.Printf("failed to store pump fun token: %v", err)
@@ -73,13 +104,53 @@ func (t *TxHandler) ProcessTransaction(ctx context.Context, tx *types.SolanaTx,
 			}
 		}
 
+		// Keyed by instruction, so a block processed again changes nothing.
+		var signature string
+		if len(tx.Transaction.Signatures) > 0 {
+			signature = tx.Transaction.Signatures[0]
+		}
 		for _, burn := range burns {
-			t.repo.UpdateTokenSupply(ctx, burn.Mint, burn.Amount, "burn")
+			ixIndex, innerIndex := transferPosition(burn)
+			t.repo.ApplyTokenSupplyChange(ctx, block, signature, ixIndex, innerIndex, burn.Mint, burn.Amount, "burn")
 		}
 		for _, mint := range mints {
-			t.repo.UpdateTokenSupply(ctx, mint.Mint, mint.Amount, "mint")
+			ixIndex, innerIndex := transferPosition(mint)
+			t.repo.ApplyTokenSupplyChange(ctx, block, signature, ixIndex, innerIndex, mint.Mint, mint.Amount, "mint")
+		}
+
+		// After the inserts above, so authority changes reach tokens created
+		// in the same transaction.
+		if err := t.repo.InsertTokenEvents(ctx, tokenEvents); err != nil {
+			log.Printf("failed to store token events: %v", err)
 		}
 	}()
 
-	return swaps, nil
+	return swaps, diag, nil
+}
+
+// ParseStats is the per-program count of decoded and skipped instructions
+// since start.
+func (t *TxHandler) ParseStats() ParseStats {
+	return t.parseStats.snapshot()
+}
+
+// Drain waits for the token and supply writes still in flight. It returns
+// false if ctx ended first.
+func (t *TxHandler) Drain(ctx context.Context) bool {
+	return waitGroup(ctx, &t.Wg)
+}
+
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
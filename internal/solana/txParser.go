@@ -1,10 +1,16 @@
 package solana
 
 import (
+	"blocsy/internal/solana/anchor"
 	"blocsy/internal/types"
+	"encoding/base64"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/mr-tron/base58"
 	"math"
 	"math/big"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 func GetTokenBalanceDiffs(tx *types.SolanaTx) map[int]types.SolBalanceDiff {
@@ -76,6 +82,12 @@ func GetNativeBalanceDiffs(tx *types.SolanaTx) map[int]types.SolBalanceDiff {
 }
 
 func ParseTransaction(tx *types.SolanaTx) ([]types.SolTransfer, []types.SolTransfer, []types.SolTransfer, []types.Token) {
+	return parseTransaction(tx, nil)
+}
+
+// parseTransaction is ParseTransaction recording the instructions it decodes
+// and skips in diag.
+func parseTransaction(tx *types.SolanaTx, diag *types.ParseDiagnostics) ([]types.SolTransfer, []types.SolTransfer, []types.SolTransfer, []types.Token) {
 	accountKeys := getAllAccountKeys(tx)
 	AccountKeysMap := make(map[string]int, len(accountKeys))
 	for i := range accountKeys {
@@ -89,14 +101,19 @@ func ParseTransaction(tx *types.SolanaTx) ([]types.SolTransfer, []types.SolTrans
 	burns := make([]types.SolTransfer, 0)
 	tokenMints := make([]types.SolTransfer, 0)
 	tokensCreated := make([]types.Token, 0)
+	var logs []types.LogDetails
 
 	for instructionIndex := range tx.Transaction.Message.Instructions {
 		instruction := tx.Transaction.Message.Instructions[instructionIndex]
-		processedOuter, found := processInstruction(instruction, AccountKeysMap, balanceDiffMap, nativeBalanceDiffMap, tx, -1, instructionIndex)
-		if found {
-			parentProgramId, parentAccounts := findParentProgram(instructionIndex, tx, -1, -1, accountKeys)
+		processedOuter, found, err := processInstruction(instruction, AccountKeysMap, balanceDiffMap, nativeBalanceDiffMap, tx, -1, instructionIndex)
+		if err != nil {
+			diag.Skip(instructionIndex, -1, "", err)
+		} else if found {
+			diag.Decode(accountKeys[instruction.ProgramIdIndex])
+			parentProgramId, parentAccounts, parentData := findParentProgram(instructionIndex, tx, -1, -1, accountKeys)
 			processedOuter.IxAccounts = parentAccounts
 			processedOuter.ParentProgramId = parentProgramId
+			processedOuter.ParentData = parentData
 
 			if processedOuter.Amount != "" && processedOuter.Amount != "0" {
 				if processedOuter.Type == "burn" {
@@ -114,7 +131,12 @@ func ParseTransaction(tx *types.SolanaTx) ([]types.SolTransfer, []types.SolTrans
 					Decimals: uint8(processedOuter.Decimals),
 					Network:  "solana",
 					Supply:   "0",
+					Program:  processedOuter.Program,
+				}
+				if processedOuter.Program == TOKEN_2022_PROGRAM {
+					token.Extensions = strings.Join(findMintExtensions(tx, processedOuter.Mint), ",")
 				}
+				setMintAuthorities(tx, &token)
 
 				name, symbol, uri, foundMetadata := findMetaplexInstruction(tx, processedOuter.Mint)
 				if foundMetadata {
@@ -134,13 +156,26 @@ func ParseTransaction(tx *types.SolanaTx) ([]types.SolTransfer, []types.SolTrans
 			}
 			for ixIndex := range tx.Meta.InnerInstructions[innerIxIndex].Instructions {
 				innerInstruction := tx.Meta.InnerInstructions[innerIxIndex].Instructions[ixIndex]
-				processedInner, foundInner := processInstruction(innerInstruction, AccountKeysMap, balanceDiffMap, nativeBalanceDiffMap, tx, instructionIndex, ixIndex)
+				processedInner, foundInner, err := processInstruction(innerInstruction, AccountKeysMap, balanceDiffMap, nativeBalanceDiffMap, tx, instructionIndex, ixIndex)
 
-				if foundInner {
-					parentProgramId, parentAccounts := findParentProgram(instructionIndex, tx, innerIxIndex, ixIndex, accountKeys)
+				if err != nil {
+					diag.Skip(instructionIndex, ixIndex, "", err)
+				} else if foundInner {
+					diag.Decode(accountKeys[innerInstruction.ProgramIdIndex])
+					parentProgramId, parentAccounts, parentData := findParentProgram(instructionIndex, tx, innerIxIndex, ixIndex, accountKeys)
 					processedInner.IxAccounts = parentAccounts
 					processedInner.ParentProgramId = parentProgramId
-					processedInner.EventData = findPumpFunSwapEvent(instructionIndex, tx, innerIxIndex, ixIndex, accountKeys)
+					processedInner.ParentData = parentData
+					processedInner.EventData = findPumpFunSwapEvent(parentProgramId, tx, innerIxIndex, ixIndex, accountKeys)
+					// PumpSwap trades without the self-CPI event still logged it.
+					if processedInner.EventData == "" && parentProgramId == PUMPFUN_AMM {
+						if logs == nil {
+							logs = GetLogs(tx.Meta.LogMessages)
+						}
+						if len(logs) == len(tx.Transaction.Message.Instructions) {
+							processedInner.EventData = findPumpFunAmmLogEvent(logs[instructionIndex : instructionIndex+1])
+						}
+					}
 					if processedInner.Amount != "" && processedInner.Amount != "0" {
 						if processedInner.Type == "burn" {
 							burns = append(burns, processedInner)
@@ -156,7 +191,12 @@ func ParseTransaction(tx *types.SolanaTx) ([]types.SolTransfer, []types.SolTrans
 							Decimals: uint8(processedInner.Decimals),
 							Network:  "solana",
 							Supply:   "0",
+							Program:  processedInner.Program,
+						}
+						if processedInner.Program == TOKEN_2022_PROGRAM {
+							token.Extensions = strings.Join(findMintExtensions(tx, processedInner.Mint), ",")
 						}
+						setMintAuthorities(tx, &token)
 						name, symbol, uri, foundMetadata := findMetaplexInstruction(tx, processedInner.Mint)
 						if foundMetadata {
 							token.Metadata = &uri
@@ -171,38 +211,141 @@ func ParseTransaction(tx *types.SolanaTx) ([]types.SolTransfer, []types.SolTrans
 		}
 	}
 
+	applyTransferFees(tx, transfers, AccountKeysMap)
+
 	return transfers, burns, tokenMints, tokensCreated
 }
 
-func findParentProgram(ixIndex int, tx *types.SolanaTx, innerIxIndex int, innerInstructionIxIndex int, accountKeys []string) (string, []int) {
+// applyTransferFees nets the fee out of Token-2022 transfers on mints with a
+// transfer fee. A plain TransferChecked does not carry the fee, so it is
+// taken from the destination's balance, when that transfer is the only one
+// touching the destination.
+func applyTransferFees(tx *types.SolanaTx, transfers []types.SolTransfer, AccountKeysMap map[string]int) {
+	incoming := make(map[string]int)
+	outgoing := make(map[string]int)
+	for _, transfer := range transfers {
+		incoming[transfer.ToTokenAccount]++
+		outgoing[transfer.FromTokenAccount]++
+	}
+
+	for i := range transfers {
+		transfer := &transfers[i]
+		if transfer.Program != TOKEN_2022_PROGRAM || transfer.Type != "token" || transfer.Fee != "" {
+			continue
+		}
+		if incoming[transfer.ToTokenAccount] != 1 || outgoing[transfer.ToTokenAccount] != 0 {
+			continue
+		}
+
+		accountIndex, ok := FindAccountKeyIndex(AccountKeysMap, transfer.ToTokenAccount)
+		if !ok {
+			continue
+		}
+		received, ok := rawTokenBalanceDiff(tx, accountIndex)
+		if !ok {
+			continue
+		}
+		gross, ok := rawTokenAmount(transfer.Amount, transfer.Decimals)
+		if !ok || received.Sign() <= 0 || received.Cmp(gross) >= 0 {
+			continue
+		}
+
+		transfer.Amount = formatTokenAmount(received.Uint64(), transfer.Decimals)
+		transfer.Fee = formatTokenAmount(new(big.Int).Sub(gross, received).Uint64(), transfer.Decimals)
+	}
+}
+
+// rawTokenBalanceDiff is the change of a token account's balance in base
+// units. An account created by the transaction has no pre balance.
+func rawTokenBalanceDiff(tx *types.SolanaTx, accountIndex int) (*big.Int, bool) {
+	post, pre := new(big.Int), new(big.Int)
+	foundPost := false
+
+	for _, balance := range tx.Meta.PostTokenBalances {
+		if balance.AccountIndex == accountIndex {
+			if _, ok := post.SetString(balance.UITokenAmount.Amount, 10); !ok {
+				return nil, false
+			}
+			foundPost = true
+		}
+	}
+	if !foundPost {
+		return nil, false
+	}
+	for _, balance := range tx.Meta.PreTokenBalances {
+		if balance.AccountIndex == accountIndex {
+			if _, ok := pre.SetString(balance.UITokenAmount.Amount, 10); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	return post.Sub(post, pre), true
+}
+
+// rawTokenAmount converts a formatted amount back to base units.
+func rawTokenAmount(amount string, decimals int) (*big.Int, bool) {
+	if decimals < 0 {
+		return nil, false
+	}
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok {
+		return nil, false
+	}
+	value.Mul(value, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+
+	// Round to the nearest unit; the formatted amount may be inexact.
+	raw, rem := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		raw.Add(raw, big.NewInt(1))
+	}
+	return raw, true
+}
+
+func findParentProgram(ixIndex int, tx *types.SolanaTx, innerIxIndex int, innerInstructionIxIndex int, accountKeys []string) (string, []int, string) {
 
 	if innerIxIndex >= 0 {
 		// If inner instruction, traverse backwards within inner instructions
 		for innerI := innerInstructionIxIndex; innerI >= 0; innerI-- {
 			ix := tx.Meta.InnerInstructions[innerIxIndex].Instructions[innerI]
-			if validateParentProgram(accountKeys[ix.ProgramIdIndex]) {
+			if ix.ProgramIdIndex < len(accountKeys) && validateParentProgram(accountKeys[ix.ProgramIdIndex]) {
 				var accounts []int
 				if validateDexInstruction(accountKeys[ix.ProgramIdIndex], ix.Accounts, accountKeys) {
 					accounts = ix.Accounts
-					return accountKeys[ix.ProgramIdIndex], accounts
+					return accountKeys[ix.ProgramIdIndex], accounts, ix.Data
 				}
 			}
 		}
 	}
 
 	baseIx := tx.Transaction.Message.Instructions[ixIndex]
-	if validateParentProgram(accountKeys[baseIx.ProgramIdIndex]) {
+	if baseIx.ProgramIdIndex < len(accountKeys) && validateParentProgram(accountKeys[baseIx.ProgramIdIndex]) {
 		var accounts []int
 		if validateDexInstruction(accountKeys[baseIx.ProgramIdIndex], baseIx.Accounts, accountKeys) {
 			accounts = baseIx.Accounts
-			return accountKeys[baseIx.ProgramIdIndex], accounts
+			return accountKeys[baseIx.ProgramIdIndex], accounts, baseIx.Data
 
 		}
 	}
 
-	return "", nil
+	return "", nil, ""
 }
 
+// tokenMovements are the token instructions that move tokens, with the
+// number of accounts they take.
+var tokenMovements = map[string]int{
+	"Transfer":               3,
+	"TransferChecked":        4,
+	"TransferCheckedWithFee": 4,
+	"MintTo":                 3,
+	"MintToChecked":          3,
+	"Burn":                   3,
+	"BurnChecked":            3,
+}
+
+// processInstruction turns a system or token program instruction into a
+// transfer. It returns an error only for instructions it should have
+// understood but could not decode.
 func processInstruction(
 	ix types.Instruction,
 	AccountKeysMap map[string]int,
@@ -210,32 +353,33 @@ func processInstruction(
 	nativeBalanceDiffMap map[int]types.SolBalanceDiff,
 	tx *types.SolanaTx,
 	innerIndex int,
-	ixIndex int) (types.SolTransfer, bool) {
+	ixIndex int) (types.SolTransfer, bool, error) {
 	accountKeys := getAllAccountKeys(tx)
 
 	if len(accountKeys)-1 < ix.ProgramIdIndex {
-		return types.SolTransfer{}, false
+		return types.SolTransfer{}, false, nil
 	}
 	programId := accountKeys[ix.ProgramIdIndex]
 
 	if programId == SYSTEM_PROGRAM {
 
-		var instructionData = DecodeSystemProgramData(ix.Data)
+		instructionData, err := DecodeSystemProgramData(ix.Data)
+		if err != nil {
+			return types.SolTransfer{}, false, err
+		}
 
 		if instructionData.Type != "Transfer" {
-			return types.SolTransfer{}, false
+			return types.SolTransfer{}, false, nil
 		}
 
-		for _, accountIndex := range ix.Accounts {
-			if accountIndex > len(accountKeys)-1 {
-				return types.SolTransfer{}, false
-			}
+		if err = checkAccounts(programId, ix.Accounts, 2, accountKeys); err != nil {
+			return types.SolTransfer{}, false, err
 		}
 
 		source := accountKeys[ix.Accounts[0]]
 		destination := accountKeys[ix.Accounts[1]]
 		if destination == "" || source == "" {
-			return types.SolTransfer{}, false
+			return types.SolTransfer{}, false, nil
 		}
 
 		amount := new(big.Float).Quo(new(big.Float).SetUint64(instructionData.Lamports), big.NewFloat(1e9)).Text('f', -1)
@@ -252,36 +396,49 @@ func processInstruction(
 		}
 		if transfer.Amount == "" {
 
-			return types.SolTransfer{}, false
+			return types.SolTransfer{}, false, nil
 		}
-		return transfer, true
+		return transfer, true, nil
 	}
 
 	//Spl-token program
-	if programId == TOKEN_PROGRAM {
-		for _, accountIndex := range ix.Accounts {
-			if accountIndex > len(accountKeys)-1 {
-				return types.SolTransfer{}, false
-			}
+	if isTokenProgram(programId) {
+		if err := checkAccounts(programId, ix.Accounts, 0, accountKeys); err != nil {
+			return types.SolTransfer{}, false, err
 		}
 
 		var amount, source, destination, authority, mint, toUserAccount string
 		var decimals = -1
 
-		var instructionData = DecodeTokenProgramData(ix.Data)
+		instructionData, err := DecodeTokenProgramData(programId, ix.Data)
+		if err != nil {
+			return types.SolTransfer{}, false, err
+		}
 		tType := "token"
 
 		if instructionData.Type == "InitializeMint" || instructionData.Type == "InitializeMint2" {
+			if err = checkAccounts(programId, ix.Accounts, 1, accountKeys); err != nil {
+				return types.SolTransfer{}, false, err
+			}
 			mint = accountKeys[ix.Accounts[0]]
 			decimals = instructionData.Decimals
 			return types.SolTransfer{
 				Type:     "initMint",
 				Mint:     mint,
 				Decimals: decimals,
-			}, false
+				Program:  programId,
+			}, false, nil
 		}
 
-		if instructionData.Type == "TransferChecked" {
+		accountsNeeded, moves := tokenMovements[instructionData.Type]
+		if !moves {
+			return types.SolTransfer{}, false, nil
+		}
+		if err = checkAccounts(programId, ix.Accounts, accountsNeeded, accountKeys); err != nil {
+			return types.SolTransfer{}, false, err
+		}
+
+		if instructionData.Type == "TransferChecked" || instructionData.Type == "TransferCheckedWithFee" {
 			source = accountKeys[ix.Accounts[0]]
 			mint = accountKeys[ix.Accounts[1]]
 			destination = accountKeys[ix.Accounts[2]]
@@ -291,18 +448,18 @@ func processInstruction(
 			source = accountKeys[ix.Accounts[0]]
 			destination = accountKeys[ix.Accounts[1]]
 			authority = accountKeys[ix.Accounts[2]]
-		} else if instructionData.Type == "MintTo" {
+		} else if instructionData.Type == "MintTo" || instructionData.Type == "MintToChecked" {
 			tType = "mint"
 			mint = accountKeys[ix.Accounts[0]]
 			destination = accountKeys[ix.Accounts[1]] //account
 			authority = accountKeys[ix.Accounts[2]]
-		} else if instructionData.Type == "Burn" {
+		} else if instructionData.Type == "Burn" || instructionData.Type == "BurnChecked" {
 			tType = "burn"
 			source = accountKeys[ix.Accounts[0]]
 			mint = accountKeys[ix.Accounts[1]]
 			authority = accountKeys[ix.Accounts[2]]
 		} else {
-			return types.SolTransfer{}, false
+			return types.SolTransfer{}, false, nil
 		}
 
 		fromUserAccount := ""
@@ -344,7 +501,14 @@ func processInstruction(
 		if decimals == -1 {
 			decimals = FindMintDecimals(tx, mint)
 		}
-		amount = new(big.Float).Quo(new(big.Float).SetUint64(instructionData.Amount), new(big.Float).SetFloat64(math.Pow10(decimals))).Text('f', -1)
+		// The destination receives the amount less the withheld fee.
+		received := instructionData.Amount
+		var fee string
+		if instructionData.Fee > 0 && instructionData.Fee <= received {
+			received -= instructionData.Fee
+			fee = formatTokenAmount(instructionData.Fee, decimals)
+		}
+		amount = formatTokenAmount(received, decimals)
 		transfer := types.SolTransfer{
 			IxIndex:          ixIndex,
 			InnerIndex:       innerIndex,
@@ -353,16 +517,22 @@ func processInstruction(
 			FromTokenAccount: source,
 			FromUserAccount:  fromUserAccount,
 			Amount:           amount,
+			Fee:              fee,
 			Mint:             mint,
 			Decimals:         decimals,
 			Type:             tType,
+			Program:          programId,
 			Authority:        authority,
 		}
 
-		return transfer, true
+		return transfer, true, nil
 	}
 
-	return types.SolTransfer{}, false
+	return types.SolTransfer{}, false, nil
+}
+
+func formatTokenAmount(raw uint64, decimals int) string {
+	return new(big.Float).Quo(new(big.Float).SetUint64(raw), new(big.Float).SetFloat64(math.Pow10(decimals))).Text('f', -1)
 }
 
 func findUserAccount(tokenAccount string, tx *types.SolanaTx) (types.TokenAccountDetails, bool) {
@@ -448,30 +618,36 @@ func findAccount(ix types.Instruction, tokenAccount string, accountKeys []string
 		}
 
 	}
-	if programId == TOKEN_PROGRAM {
-		var instructionData = DecodeTokenProgramData(ix.Data)
-		if instructionData.Type == "InitializeAccount3" {
+	if isTokenProgram(programId) {
+		instructionData, err := DecodeTokenProgramData(programId, ix.Data)
+		if err != nil {
+			return userAccount, mint, false
+		}
+		if instructionData.Type == "InitializeAccount3" && len(ix.Accounts) >= 2 {
 			foundTokenAccount = accountKeys[ix.Accounts[0]]
 			mint = accountKeys[ix.Accounts[1]]
 			userAccount = instructionData.Owner.String()
-		} else if instructionData.Type == "InitializeAccount" {
+		} else if instructionData.Type == "InitializeAccount" && len(ix.Accounts) >= 3 {
 			foundTokenAccount = accountKeys[ix.Accounts[0]]
 			mint = accountKeys[ix.Accounts[1]]
 			userAccount = accountKeys[ix.Accounts[2]]
-		} else if instructionData.Type == "InitializeAccount2" {
+		} else if instructionData.Type == "InitializeAccount2" && len(ix.Accounts) >= 2 {
 			foundTokenAccount = accountKeys[ix.Accounts[0]]
 			mint = accountKeys[ix.Accounts[1]]
 			userAccount = instructionData.Owner.String()
-		} else if instructionData.Type == "CloseAccount" {
+		} else if instructionData.Type == "CloseAccount" && len(ix.Accounts) >= 3 {
 			foundTokenAccount = accountKeys[ix.Accounts[0]]
 			userAccount = accountKeys[ix.Accounts[2]]
 		}
 
 	}
 	if programId == SYSTEM_PROGRAM {
-		var instructionData = DecodeSystemProgramData(ix.Data)
+		instructionData, err := DecodeSystemProgramData(ix.Data)
+		if err != nil {
+			return userAccount, mint, false
+		}
 
-		if instructionData.Type == "CreateAccount" {
+		if instructionData.Type == "CreateAccount" && len(ix.Accounts) >= 2 {
 			source := accountKeys[ix.Accounts[0]]
 			newAccount := accountKeys[ix.Accounts[1]]
 			if newAccount == tokenAccount {
@@ -496,12 +672,17 @@ func findMintInBalances(tx *types.SolanaTx, mint string) int {
 	return -1
 }
 
-func findPumpFunSwapEvent(ixIndex int, tx *types.SolanaTx, innerIxIndex int, innerInstructionIxIndex int, accountKeys []string) string {
+// findPumpFunSwapEvent is the data of the self-CPI event that program, a
+// pump.fun program, emitted after the inner instruction.
+func findPumpFunSwapEvent(program string, tx *types.SolanaTx, innerIxIndex int, innerInstructionIxIndex int, accountKeys []string) string {
+	if program != PUMPFUN && program != PUMPFUN_AMM {
+		return ""
+	}
 	if innerIxIndex >= 0 {
 		// If inner instruction, traverse backwards within inner instructions
 		for innerI := innerInstructionIxIndex; innerI < len(tx.Meta.InnerInstructions[innerIxIndex].Instructions); innerI++ {
 			ix := tx.Meta.InnerInstructions[innerIxIndex].Instructions[innerI]
-			if accountKeys[ix.ProgramIdIndex] == PUMPFUN {
+			if ix.ProgramIdIndex < len(accountKeys) && accountKeys[ix.ProgramIdIndex] == program {
 				return ix.Data
 			}
 		}
@@ -509,12 +690,131 @@ func findPumpFunSwapEvent(ixIndex int, tx *types.SolanaTx, innerIxIndex int, inn
 	return ""
 }
 
+// findPumpFunAmmLogEvent is the PumpSwap trade event logged in logs, base58
+// like a self-CPI event, when there is exactly one.
+func findPumpFunAmmLogEvent(logs []types.LogDetails) string {
+	program := anchor.Default().Program(PUMPFUN_AMM)
+	if program == nil {
+		return ""
+	}
+
+	var found []string
+	var walk func(logs []types.LogDetails)
+	walk = func(logs []types.LogDetails) {
+		for _, logDetail := range logs {
+			if logDetail.Program == PUMPFUN_AMM {
+				for _, line := range logDetail.Logs {
+					encoded, ok := strings.CutPrefix(line, "Program data: ")
+					if !ok {
+						continue
+					}
+					data, err := base64.StdEncoding.DecodeString(encoded)
+					if err != nil {
+						continue
+					}
+					if event, err := program.DecodeEvent(data); err == nil && (event.Name == "BuyEvent" || event.Name == "SellEvent") {
+						found = append(found, base58.Encode(data))
+					}
+				}
+			}
+			walk(logDetail.SubLogs)
+		}
+	}
+	walk(logs)
+
+	if len(found) != 1 {
+		return ""
+	}
+	return found[0]
+}
+
+// setMintAuthorities copies the authorities the mint was initialized with.
+// Later SetAuthority instructions reach the token as token events.
+func setMintAuthorities(tx *types.SolanaTx, token *types.Token) {
+	accountKeys := getAllAccountKeys(tx)
+
+	initialize := func(ix types.Instruction) bool {
+		if ix.ProgramIdIndex >= len(accountKeys) || !isTokenProgram(accountKeys[ix.ProgramIdIndex]) {
+			return false
+		}
+		if len(ix.Accounts) == 0 || ix.Accounts[0] >= len(accountKeys) || accountKeys[ix.Accounts[0]] != token.Address {
+			return false
+		}
+		data, err := DecodeTokenProgramData(accountKeys[ix.ProgramIdIndex], ix.Data)
+		if err != nil || (data.Type != "InitializeMint" && data.Type != "InitializeMint2") {
+			return false
+		}
+
+		if data.MintAuthority != (common.PublicKey{}) {
+			mintAuthority := data.MintAuthority.String()
+			token.MintAuthority = &mintAuthority
+		}
+		token.MintAuthorityRevoked = token.MintAuthority == nil
+		if data.FreezeAuthority != (common.PublicKey{}) {
+			freezeAuthority := data.FreezeAuthority.String()
+			token.FreezeAuthority = &freezeAuthority
+		}
+		token.FreezeAuthorityRevoked = token.FreezeAuthority == nil
+		return true
+	}
+
+	for i, instruction := range tx.Transaction.Message.Instructions {
+		if initialize(instruction) {
+			return
+		}
+		for _, inner := range tx.Meta.InnerInstructions {
+			if inner.Index != i {
+				continue
+			}
+			for _, innerInstruction := range inner.Instructions {
+				if initialize(innerInstruction) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// findMintExtensions lists the Token-2022 extensions the transaction
+// initializes on mint, in instruction order.
+func findMintExtensions(tx *types.SolanaTx, mint string) []string {
+	accountKeys := getAllAccountKeys(tx)
+	extensions := make([]string, 0)
+
+	collect := func(ix types.Instruction) {
+		if ix.ProgramIdIndex >= len(accountKeys) || accountKeys[ix.ProgramIdIndex] != TOKEN_2022_PROGRAM {
+			return
+		}
+		if len(ix.Accounts) == 0 || ix.Accounts[0] >= len(accountKeys) || accountKeys[ix.Accounts[0]] != mint {
+			return
+		}
+		data, err := DecodeTokenProgramData(TOKEN_2022_PROGRAM, ix.Data)
+		if err == nil && data.Extension != "" && !slices.Contains(extensions, data.Extension) {
+			extensions = append(extensions, data.Extension)
+		}
+	}
+
+	for i, instruction := range tx.Transaction.Message.Instructions {
+		collect(instruction)
+		for _, inner := range tx.Meta.InnerInstructions {
+			if inner.Index != i {
+				continue
+			}
+			for _, innerInstruction := range inner.Instructions {
+				collect(innerInstruction)
+			}
+		}
+	}
+
+	return extensions
+}
+
 func findMetaplexInstruction(tx *types.SolanaTx, mint string) (string, string, string, bool) {
 	accountKeys := getAllAccountKeys(tx)
 
 	for _, instruction := range tx.Transaction.Message.Instructions {
-		if accountKeys[instruction.ProgramIdIndex] == METAPLEX_TOKEN_METDATA {
-			if len(instruction.Accounts) > 2 {
+		if instruction.ProgramIdIndex < len(accountKeys) && accountKeys[instruction.ProgramIdIndex] == METAPLEX_TOKEN_METDATA {
+			if len(instruction.Accounts) > 2 && instruction.Accounts[1] < len(accountKeys) {
 				if accountKeys[instruction.Accounts[1]] == mint {
 					metadataAccount, err := DecodeMetaplexData(instruction.Data)
 					if err != nil {
@@ -528,8 +828,8 @@ func findMetaplexInstruction(tx *types.SolanaTx, mint string) (string, string, s
 
 	for _, innerInstruction := range tx.Meta.InnerInstructions {
 		for _, instruction := range innerInstruction.Instructions {
-			if accountKeys[instruction.ProgramIdIndex] == METAPLEX_TOKEN_METDATA {
-				if len(instruction.Accounts) > 2 {
+			if instruction.ProgramIdIndex < len(accountKeys) && accountKeys[instruction.ProgramIdIndex] == METAPLEX_TOKEN_METDATA {
+				if len(instruction.Accounts) > 2 && instruction.Accounts[1] < len(accountKeys) {
 					if accountKeys[instruction.Accounts[1]] == mint {
 						metadataAccount, err := DecodeMetaplexData(instruction.Data)
 						if err != nil {
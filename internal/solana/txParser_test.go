@@ -0,0 +1,128 @@
+package solana
+
+import (
+	"blocsy/internal/types"
+	"encoding/binary"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/mr-tron/base58"
+	"testing"
+)
+
+// token2022 adds a Token-2022 inner instruction.
+func (f *txFixture) token2022(data []byte, accounts ...string) {
+	f.inner = append(f.inner, types.Instruction{ProgramIdIndex: f.index(TOKEN_2022_PROGRAM), Accounts: f.indexes(accounts...), Data: base58.Encode(data)})
+}
+
+// token2022Account is tokenAccount for a Token-2022 account, with its balance
+// in base units before and after the transaction.
+func (f *txFixture) token2022Account(tx *types.SolanaTx, account string, owner string, mint string, pre string, post string) {
+	balance := func(amount string) types.TokenBalance {
+		return types.TokenBalance{
+			AccountIndex:  f.index(account),
+			Mint:          mint,
+			Owner:         owner,
+			ProgramId:     TOKEN_2022_PROGRAM,
+			UITokenAmount: types.UITokenAmount{Decimals: 6, Amount: amount},
+		}
+	}
+	tx.Meta.PreTokenBalances = append(tx.Meta.PreTokenBalances, balance(pre))
+	tx.Meta.PostTokenBalances = append(tx.Meta.PostTokenBalances, balance(post))
+}
+
+func transferChecked(amount uint64) []byte {
+	return append(binary.LittleEndian.AppendUint64([]byte{12}, amount), 6)
+}
+
+func transferCheckedWithFee(amount uint64, fee uint64) []byte {
+	data := append(binary.LittleEndian.AppendUint64([]byte{26, transferFeeTransferCheckedWithFee}, amount), 6)
+	return binary.LittleEndian.AppendUint64(data, fee)
+}
+
+func checkTransfers(t *testing.T, transfers []types.SolTransfer, want ...[2]string) {
+	t.Helper()
+	if len(transfers) != len(want) {
+		t.Fatalf("got %d transfers: %+v", len(transfers), transfers)
+	}
+	for i, transfer := range transfers {
+		if transfer.Program != TOKEN_2022_PROGRAM || transfer.Mint != tokenMint || transfer.Amount != want[i][0] || transfer.Fee != want[i][1] {
+			t.Fatalf("transfer %d: %+v, want amount %s fee %q", i, transfer, want[i][0], want[i][1])
+		}
+	}
+}
+
+func TestTransferCheckedWithFee(t *testing.T) {
+	f := &txFixture{}
+	f.token2022(transferCheckedWithFee(2_000_000, 20_000), "source", tokenMint, "destination", "sender")
+	tx := f.tx("router", "sender")
+	f.token2022Account(tx, "source", "sender", tokenMint, "5000000", "3000000")
+	// The fee stays withheld in the destination, so its balance does not show it.
+	f.token2022Account(tx, "destination", "receiver", tokenMint, "0", "1980000")
+
+	transfers, _, _, _ := ParseTransaction(tx)
+	checkTransfers(t, transfers, [2]string{"1.98", "0.02"})
+	if transfers[0].FromUserAccount != "sender" || transfers[0].ToUserAccount != "receiver" {
+		t.Fatalf("got %+v", transfers[0])
+	}
+}
+
+func TestTransferCheckedBalanceFee(t *testing.T) {
+	f := &txFixture{}
+	f.token2022(transferChecked(2_000_000), "source", tokenMint, "destination", "sender")
+	tx := f.tx("router", "sender")
+	f.token2022Account(tx, "source", "sender", tokenMint, "5000000", "3000000")
+	f.token2022Account(tx, "destination", "receiver", tokenMint, "1000000", "2990000")
+
+	transfers, _, _, _ := ParseTransaction(tx)
+	checkTransfers(t, transfers, [2]string{"1.99", "0.01"})
+
+	// Without a fee the balance matches the amount.
+	tx.Meta.PostTokenBalances[1].UITokenAmount.Amount = "3000000"
+	transfers, _, _, _ = ParseTransaction(tx)
+	checkTransfers(t, transfers, [2]string{"2", ""})
+}
+
+func TestTransferCheckedSharedDestination(t *testing.T) {
+	f := &txFixture{}
+	f.token2022(transferChecked(2_000_000), "source", tokenMint, "destination", "sender")
+	f.token2022(transferChecked(10), "other", tokenMint, "destination", "sender")
+	tx := f.tx("router", "sender")
+	f.token2022Account(tx, "source", "sender", tokenMint, "5000000", "3000000")
+	f.token2022Account(tx, "other", "sender", tokenMint, "10", "0")
+	f.token2022Account(tx, "destination", "receiver", tokenMint, "0", "1980010")
+
+	// The balance can't tell the fees of the two transfers apart.
+	transfers, _, _, _ := ParseTransaction(tx)
+	checkTransfers(t, transfers, [2]string{"2", ""}, [2]string{"0.00001", ""})
+}
+
+func TestToken2022MintExtensions(t *testing.T) {
+	mintAuthority := common.PublicKeyFromString(tokenMint)
+
+	f := &txFixture{}
+	transferFeeConfig := append([]byte{26, 0}, make([]byte, 76)...)
+	metadataPointer := append([]byte{39, 0}, make([]byte, 64)...)
+	initializeMint := append([]byte{20, 6}, mintAuthority.Bytes()...)
+	initializeMint = append(initializeMint, 0)
+	f.token2022(transferFeeConfig, "mint")
+	f.token2022(metadataPointer, "mint")
+	f.token2022(initializeMint, "mint")
+	f.token2022(append(append([]byte{}, tokenMetadataInitialize...), make([]byte, 12)...), "mint", "updateAuthority", "mint", "mintAuthority")
+	// A sub-instruction other than initialize adds nothing.
+	f.token2022([]byte{26, 5, 1, 0}, "mint", "authority")
+	tx := f.tx("launchpad", "creator", "mint")
+
+	_, _, _, tokens := ParseTransaction(tx)
+	if len(tokens) != 1 {
+		t.Fatalf("got %d tokens: %+v", len(tokens), tokens)
+	}
+	token := tokens[0]
+	if token.Address != "mint" || token.Program != TOKEN_2022_PROGRAM || token.Decimals != 6 {
+		t.Fatalf("got %+v", token)
+	}
+	if token.Extensions != "transferFeeConfig,metadataPointer,tokenMetadata" {
+		t.Fatalf("extensions %q", token.Extensions)
+	}
+	if token.MintAuthority == nil || *token.MintAuthority != tokenMint || !token.FreezeAuthorityRevoked {
+		t.Fatalf("authorities %v %v", token.MintAuthority, token.FreezeAuthorityRevoked)
+	}
+}
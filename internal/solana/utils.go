@@ -24,7 +24,7 @@ func validateTX(tx *types.SolanaTx) bool {
 			key == METEORA_DLMM_PROGRAM ||
 			key == METEORA_POOLS_PROGRAM ||
 			key == RAYDIUM_LIQ_POOL_V4 ||
-			key == TOKEN_PROGRAM ||
+			isTokenProgram(key) ||
 			key == ORCA_WHIRL_PROGRAM_ID {
 			return true
 		}
@@ -33,30 +33,45 @@ func validateTX(tx *types.SolanaTx) bool {
 	return false
 }
 
+// isTokenProgram reports whether programId is the SPL token program or
+// Token-2022, which shares its instruction layout.
+func isTokenProgram(programId string) bool {
+	return programId == TOKEN_PROGRAM || programId == TOKEN_2022_PROGRAM
+}
+
 func validateSupportedDex(programId string) bool {
 	switch programId {
 	case
 		PUMPFUN,
 		METEORA_DLMM_PROGRAM,
+		METEORA_POOLS_PROGRAM,
 		RAYDIUM_LIQ_POOL_V4,
 		PUMPFUN_AMM,
 		RAYDIUM_CONCENTRATED_LIQ,
 		RAYDIUM_LAUNCHPAD,
 		RAYDIUM_CPMM,
-		ORCA_WHIRL_PROGRAM_ID:
+		ORCA_WHIRL_PROGRAM_ID,
+		LIFINITY_SWAP_V2,
+		PHOENIX,
+		FLUXBEAM_PROGRAM,
+		ORCA_SWAP,
+		ORCA_SWAP_V2:
 		return true
 	}
 	return false
 }
 
 func validateDexInstruction(program string, accounts []int, accountKeys []string) bool {
+	if checkAccounts(program, accounts, 0, accountKeys) != nil {
+		return false
+	}
 	if program == ORCA_WHIRL_PROGRAM_ID {
-		if len(accounts) == 15 || (len(accounts) == 11 && accountKeys[accounts[0]] == TOKEN_PROGRAM) {
+		if len(accounts) == 15 || (len(accounts) == 11 && isTokenProgram(accountKeys[accounts[0]])) {
 			return true
 		}
 	}
 	if program == RAYDIUM_LIQ_POOL_V4 {
-		if len(accounts) == 18 || len(accounts) == 17 && accountKeys[accounts[0]] == TOKEN_PROGRAM {
+		if len(accounts) == 18 || len(accounts) == 17 && isTokenProgram(accountKeys[accounts[0]]) {
 			return true
 		}
 	}
@@ -65,6 +80,13 @@ func validateDexInstruction(program string, accounts []int, accountKeys []string
 			return true
 		}
 	}
+	if program == METEORA_POOLS_PROGRAM {
+		// Swaps pass the vault program after the user; liquidity
+		// instructions pass it one account later.
+		if len(accounts) >= 15 && accountKeys[accounts[13]] == METEORA_VAULT_PROGRAM && isTokenProgram(accountKeys[accounts[14]]) {
+			return true
+		}
+	}
 	if program == PUMPFUN {
 		if len(accounts) >= 12 && accountKeys[accounts[11]] == PUMPFUN {
 			return true
@@ -76,7 +98,7 @@ func validateDexInstruction(program string, accounts []int, accountKeys []string
 		}
 	}
 	if program == RAYDIUM_CONCENTRATED_LIQ {
-		if len(accounts) >= 10 && accountKeys[accounts[8]] == TOKEN_PROGRAM {
+		if len(accounts) >= 10 && isTokenProgram(accountKeys[accounts[8]]) {
 			return true
 		}
 	}
@@ -91,12 +113,26 @@ func validateDexInstruction(program string, accounts []int, accountKeys []string
 		}
 	}
 	if program == PHOENIX {
-		if len(accounts) >= 9 && accountKeys[accounts[8]] == TOKEN_PROGRAM && accountKeys[accounts[0]] == PHOENIX {
+		if len(accounts) >= 9 && isTokenProgram(accountKeys[accounts[8]]) && accountKeys[accounts[0]] == PHOENIX {
 			return true
 		}
 	}
 	if program == LIFINITY_SWAP_V2 {
-		if len(accounts) >= 13 && accountKeys[accounts[9]] == TOKEN_PROGRAM {
+		if len(accounts) >= 13 && isTokenProgram(accountKeys[accounts[9]]) {
+			return true
+		}
+	}
+	if program == ORCA_SWAP || program == ORCA_SWAP_V2 {
+		// Withdrawals pass the fee account where swaps and deposits pass the
+		// token program.
+		if len(accounts) >= 10 && isTokenProgram(accountKeys[accounts[9]]) {
+			return true
+		}
+	}
+	if program == FLUXBEAM_PROGRAM {
+		// Swaps end with the source, destination and pool token programs;
+		// deposits and withdrawals have fewer accounts.
+		if len(accounts) >= 14 && isTokenProgram(accountKeys[accounts[11]]) && isTokenProgram(accountKeys[accounts[12]]) && isTokenProgram(accountKeys[accounts[13]]) {
 			return true
 		}
 	}
@@ -109,7 +145,7 @@ func validateParentProgram(programId string) bool {
 		METEORA_DLMM_PROGRAM, METEORA_POOLS_PROGRAM,
 		RAYDIUM_LIQ_POOL_V4, RAYDIUM_CONCENTRATED_LIQ, RAYDIUM_CPMM, RAYDIUM_LAUNCHPAD,
 		JUPITER_V6_AGGREGATOR,
-		LIFINITY_SWAP_V2, PHOENIX,
+		LIFINITY_SWAP_V2, PHOENIX, FLUXBEAM_PROGRAM,
 		PUMPFUN_AMM,
 		ORCA_WHIRL_PROGRAM_ID, ORCA_SWAP_V2, ORCA_SWAP:
 		return true
@@ -117,6 +153,20 @@ func validateParentProgram(programId string) bool {
 	return false
 }
 
+// checkAccounts makes sure an instruction of program has at least need
+// accounts and that all of them resolve to a key.
+func checkAccounts(program string, accounts []int, need int, accountKeys []string) error {
+	if len(accounts) < need {
+		return types.NewDecodeError(program, types.ErrMissingAccounts, "need %d, got %d", need, len(accounts))
+	}
+	for _, accountIndex := range accounts {
+		if accountIndex < 0 || accountIndex >= len(accountKeys) {
+			return types.NewDecodeError(program, types.ErrAccountIndex, "%d of %d keys", accountIndex, len(accountKeys))
+		}
+	}
+	return nil
+}
+
 func FindAccountKeyIndex(keyMap map[string]int, key string) (int, bool) {
 	if i, ok := keyMap[key]; ok {
 		return i, true
@@ -132,11 +182,15 @@ func GetLogs(logs []string) []types.LogDetails {
 
 	for _, l := range logs {
 		if strings.Contains(l, "invoke") {
+			fields := strings.Fields(l)
+			if len(fields) < 2 {
+				continue
+			}
 			if current.Program != "" {
 				stack = append(stack, current)
 			}
 			current = types.LogDetails{
-				Program: strings.Fields(l)[1],
+				Program: fields[1],
 			}
 		} else if strings.Contains(l, "Program log:") || strings.Contains(l, "Program data:") {
 			current.Logs = append(current.Logs, l)
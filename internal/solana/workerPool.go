@@ -0,0 +1,206 @@
+package solana
+
+import (
+	"context"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMinWorkers = 20
+	defaultMaxWorkers = 200
+	defaultTxWorkers  = 10
+	defaultSlowInsert = 500 * time.Millisecond
+
+	scaleInterval = 5 * time.Second
+	statsEvery    = 12 // scale intervals between stats logs
+	insertWeight  = 0.2
+
+	// A consumer more than backlogLag behind the chain is behind even when the
+	// transport cannot tell its depth.
+	backlogLag = 10 * time.Second
+	cpuHigh    = 0.85
+	cpuLow     = 0.70
+)
+
+// QueueStats is a snapshot of the consumer's worker pool. Depth is -1 when the
+// transport cannot tell, and Lag is how far behind the chain the newest live
+// block handled is.
+type QueueStats struct {
+	Workers       int           `json:"workers"`
+	MinWorkers    int           `json:"minWorkers"`
+	MaxWorkers    int           `json:"maxWorkers"`
+	InFlight      int64         `json:"inFlight"`
+	Prefetch      int           `json:"prefetch"`
+	Depth         int           `json:"depth"`
+	Lag           time.Duration `json:"lag"`
+	InsertLatency time.Duration `json:"insertLatency"`
+	CPU           float64       `json:"cpu"`
+}
+
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// queuePrefetch is the most unacked deliveries a consumer holds, QUEUE_PREFETCH.
+func queuePrefetch() int {
+	return envInt("QUEUE_PREFETCH", rabbitPrefetch)
+}
+
+func (qh *QueueHandler) Stats() QueueStats {
+	qh.mu.Lock()
+	st := qh.stats
+	st.Workers = len(qh.workerPool)
+	qh.mu.Unlock()
+
+	st.MinWorkers, st.MaxWorkers = qh.minWorkers, qh.maxWorkers
+	st.InFlight = qh.inFlight.Load()
+	st.InsertLatency = time.Duration(qh.insertNs.Load())
+	if last := qh.lastBlockTime.Load(); last > 0 {
+		st.Lag = time.Since(time.Unix(last, 0)).Round(time.Second)
+	}
+	return st
+}
+
+// scaleWorkers resizes the pool every scaleInterval until ctx is done or the
+// consumer has ended. A slow database shrinks the pool and the prefetch, a
+// busy CPU shrinks the pool, and a backlog grows it while there is CPU to
+// spare. An idle pool shrinks slowly.
+func (qh *QueueHandler) scaleWorkers(ctx context.Context) {
+	ticker := time.NewTicker(scaleInterval)
+	defer ticker.Stop()
+
+	cpu := newCPUSampler()
+	for tick := 1; ; tick++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-qh.consumed:
+			log.Println("Queue consumer ended, no longer scaling workers")
+			return
+		case <-ticker.C:
+		}
+
+		depth := -1
+		if d, ok := qh.transport.(QueueDepth); ok {
+			if n, err := d.Depth(); err == nil {
+				depth = n
+			} else {
+				log.Printf("Failed to get queue depth: %v", err)
+			}
+		}
+
+		qh.mu.Lock()
+		qh.stats.Depth = depth
+		qh.stats.CPU = cpu.sample()
+		qh.mu.Unlock()
+
+		st := qh.Stats()
+		target, prefetch := st.Workers, st.Prefetch
+		backlog := depth > st.Workers || st.Lag > backlogLag
+
+		switch {
+		case st.InsertLatency > qh.slowInsert:
+			target -= max(st.Workers/4, 1)
+			prefetch = max(prefetch/2, qh.minWorkers)
+		case st.CPU > cpuHigh:
+			target -= max(st.Workers/10, 1)
+		case backlog && st.CPU < cpuLow:
+			target += max(st.Workers/4, 1)
+			prefetch = min(prefetch*2, qh.maxPrefetch)
+		case depth == 0 && st.InFlight < int64(st.Workers/2):
+			target -= max(st.Workers/10, 1)
+		default:
+			prefetch = min(prefetch*2, qh.maxPrefetch)
+		}
+		target = min(max(target, qh.minWorkers), qh.maxWorkers)
+
+		if target != st.Workers {
+			log.Printf("Scaling workers %d -> %d (depth: %d lag: %s insert: %s cpu: %.0f%%)",
+				st.Workers, target, depth, st.Lag, st.InsertLatency.Round(time.Millisecond), st.CPU*100)
+			qh.resize(target)
+		}
+		if prefetch != st.Prefetch {
+			qh.setPrefetch(prefetch)
+		}
+
+		if tick%statsEvery == 0 {
+			st = qh.Stats()
+			log.Printf("Queue | workers: %d (%d-%d) in flight: %d prefetch: %d depth: %d lag: %s insert: %s cpu: %.0f%%",
+				st.Workers, st.MinWorkers, st.MaxWorkers, st.InFlight, st.Prefetch, st.Depth, st.Lag,
+				st.InsertLatency.Round(time.Millisecond), st.CPU*100)
+		}
+	}
+}
+
+func (qh *QueueHandler) resize(target int) {
+	qh.mu.Lock()
+	workers := len(qh.workerPool)
+	qh.mu.Unlock()
+
+	for ; workers < target; workers++ {
+		qh.startWorker()
+	}
+	for ; workers > target; workers-- {
+		qh.stopWorker()
+	}
+}
+
+func (qh *QueueHandler) setPrefetch(n int) {
+	if p, ok := qh.transport.(PrefetchSetter); ok {
+		if err := p.SetPrefetch(n); err != nil {
+			log.Printf("Failed to set prefetch to %d: %v", n, err)
+			return
+		}
+		log.Printf("Prefetch set to %d", n)
+	}
+
+	qh.mu.Lock()
+	qh.stats.Prefetch = n
+	qh.mu.Unlock()
+}
+
+// observeInsert folds a successful insert into the moving average latency.
+func (qh *QueueHandler) observeInsert(latency time.Duration) {
+	if avg := qh.insertNs.Load(); avg == 0 {
+		qh.insertNs.Store(int64(latency))
+	} else {
+		qh.insertNs.Store(int64(float64(avg)*(1-insertWeight) + float64(latency)*insertWeight))
+	}
+}
+
+// cpuSampler measures the share of GOMAXPROCS the process used between samples.
+type cpuSampler struct {
+	at  time.Time
+	cpu time.Duration
+}
+
+func newCPUSampler() *cpuSampler {
+	return &cpuSampler{at: time.Now(), cpu: processCPU()}
+}
+
+func (c *cpuSampler) sample() float64 {
+	now, cpu := time.Now(), processCPU()
+	wall := now.Sub(c.at) * time.Duration(runtime.GOMAXPROCS(0))
+	used := cpu - c.cpu
+	c.at, c.cpu = now, cpu
+	if wall <= 0 {
+		return 0
+	}
+	return float64(used) / float64(wall)
+}
+
+func processCPU() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -0,0 +1,29 @@
+package solana
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestListenReturnsWhenConsumerEnds(t *testing.T) {
+	transport := NewMemoryTransport(t.Name())
+	qh := NewQueueHandlerWithTransport(nil, nil, transport)
+
+	go qh.ListenToSolanaQueue(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	if workers := qh.Stats().Workers; workers != qh.minWorkers {
+		t.Fatalf("%d workers, want %d", workers, qh.minWorkers)
+	}
+
+	// The transport stops delivering while ctx is still live.
+	transport.Close()
+	select {
+	case <-qh.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("ListenToSolanaQueue did not return")
+	}
+	if workers := qh.Stats().Workers; workers != 0 {
+		t.Fatalf("%d workers reported after the consumer ended", workers)
+	}
+}
@@ -0,0 +1,14 @@
+package types
+
+const (
+	CommitmentProcessed = "processed"
+	CommitmentConfirmed = "confirmed"
+	CommitmentFinalized = "finalized"
+	SlotDead            = "dead"
+)
+
+type SlotStatus struct {
+	Slot   uint64 `json:"slot" db:"slot"`
+	Parent uint64 `json:"parent" db:"parent"`
+	Status string `json:"status" db:"status"`
+}
@@ -14,6 +14,12 @@ type SwapLog struct {
 	Pair             string    `json:"pair" db:"pair"`
 	Token            string    `json:"token" db:"token"`
 	Processed        bool      `json:"processed" db:"processed"`
+	Finalized        bool      `json:"finalized" db:"finalized"`
+	Hop              int       `json:"hop" db:"hop"` // leg of an aggregator route; 0 for the trade itself
+	Fee              float64   `json:"fee" db:"fee"`
+	BaseReserve      *string   `json:"baseReserve,omitempty" db:"baseReserve"`
+	QuoteReserve     *string   `json:"quoteReserve,omitempty" db:"quoteReserve"`
+	Creator          string    `json:"creator,omitempty" db:"creator"`
 	TokenSymbol      *string   `json:"tokenSymbol,omitempty" db:"tokenSymbol"`
 	QuoteTokenSymbol *string   `json:"quoteTokenSymbol,omitempty" db:"quoteTokenSymbol"`
 }
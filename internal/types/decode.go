@@ -0,0 +1,108 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reasons an instruction could not be decoded. Decoders wrap one of them in
+// a DecodeError, so callers can tell them apart with errors.Is.
+var (
+	ErrBadEncoding        = errors.New("bad encoding")
+	ErrShortData          = errors.New("data too short")
+	ErrUnknownInstruction = errors.New("unknown instruction")
+	ErrMissingAccounts    = errors.New("too few accounts")
+	ErrAccountIndex       = errors.New("account index out of range")
+	ErrNoCounterpart      = errors.New("no matching transfer")
+	ErrNoEvent            = errors.New("no event data")
+	ErrUnknownDecimals    = errors.New("unknown mint decimals")
+	ErrPanic              = errors.New("decoder panic")
+)
+
+var decodeReasons = []error{
+	ErrBadEncoding,
+	ErrShortData,
+	ErrUnknownInstruction,
+	ErrMissingAccounts,
+	ErrAccountIndex,
+	ErrNoCounterpart,
+	ErrNoEvent,
+	ErrUnknownDecimals,
+	ErrPanic,
+}
+
+// DecodeError is an instruction of Program that could not be decoded.
+type DecodeError struct {
+	Program string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Program, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NewDecodeError wraps reason, with an optional detail, for program.
+func NewDecodeError(program string, reason error, format string, args ...any) error {
+	err := reason
+	if format != "" {
+		err = fmt.Errorf("%w: %s", reason, fmt.Sprintf(format, args...))
+	}
+	return &DecodeError{Program: program, Err: err}
+}
+
+// DecodeReason is the short reason behind err, suitable as a counter key.
+func DecodeReason(err error) string {
+	for _, reason := range decodeReasons {
+		if errors.Is(err, reason) {
+			return reason.Error()
+		}
+	}
+	return "other"
+}
+
+// SkippedInstruction is an instruction the parser gave up on.
+type SkippedInstruction struct {
+	IxIndex    int    `json:"ixIndex"`
+	InnerIndex int    `json:"innerIndex"` // -1 for outer instructions
+	Program    string `json:"program"`
+	Reason     string `json:"reason"`
+	Err        error  `json:"-"`
+}
+
+// ParseDiagnostics records what parsing one transaction decoded and skipped.
+// A nil *ParseDiagnostics records nothing.
+type ParseDiagnostics struct {
+	Skipped []SkippedInstruction `json:"skipped,omitempty"`
+	Decoded map[string]int       `json:"decoded,omitempty"` // by program
+}
+
+func (d *ParseDiagnostics) Skip(ixIndex int, innerIndex int, program string, err error) {
+	if d == nil || err == nil {
+		return
+	}
+	var decodeErr *DecodeError
+	if program == "" && errors.As(err, &decodeErr) {
+		program = decodeErr.Program
+	}
+	d.Skipped = append(d.Skipped, SkippedInstruction{
+		IxIndex:    ixIndex,
+		InnerIndex: innerIndex,
+		Program:    program,
+		Reason:     DecodeReason(err),
+		Err:        err,
+	})
+}
+
+func (d *ParseDiagnostics) Decode(program string) {
+	if d == nil {
+		return
+	}
+	if d.Decoded == nil {
+		d.Decoded = make(map[string]int)
+	}
+	d.Decoded[program]++
+}
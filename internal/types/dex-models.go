@@ -288,6 +288,10 @@ func (m *FluxBeamPool) Decode(in []byte) error {
 	return nil
 }
 
+// OrcaTokenSwapPool is the pool of the legacy Orca programs, which keep the
+// SPL token-swap layout FluxBeam uses.
+type OrcaTokenSwapPool = FluxBeamPool
+
 type MarketSizeParams struct {
 	BidsSize uint64 `json:"bidsSize"`
 	AsksSize uint64 `json:"asksSize"`
@@ -295,50 +299,41 @@ type MarketSizeParams struct {
 }
 
 type TokenParams struct {
-	Decimals  uint8            `json:"decimals"`
-	VaultBump uint8            `json:"vaultBump"`
+	Decimals  uint32           `json:"decimals"`
+	VaultBump uint32           `json:"vaultBump"`
 	MintKey   common.PublicKey `json:"mintKey"`
 	VaultKey  common.PublicKey `json:"vaultKey"`
 }
 
 type Header struct {
 	Discriminator                   [8]byte          `json:"discriminator"`
-	Status                          uint8            `json:"status"`
+	Status                          uint64           `json:"status"`
 	MarketSizeParams                MarketSizeParams `json:"marketSizeParams"`
-	TokenParams                     TokenParams      `json:"tokenParams"`
+	BaseParams                      TokenParams      `json:"baseParams"`
 	BaseLotSize                     uint64           `json:"baseLotSize"`
+	QuoteParams                     TokenParams      `json:"quoteParams"`
 	QuoteLotSize                    uint64           `json:"quoteLotSize"`
 	TickSizeInQuoteAtomsPerBaseUnit uint64           `json:"tickSizeInQuoteAtomsPerBaseUnit"`
 	Authority                       common.PublicKey `json:"authority"`
 	FeeRecipient                    common.PublicKey `json:"feeRecipient"`
 	MarketSequenceNumber            uint64           `json:"marketSequenceNumber"`
 	Successor                       common.PublicKey `json:"successor"`
-	RawBaseUnitsPerBaseUnit         uint64           `json:"rawBaseUnitsPerBaseUnit"`
-	Padding1                        uint8            `json:"padding1"`
-	Padding2                        [32]uint8        `json:"padding2"`
+	RawBaseUnitsPerBaseUnit         uint32           `json:"rawBaseUnitsPerBaseUnit"`
+	Padding1                        uint32           `json:"padding1"`
+	Padding2                        [32]uint64       `json:"padding2"`
 }
 
+// PhoenixMarketLayout is the start of a Phoenix market account. The order
+// book that follows is sized by MarketSizeParams and not decoded.
 type PhoenixMarketLayout struct {
-	Header                      Header `json:"header"`
-	BaseLotsPerBaseUnit         uint64 `json:"baseLotsPerBaseUnit"`
-	QuoteLotsPerBaseUnitPerTick uint64 `json:"quoteLotsPerBaseUnitPerTick"`
-	OrderSequenceNumber         uint64 `json:"orderSequenceNumber"`
-	TakerFeeBps                 uint16 `json:"takerFeeBps"`
-	CollectedQuoteLotFees       uint64 `json:"collectedQuoteLotFees"`
-	UnclaimedQuoteLotFees       uint64 `json:"unclaimedQuoteLotFees"`
-	// Assuming you have a fixed-size order book (modify as necessary)
-	Bids [1024]OrderEntry `json:"bids"`
-	Asks [1024]OrderEntry `json:"asks"`
-	// Additional fields, like trader mappings, can be added as needed
-}
-
-type OrderEntry struct {
-	PriceInTicks                    uint64 `json:"priceInTicks"`
-	OrderSequenceNumber             uint64 `json:"orderSequenceNumber"`
-	TraderIndex                     uint64 `json:"traderIndex"`
-	NumBaseLots                     uint64 `json:"numBaseLots"`
-	LastValidSlot                   uint64 `json:"lastValidSlot"`
-	LastValidUnixTimestampInSeconds uint64 `json:"lastValidUnixTimestampInSeconds"`
+	Header                      Header     `json:"header"`
+	Padding                     [32]uint64 `json:"padding"`
+	BaseLotsPerBaseUnit         uint64     `json:"baseLotsPerBaseUnit"`
+	QuoteLotsPerBaseUnitPerTick uint64     `json:"quoteLotsPerBaseUnitPerTick"`
+	OrderSequenceNumber         uint64     `json:"orderSequenceNumber"`
+	TakerFeeBps                 uint64     `json:"takerFeeBps"`
+	CollectedQuoteLotFees       uint64     `json:"collectedQuoteLotFees"`
+	UnclaimedQuoteLotFees       uint64     `json:"unclaimedQuoteLotFees"`
 }
 
 func (m *PhoenixMarketLayout) Decode(in []byte) error {
@@ -362,9 +357,8 @@ type AmmFees struct {
 }
 
 type AmmCurve struct {
-	CurveType       uint8   `json:"curveType"`
-	Padding1        [7]byte // Padding to align next field to 8 bytes
-	CurveParameters uint64  `json:"curveParameters"`
+	CurveType       uint8  `json:"curveType"`
+	CurveParameters uint64 `json:"curveParameters"`
 }
 
 type AmmConfig struct {
@@ -400,6 +394,7 @@ type AmmConfig struct {
 }
 
 type LfinitySwapV2Layout struct {
+	Padding                        [8]byte
 	InitializerKey                 common.PublicKey `json:"initializerKey"`
 	InitializerDepositTokenAccount common.PublicKey `json:"initializerDepositTokenAccount"`
 	InitializerReceiveTokenAccount common.PublicKey `json:"initializerReceiveTokenAccount"`
@@ -411,7 +406,6 @@ type LfinitySwapV2Layout struct {
 	FreezeDeposit                  uint8            `json:"freezeDeposit"`
 	FreezeWithdraw                 uint8            `json:"freezeWithdraw"`
 	BaseDecimals                   uint8            `json:"baseDecimals"`
-	Padding1                       [3]byte          // Padding to align the next field to 8 bytes
 	TokenProgramId                 common.PublicKey `json:"tokenProgramId"`
 	TokenAAccount                  common.PublicKey `json:"tokenAAccount"`
 	TokenBAccount                  common.PublicKey `json:"tokenBAccount"`
@@ -0,0 +1,44 @@
+package types
+
+const (
+	IngestSourceLive     = "live"
+	IngestSourceBackfill = "backfill"
+	// IngestSourceAddress blocks hold a single wallet's or token's
+	// transactions and say nothing about the rest of the slot.
+	IngestSourceAddress = "address"
+
+	SlotOK      = "ok"
+	SlotSkipped = "skipped"
+	SlotFailed  = "failed"
+)
+
+type SlotLedgerEntry struct {
+	Slot   uint64 `json:"slot" db:"slot"`
+	Source string `json:"source" db:"source"`
+	// Piece names the part of the slot a pass covered: the first signature
+	// of a live piece, empty for a whole block.
+	Piece        string `json:"piece,omitempty" db:"piece"`
+	TxCount      int    `json:"txCount" db:"txCount"`
+	SwapCount    int    `json:"swapCount" db:"swapCount"`
+	ProcessingMs int64  `json:"processingMs" db:"processingMs"`
+	Status       string `json:"status" db:"status"`
+}
+
+type SlotRange struct {
+	From uint64 `json:"from" db:"from"`
+	To   uint64 `json:"to" db:"to"`
+}
+
+type IngestSummary struct {
+	FromSlot        uint64           `json:"fromSlot"`
+	ToSlot          uint64           `json:"toSlot"`
+	Recorded        int64            `json:"recorded"`
+	OK              int64            `json:"ok"`
+	Skipped         int64            `json:"skipped"`
+	Failed          int64            `json:"failed"`
+	Missing         int64            `json:"missing"`
+	Completeness    float64          `json:"completeness"`
+	BySource        map[string]int64 `json:"bySource"`
+	AvgProcessingMs float64          `json:"avgProcessingMs"`
+	Gaps            []SlotRange      `json:"gaps"`
+}
@@ -54,6 +54,15 @@ type Token struct {
 	CreatedTimestamp time.Time `json:"createdTimestamp" db:"createdTimestamp"`
 	Deployer         *string   `json:"deployer,omitempty" db:"deployer"`
 	Metadata         *string   `json:"metadata,omitempty" db:"metadata"`
+	Program          string    `json:"program,omitempty" db:"program"`
+	Extensions       string    `json:"extensions,omitempty" db:"extensions"` // comma separated Token-2022 mint extensions
+
+	MintAuthority   *string `json:"mintAuthority,omitempty" db:"mintAuthority"`
+	FreezeAuthority *string `json:"freezeAuthority,omitempty" db:"freezeAuthority"`
+	// Set once the mint has no such authority, whether it never had one or
+	// it was revoked. Tokens stored before these were tracked read false.
+	MintAuthorityRevoked   bool `json:"mintAuthorityRevoked" db:"mintAuthorityRevoked"`
+	FreezeAuthorityRevoked bool `json:"freezeAuthorityRevoked" db:"freezeAuthorityRevoked"`
 }
 
 //easyjson:json
@@ -285,6 +285,34 @@ func easyjson89aae3efDecodeBlocsyInternalTypes2(in *jlexer.Lexer, out *Token) {
 				}
 				*out.Metadata = string(in.String())
 			}
+		case "program":
+			out.Program = string(in.String())
+		case "extensions":
+			out.Extensions = string(in.String())
+		case "mintAuthority":
+			if in.IsNull() {
+				in.Skip()
+				out.MintAuthority = nil
+			} else {
+				if out.MintAuthority == nil {
+					out.MintAuthority = new(string)
+				}
+				*out.MintAuthority = string(in.String())
+			}
+		case "freezeAuthority":
+			if in.IsNull() {
+				in.Skip()
+				out.FreezeAuthority = nil
+			} else {
+				if out.FreezeAuthority == nil {
+					out.FreezeAuthority = new(string)
+				}
+				*out.FreezeAuthority = string(in.String())
+			}
+		case "mintAuthorityRevoked":
+			out.MintAuthorityRevoked = bool(in.Bool())
+		case "freezeAuthorityRevoked":
+			out.FreezeAuthorityRevoked = bool(in.Bool())
 		default:
 			in.SkipRecursive()
 		}
@@ -349,6 +377,36 @@ func easyjson89aae3efEncodeBlocsyInternalTypes2(out *jwriter.Writer, in Token) {
 		out.RawString(prefix)
 		out.String(string(*in.Metadata))
 	}
+	if in.Program != "" {
+		const prefix string = ",\"program\":"
+		out.RawString(prefix)
+		out.String(string(in.Program))
+	}
+	if in.Extensions != "" {
+		const prefix string = ",\"extensions\":"
+		out.RawString(prefix)
+		out.String(string(in.Extensions))
+	}
+	if in.MintAuthority != nil {
+		const prefix string = ",\"mintAuthority\":"
+		out.RawString(prefix)
+		out.String(string(*in.MintAuthority))
+	}
+	if in.FreezeAuthority != nil {
+		const prefix string = ",\"freezeAuthority\":"
+		out.RawString(prefix)
+		out.String(string(*in.FreezeAuthority))
+	}
+	{
+		const prefix string = ",\"mintAuthorityRevoked\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.MintAuthorityRevoked))
+	}
+	{
+		const prefix string = ",\"freezeAuthorityRevoked\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.FreezeAuthorityRevoked))
+	}
 	out.RawByte('}')
 }
 
@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+// PoolState is a snapshot of a pool account. Amounts are raw token units and
+// Price is quote per base in raw units; adjust with the mint decimals.
+// Reserves are only known for constant-product pools; concentrated pools
+// report Liquidity instead.
+type PoolState struct {
+	Pool         string    `json:"pool" db:"pool"`
+	Exchange     string    `json:"exchange" db:"exchange"`
+	Slot         uint64    `json:"slot" db:"slot"`
+	Timestamp    time.Time `json:"timestamp" db:"timestamp"`
+	BaseMint     string    `json:"baseMint" db:"baseMint"`
+	QuoteMint    string    `json:"quoteMint" db:"quoteMint"`
+	BaseReserve  *uint64   `json:"baseReserve,omitempty" db:"baseReserve"`
+	QuoteReserve *uint64   `json:"quoteReserve,omitempty" db:"quoteReserve"`
+	Liquidity    string    `json:"liquidity,omitempty" db:"liquidity"`
+	Price        float64   `json:"price" db:"price"`
+}
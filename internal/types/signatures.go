@@ -0,0 +1,18 @@
+package types
+
+// SignatureInfo is one entry of a getSignaturesForAddress page.
+type SignatureInfo struct {
+	Signature          string      `json:"signature"`
+	Slot               uint64      `json:"slot"`
+	BlockTime          *int64      `json:"blockTime"`
+	Err                interface{} `json:"err"`
+	ConfirmationStatus string      `json:"confirmationStatus"`
+}
+
+type HTTPSignaturesMessage struct {
+	Result []SignatureInfo `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
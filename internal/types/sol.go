@@ -39,6 +39,7 @@ type TokenProgramData struct {
 
 	Decimals int
 	Amount   uint64
+	Fee      uint64 // Token-2022 TransferCheckedWithFee
 
 	Owner common.PublicKey
 
@@ -47,6 +48,8 @@ type TokenProgramData struct {
 
 	AuthorityType byte
 	NewAuthority  common.PublicKey
+
+	Extension string // mint extension a Token-2022 instruction initializes
 }
 
 type Metadata struct {
@@ -125,6 +128,8 @@ type BlockData struct {
 	Timestamp    int64      `json:"blockTime"`
 	Block        uint64     `json:"block"`
 	IgnoreWS     bool       `json:"ignoreWS"`
+	Commitment   string     `json:"commitment,omitempty"`
+	Source       string     `json:"source,omitempty"`
 }
 
 //easyjson:json
@@ -350,11 +355,14 @@ type SolTransfer struct {
 
 	Mint     string
 	Decimals int
-	Amount   string
+	Amount   string // received by the destination
+	Fee      string // withheld by a Token-2022 transfer fee
 
 	Type            string
+	Program         string // token program that executed the instruction
 	ParentProgramId string
 	IxAccounts      []int
+	ParentData      string // base58 data of the parent instruction
 	EventData       string
 }
 
@@ -405,6 +413,13 @@ type SolSwap struct {
 	AmountIn  string
 	Wallet    string
 	Source    string
+	Hop       int // position in an aggregator route, 0 outside one
+
+	// Reported by venues that log their trades, like PumpSwap.
+	Fee          string // fees the user paid in the quote token, in whole tokens
+	BaseReserve  string // raw pool reserves after the trade
+	QuoteReserve string
+	Creator      string // creator-fee recipient of the pool
 }
 
 //easyjson:json
@@ -1181,6 +1181,8 @@ func easyjson791538f0DecodeBlocsyInternalTypes6(in *jlexer.Lexer, out *TokenProg
 			out.Decimals = int(in.Int())
 		case "Amount":
 			out.Amount = uint64(in.Uint64())
+		case "Fee":
+			out.Fee = uint64(in.Uint64())
 		case "Owner":
 			if data := in.Raw(); in.Ok() {
 				in.AddError((out.Owner).UnmarshalJSON(data))
@@ -1199,6 +1201,8 @@ func easyjson791538f0DecodeBlocsyInternalTypes6(in *jlexer.Lexer, out *TokenProg
 			if data := in.Raw(); in.Ok() {
 				in.AddError((out.NewAuthority).UnmarshalJSON(data))
 			}
+		case "Extension":
+			out.Extension = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -1233,6 +1237,11 @@ func easyjson791538f0EncodeBlocsyInternalTypes6(out *jwriter.Writer, in TokenPro
 		out.RawString(prefix)
 		out.Uint64(uint64(in.Amount))
 	}
+	{
+		const prefix string = ",\"Fee\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Fee))
+	}
 	{
 		const prefix string = ",\"Owner\":"
 		out.RawString(prefix)
@@ -1258,6 +1267,11 @@ func easyjson791538f0EncodeBlocsyInternalTypes6(out *jwriter.Writer, in TokenPro
 		out.RawString(prefix)
 		out.Raw((in.NewAuthority).MarshalJSON())
 	}
+	{
+		const prefix string = ",\"Extension\":"
+		out.RawString(prefix)
+		out.String(string(in.Extension))
+	}
 	out.RawByte('}')
 }
 
@@ -2038,8 +2052,12 @@ func easyjson791538f0DecodeBlocsyInternalTypes14(in *jlexer.Lexer, out *SolTrans
 			out.Decimals = int(in.Int())
 		case "Amount":
 			out.Amount = string(in.String())
+		case "Fee":
+			out.Fee = string(in.String())
 		case "Type":
 			out.Type = string(in.String())
+		case "Program":
+			out.Program = string(in.String())
 		case "ParentProgramId":
 			out.ParentProgramId = string(in.String())
 		case "IxAccounts":
@@ -2065,6 +2083,8 @@ func easyjson791538f0DecodeBlocsyInternalTypes14(in *jlexer.Lexer, out *SolTrans
 				}
 				in.Delim(']')
 			}
+		case "ParentData":
+			out.ParentData = string(in.String())
 		case "EventData":
 			out.EventData = string(in.String())
 		default:
@@ -2131,11 +2151,21 @@ func easyjson791538f0EncodeBlocsyInternalTypes14(out *jwriter.Writer, in SolTran
 		out.RawString(prefix)
 		out.String(string(in.Amount))
 	}
+	{
+		const prefix string = ",\"Fee\":"
+		out.RawString(prefix)
+		out.String(string(in.Fee))
+	}
 	{
 		const prefix string = ",\"Type\":"
 		out.RawString(prefix)
 		out.String(string(in.Type))
 	}
+	{
+		const prefix string = ",\"Program\":"
+		out.RawString(prefix)
+		out.String(string(in.Program))
+	}
 	{
 		const prefix string = ",\"ParentProgramId\":"
 		out.RawString(prefix)
@@ -2157,6 +2187,11 @@ func easyjson791538f0EncodeBlocsyInternalTypes14(out *jwriter.Writer, in SolTran
 			out.RawByte(']')
 		}
 	}
+	{
+		const prefix string = ",\"ParentData\":"
+		out.RawString(prefix)
+		out.String(string(in.ParentData))
+	}
 	{
 		const prefix string = ",\"EventData\":"
 		out.RawString(prefix)
@@ -2413,6 +2448,16 @@ func easyjson791538f0DecodeBlocsyInternalTypes16(in *jlexer.Lexer, out *SolSwap)
 			out.Wallet = string(in.String())
 		case "Source":
 			out.Source = string(in.String())
+		case "Hop":
+			out.Hop = int(in.Int())
+		case "Fee":
+			out.Fee = string(in.String())
+		case "BaseReserve":
+			out.BaseReserve = string(in.String())
+		case "QuoteReserve":
+			out.QuoteReserve = string(in.String())
+		case "Creator":
+			out.Creator = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -2467,6 +2512,31 @@ func easyjson791538f0EncodeBlocsyInternalTypes16(out *jwriter.Writer, in SolSwap
 		out.RawString(prefix)
 		out.String(string(in.Source))
 	}
+	{
+		const prefix string = ",\"Hop\":"
+		out.RawString(prefix)
+		out.Int(int(in.Hop))
+	}
+	{
+		const prefix string = ",\"Fee\":"
+		out.RawString(prefix)
+		out.String(string(in.Fee))
+	}
+	{
+		const prefix string = ",\"BaseReserve\":"
+		out.RawString(prefix)
+		out.String(string(in.BaseReserve))
+	}
+	{
+		const prefix string = ",\"QuoteReserve\":"
+		out.RawString(prefix)
+		out.String(string(in.QuoteReserve))
+	}
+	{
+		const prefix string = ",\"Creator\":"
+		out.RawString(prefix)
+		out.String(string(in.Creator))
+	}
 	out.RawByte('}')
 }
 
@@ -5291,6 +5361,10 @@ func easyjson791538f0DecodeBlocsyInternalTypes34(in *jlexer.Lexer, out *BlockDat
 			out.Block = uint64(in.Uint64())
 		case "ignoreWS":
 			out.IgnoreWS = bool(in.Bool())
+		case "commitment":
+			out.Commitment = string(in.String())
+		case "source":
+			out.Source = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -5336,6 +5410,16 @@ func easyjson791538f0EncodeBlocsyInternalTypes34(out *jwriter.Writer, in BlockDa
 		out.RawString(prefix)
 		out.Bool(bool(in.IgnoreWS))
 	}
+	if in.Commitment != "" {
+		const prefix string = ",\"commitment\":"
+		out.RawString(prefix)
+		out.String(string(in.Commitment))
+	}
+	if in.Source != "" {
+		const prefix string = ",\"source\":"
+		out.RawString(prefix)
+		out.String(string(in.Source))
+	}
 	out.RawByte('}')
 }
 
@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+const (
+	TokenEventSetAuthority = "setAuthority"
+	TokenEventFreeze       = "freeze"
+	TokenEventThaw         = "thaw"
+	TokenEventClose        = "close"
+	TokenEventApprove      = "approve"
+	TokenEventRevoke       = "revoke"
+
+	// SetAuthority types that act on the mint rather than a token account.
+	AuthorityMintTokens    = "mintTokens"
+	AuthorityFreezeAccount = "freezeAccount"
+)
+
+// TokenEvent is a token program instruction other than a transfer, mint or
+// burn. Account is the token account it acts on, or the mint for mint-level
+// authority changes.
+type TokenEvent struct {
+	Signature  string    `json:"signature" db:"signature"`
+	Slot       uint64    `json:"slot" db:"slot"`
+	Timestamp  time.Time `json:"timestamp" db:"timestamp"`
+	IxIndex    int       `json:"ixIndex" db:"ixIndex"`
+	InnerIndex int       `json:"innerIndex" db:"innerIndex"` // -1 for outer instructions
+	Program    string    `json:"program" db:"program"`
+	Type       string    `json:"type" db:"type"`
+	Mint       string    `json:"mint" db:"mint"`
+	Account    string    `json:"account" db:"account"`
+	Authority  string    `json:"authority" db:"authority"` // signer
+
+	AuthorityType string `json:"authorityType,omitempty" db:"authorityType"`
+	NewAuthority  string `json:"newAuthority,omitempty" db:"newAuthority"` // empty when revoked
+	Delegate      string `json:"delegate,omitempty" db:"delegate"`
+	Destination   string `json:"destination,omitempty" db:"destination"` // rent of a closed account
+	Amount        string `json:"amount,omitempty" db:"amount"`           // approved, in base units
+}
@@ -94,5 +94,11 @@ func GetDBConnection(ctx context.Context) (*sqlx.DB, error) {
 	db.CreateProcessedBlocksTable(ctx, dbx)
 	db.CreateTokenTable(ctx, dbx)
 	db.CreatePairTable(ctx, dbx)
+	db.CreateSlotStatusTable(ctx, dbx)
+	db.CreateSupplyChangesTable(ctx, dbx)
+	db.CreatePoolStateTable(ctx, dbx)
+	db.CreateBackfillRetryTable(ctx, dbx)
+	db.CreateSlotLedgerTable(ctx, dbx)
+	db.CreateTokenEventsTable(ctx, dbx)
 	return dbx, nil
 }